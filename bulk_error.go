@@ -0,0 +1,68 @@
+package ragstore
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/samber/lo"
+)
+
+// BulkError is returned by the bulk chunk methods, when some of the chunks
+// could not be written. All other chunks are written.
+type BulkError struct {
+	// Errors maps the index of each failed chunk, in the slice passed to
+	// the bulk method, to its error
+	Errors map[int]error
+}
+
+var _ error = (*BulkError)(nil)
+
+// Error returns the number of failed chunks, with the error of the first one
+func (e *BulkError) Error() string {
+	indexes := e.Indexes()
+
+	if len(indexes) < 1 {
+		return "bulk: no errors"
+	}
+
+	first := indexes[0]
+
+	return "bulk: " + strconv.Itoa(len(indexes)) + " failed, first at index " +
+		strconv.Itoa(first) + ": " + e.Errors[first].Error()
+}
+
+// Indexes returns the indexes of the failed chunks in ascending order
+func (e *BulkError) Indexes() []int {
+	indexes := lo.Keys(e.Errors)
+	slices.Sort(indexes)
+	return indexes
+}
+
+// Unwrap returns the errors of the failed chunks, for errors.Is and
+// errors.As
+func (e *BulkError) Unwrap() []error {
+	return lo.Map(e.Indexes(), func(index int, _ int) error {
+		return e.Errors[index]
+	})
+}
+
+// newBulkError creates an empty bulk error
+func newBulkError() *BulkError {
+	return &BulkError{
+		Errors: map[int]error{},
+	}
+}
+
+// add records the error of the chunk at the index
+func (e *BulkError) add(index int, err error) {
+	e.Errors[index] = err
+}
+
+// errorOrNil returns the bulk error, or nil when no chunk failed
+func (e *BulkError) errorOrNil() error {
+	if len(e.Errors) < 1 {
+		return nil
+	}
+
+	return e
+}
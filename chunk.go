@@ -1,18 +1,42 @@
 package ragstore
 
 import (
-	"encoding/json"
+	"errors"
+	"maps"
+	"slices"
 
 	"github.com/dromara/carbon/v2"
 	"github.com/gouniverse/dataobject"
+	"github.com/gouniverse/maputils"
 	"github.com/gouniverse/sb"
 	"github.com/gouniverse/uid"
+	"github.com/gouniverse/utils"
 	"github.com/spf13/cast"
 )
 
-const CHAT_MESSAGE_STATUS_ACTIVE = "active"
-const CHAT_MESSAGE_STATUS_INACTIVE = "inactive"
-const CHAT_MESSAGE_STATUS_DELETED = "deleted"
+// Deprecated: chunks have the CHUNK_STATUS_* statuses instead
+const (
+	CHAT_MESSAGE_STATUS_ACTIVE   = "active"
+	CHAT_MESSAGE_STATUS_INACTIVE = "inactive"
+	CHAT_MESSAGE_STATUS_DELETED  = "deleted"
+)
+
+// chunkStatuses lists the statuses of the chunk lifecycle
+var chunkStatuses = []string{
+	CHUNK_STATUS_PENDING,
+	CHUNK_STATUS_EMBEDDED,
+	CHUNK_STATUS_FAILED,
+	CHUNK_STATUS_STALE,
+}
+
+// validateChunkStatus checks the status is one of the chunk statuses
+func validateChunkStatus(status string) error {
+	if !slices.Contains(chunkStatuses, status) {
+		return errors.New("unsupported chunk status: " + status)
+	}
+
+	return nil
+}
 
 type Chunk struct {
 	dataobject.DataObject
@@ -22,6 +46,7 @@ func NewChunk() ChunkInterface {
 	o := &Chunk{}
 	o.SetID(uid.HumanUid())
 	// REQUIRED: o.SetDocumentID("")
+	o.SetEmbedding([]float32{}) // marks the chunk as pending
 	o.SetCreatedAt(carbon.Now(carbon.UTC).Format("Y-m-d H:i:s"))
 	o.SetUpdatedAt(carbon.Now(carbon.UTC).Format("Y-m-d H:i:s"))
 	o.SetSoftDeletedAt(sb.MAX_DATETIME)
@@ -39,6 +64,46 @@ func (o *Chunk) IsSoftDeleted() bool {
 	return o.SoftDeletedAt() != sb.MAX_DATETIME
 }
 
+// IsPending checks if the chunk is waiting for its embedding
+func (o *Chunk) IsPending() bool {
+	return o.Status() == CHUNK_STATUS_PENDING
+}
+
+// IsEmbedded checks if the embedding of the chunk is up to date
+func (o *Chunk) IsEmbedded() bool {
+	return o.Status() == CHUNK_STATUS_EMBEDDED
+}
+
+// IsFailed checks if embedding the chunk failed, and should be retried
+func (o *Chunk) IsFailed() bool {
+	return o.Status() == CHUNK_STATUS_FAILED
+}
+
+// IsStale checks if the content of the chunk changed since it was embedded
+func (o *Chunk) IsStale() bool {
+	return o.Status() == CHUNK_STATUS_STALE
+}
+
+// MarkPending marks the chunk as waiting for its embedding
+func (o *Chunk) MarkPending() ChunkInterface {
+	return o.SetStatus(CHUNK_STATUS_PENDING)
+}
+
+// MarkEmbedded marks the embedding of the chunk as up to date
+func (o *Chunk) MarkEmbedded() ChunkInterface {
+	return o.SetStatus(CHUNK_STATUS_EMBEDDED)
+}
+
+// MarkFailed marks embedding the chunk as failed, to be retried
+func (o *Chunk) MarkFailed() ChunkInterface {
+	return o.SetStatus(CHUNK_STATUS_FAILED)
+}
+
+// MarkStale marks the content of the chunk as changed since it was embedded
+func (o *Chunk) MarkStale() ChunkInterface {
+	return o.SetStatus(CHUNK_STATUS_STALE)
+}
+
 func (o *Chunk) ChunkIndex() int {
 	return cast.ToInt(o.Get(COLUMN_CHUNK_INDEX))
 }
@@ -52,11 +117,18 @@ func (o *Chunk) Content() string {
 	return o.Get(COLUMN_CONTENT)
 }
 
+// SetContent sets the content, along with its content hash
 func (o *Chunk) SetContent(content string) ChunkInterface {
 	o.Set(COLUMN_CONTENT, content)
+	o.Set(COLUMN_CONTENT_HASH, contentHash(content))
 	return o
 }
 
+// ContentHash returns the SHA-256 hash of the content, hex encoded
+func (o *Chunk) ContentHash() string {
+	return o.Get(COLUMN_CONTENT_HASH)
+}
+
 func (o *Chunk) DocumentID() string {
 	return o.Get(COLUMN_DOCUMENT_ID)
 }
@@ -66,16 +138,107 @@ func (o *Chunk) SetDocumentID(id string) ChunkInterface {
 	return o
 }
 
+// Embedding returns the decoded embedding, empty if the stored value
+// cannot be decoded. Use EmbeddingE to get the decoding error.
 func (o *Chunk) Embedding() []float32 {
-	jsonStr := o.Get(COLUMN_EMBEDDING)
-	var embedding []float32
-	_ = json.Unmarshal([]byte(jsonStr), &embedding)
+	embedding, _ := embeddingFromString(o.Get(COLUMN_EMBEDDING))
 	return embedding
 }
 
+// EmbeddingE returns the decoded embedding, or an error if the
+// stored value cannot be decoded
+func (o *Chunk) EmbeddingE() ([]float32, error) {
+	return embeddingFromString(o.Get(COLUMN_EMBEDDING))
+}
+
+// SetEmbedding sets the embedding, along with its number of dimensions,
+// and marks the chunk as embedded, or pending when the embedding is empty.
+// It is kept in the compact binary format, the store converts it to its
+// configured format when written.
 func (o *Chunk) SetEmbedding(embedding []float32) ChunkInterface {
-	jsonStr, _ := json.Marshal(embedding)
-	o.Set(COLUMN_EMBEDDING, string(jsonStr))
+	o.Set(COLUMN_EMBEDDING, string(embeddingToBinary(embedding)))
+	o.Set(COLUMN_EMBEDDING_DIM, cast.ToString(len(embedding)))
+
+	if len(embedding) > 0 {
+		return o.MarkEmbedded()
+	}
+
+	return o.MarkPending()
+}
+
+// EmbeddingDim returns the number of dimensions of the embedding, which
+// is 0 for chunks written before it was recorded
+func (o *Chunk) EmbeddingDim() int {
+	return cast.ToInt(o.Get(COLUMN_EMBEDDING_DIM))
+}
+
+// EmbeddingModel returns the name of the model the embedding was made with
+func (o *Chunk) EmbeddingModel() string {
+	return o.Get(COLUMN_EMBEDDING_MODEL)
+}
+
+func (o *Chunk) SetEmbeddingModel(model string) ChunkInterface {
+	o.Set(COLUMN_EMBEDDING_MODEL, model)
+	return o
+}
+
+func (o *Chunk) Meta(key string) (string, error) {
+	metas, err := o.Metas()
+	if err != nil {
+		return "", err
+	}
+	return metas[key], nil
+}
+
+func (o *Chunk) SetMeta(key string, value string) error {
+	return o.UpsertMetas(map[string]string{
+		key: value,
+	})
+}
+
+func (o *Chunk) Metas() (map[string]string, error) {
+	metasStr := o.Get(COLUMN_METAS)
+
+	if metasStr == "" {
+		metasStr = "{}"
+	}
+
+	metasJson, errJson := utils.FromJSON(metasStr, map[string]string{})
+	if errJson != nil {
+		return map[string]string{}, errJson
+	}
+
+	return maputils.MapStringAnyToMapStringString(metasJson.(map[string]any)), nil
+}
+
+func (o *Chunk) SetMetas(metas map[string]string) error {
+	mapString, err := utils.ToJSON(metas)
+	if err != nil {
+		return err
+	}
+
+	o.Set(COLUMN_METAS, mapString)
+	return nil
+}
+
+func (o *Chunk) UpsertMetas(metas map[string]string) error {
+	currentMetas, err := o.Metas()
+
+	if err != nil {
+		return err
+	}
+
+	maps.Copy(currentMetas, metas)
+
+	return o.SetMetas(currentMetas)
+}
+
+func (o *Chunk) OwnerID() string {
+	return o.Get(COLUMN_OWNER_ID)
+}
+
+func (o *Chunk) SetOwnerID(ownerID string) ChunkInterface {
+	o.Set(COLUMN_OWNER_ID, ownerID)
 	return o
 }
 
@@ -105,6 +268,18 @@ func (o *Chunk) SoftDeletedAtCarbon() *carbon.Carbon {
 	return carbon.Parse(o.SoftDeletedAt(), carbon.UTC)
 }
 
+func (o *Chunk) Status() string {
+	return o.Get(COLUMN_STATUS)
+}
+
+// SetStatus sets the status, one of the CHUNK_STATUS_* constants, which
+// is validated when the chunk is written. The Mark* methods set the
+// statuses of the lifecycle.
+func (o *Chunk) SetStatus(status string) ChunkInterface {
+	o.Set(COLUMN_STATUS, status)
+	return o
+}
+
 func (o *Chunk) Text() string {
 	return o.Get(COLUMN_TEXT)
 }
@@ -10,6 +10,16 @@ type ChunkInterface interface {
 
 	IsSoftDeleted() bool
 
+	IsPending() bool
+	IsEmbedded() bool
+	IsFailed() bool
+	IsStale() bool
+
+	MarkPending() ChunkInterface
+	MarkEmbedded() ChunkInterface
+	MarkFailed() ChunkInterface
+	MarkStale() ChunkInterface
+
 	DocumentID() string
 	SetDocumentID(chatID string) ChunkInterface
 
@@ -19,9 +29,31 @@ type ChunkInterface interface {
 	Content() string
 	SetContent(content string) ChunkInterface
 
+	ContentHash() string
+
 	Embedding() []float32
+	EmbeddingE() ([]float32, error)
 	SetEmbedding(embedding []float32) ChunkInterface
 
+	EmbeddingDim() int
+
+	EmbeddingModel() string
+	SetEmbeddingModel(model string) ChunkInterface
+
+	Meta(key string) (string, error)
+	SetMeta(key string, value string) error
+
+	Metas() (map[string]string, error)
+	SetMetas(metas map[string]string) error
+
+	UpsertMetas(metas map[string]string) error
+
+	OwnerID() string
+	SetOwnerID(ownerID string) ChunkInterface
+
+	Status() string
+	SetStatus(status string) ChunkInterface
+
 	CreatedAt() string
 	CreatedAtCarbon() *carbon.Carbon
 	SetCreatedAt(createdAt string) ChunkInterface
@@ -2,6 +2,8 @@ package ragstore
 
 import (
 	"errors"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/doug-martin/goqu/v9"
@@ -23,6 +25,21 @@ func ChunkQuery() ChunkQueryInterface {
 	}
 }
 
+// chunkQueryCopy returns a copy of the query, which can be changed without
+// changing the passed query, as the setters of the meta filters copy them
+// before adding to them. A nil query is copied as an empty query.
+func chunkQueryCopy(query ChunkQueryInterface) *chunkQuery {
+	copied := &chunkQuery{
+		params: map[string]interface{}{},
+	}
+
+	if q, ok := query.(*chunkQuery); ok && q != nil {
+		copied.params = maps.Clone(q.params)
+	}
+
+	return copied
+}
+
 func (q *chunkQuery) ToSelectDataset(st *store) (selectDataset *goqu.SelectDataset, columns []any, err error) {
 	if st == nil {
 		return nil, []any{}, errors.New("store cannot be nil")
@@ -44,6 +61,23 @@ func (q *chunkQuery) ToSelectDataset(st *store) (selectDataset *goqu.SelectDatas
 		sql = sql.Where(goqu.C(COLUMN_DOCUMENT_ID).In(q.GetDocumentIDIn()))
 	}
 
+	// Document query filter
+	if q.IsDocumentQuerySet() {
+		documents, _, err := q.GetDocumentQuery().ToSelectDataset(st)
+
+		if err != nil {
+			return nil, []any{}, err
+		}
+
+		documentIDs := documents.
+			ClearLimit().
+			ClearOffset().
+			ClearOrder().
+			Select(COLUMN_ID)
+
+		sql = sql.Where(goqu.C(COLUMN_DOCUMENT_ID).In(documentIDs))
+	}
+
 	// Created At filter
 	if q.IsCreatedAtGteSet() {
 		sql = sql.Where(goqu.C(COLUMN_CREATED_AT).Gte(q.GetCreatedAtGte()))
@@ -63,6 +97,16 @@ func (q *chunkQuery) ToSelectDataset(st *store) (selectDataset *goqu.SelectDatas
 		sql = sql.Where(goqu.C(COLUMN_ID).In(q.GetIDIn()))
 	}
 
+	// Embedding model filter
+	if q.IsEmbeddingModelSet() {
+		sql = sql.Where(goqu.C(COLUMN_EMBEDDING_MODEL).Eq(q.GetEmbeddingModel()))
+	}
+
+	// Owner ID filter
+	if q.IsOwnerIDSet() {
+		sql = sql.Where(goqu.C(COLUMN_OWNER_ID).Eq(q.GetOwnerID()))
+	}
+
 	// Status filter
 	if q.IsStatusSet() {
 		sql = sql.Where(goqu.C(COLUMN_STATUS).Eq(q.GetStatus()))
@@ -82,6 +126,9 @@ func (q *chunkQuery) ToSelectDataset(st *store) (selectDataset *goqu.SelectDatas
 		sql = sql.Where(goqu.C(COLUMN_UPDATED_AT).Lte(q.GetUpdatedAtLte()))
 	}
 
+	// Meta filters
+	sql = st.sqlMetaFilters(sql, q.GetMetaEquals(), q.GetMetaIn(), q.GetMetaExists())
+
 	if !q.IsCountOnlySet() {
 		if q.IsLimitSet() {
 			sql = sql.Limit(uint(q.GetLimit()))
@@ -168,6 +215,10 @@ func (q *chunkQuery) Validate() error {
 		return errors.New("chunk query: document_id_in cannot be empty array")
 	}
 
+	if q.IsEmbeddingModelSet() && q.GetEmbeddingModel() == "" {
+		return errors.New("chunk query: embedding_model cannot be empty")
+	}
+
 	if q.IsIDSet() && q.GetID() == "" {
 		return errors.New("chunk query: id cannot be empty")
 	}
@@ -196,10 +247,40 @@ func (q *chunkQuery) Validate() error {
 		return errors.New("chunk query: order_direction cannot be empty")
 	}
 
+	if q.IsOwnerIDSet() && q.GetOwnerID() == "" {
+		return errors.New("chunk query: owner_id cannot be empty")
+	}
+
+	if q.IsStatusSet() && q.GetStatus() == "" {
+		return errors.New("chunk query: status cannot be empty")
+	}
+
 	if q.IsStatusInSet() && len(q.GetStatusIn()) < 1 {
 		return errors.New("chunk query: status_in cannot be empty array")
 	}
 
+	for key, values := range q.GetMetaIn() {
+		if len(values) < 1 {
+			return errors.New("chunk query: meta_in values cannot be empty array")
+		}
+
+		if err := validateMetaKey(key); err != nil {
+			return errors.New("chunk query: " + err.Error())
+		}
+	}
+
+	for key := range q.GetMetaEquals() {
+		if err := validateMetaKey(key); err != nil {
+			return errors.New("chunk query: " + err.Error())
+		}
+	}
+
+	for _, key := range q.GetMetaExists() {
+		if err := validateMetaKey(key); err != nil {
+			return errors.New("chunk query: " + err.Error())
+		}
+	}
+
 	return nil
 }
 
@@ -292,6 +373,26 @@ func (q *chunkQuery) SetDocumentIDIn(documentIDIn []string) ChunkQueryInterface
 	return q
 }
 
+func (q *chunkQuery) IsDocumentQuerySet() bool {
+	return q.hasProperty("document_query")
+}
+
+func (q *chunkQuery) GetDocumentQuery() DocumentQueryInterface {
+	if q.IsDocumentQuerySet() {
+		return q.params["document_query"].(DocumentQueryInterface)
+	}
+
+	return nil
+}
+
+// SetDocumentQuery restricts the chunks to those of the documents
+// matching the document query. Limit, offset and ordering set on the
+// document query are ignored.
+func (q *chunkQuery) SetDocumentQuery(documentQuery DocumentQueryInterface) ChunkQueryInterface {
+	q.params["document_query"] = documentQuery
+	return q
+}
+
 func (q *chunkQuery) IsIDSet() bool {
 	return q.hasProperty("id")
 }
@@ -360,6 +461,67 @@ func (q *chunkQuery) SetLimit(limit int) ChunkQueryInterface {
 	return q
 }
 
+func (q *chunkQuery) IsMetaEqualsSet() bool {
+	return q.hasProperty("meta_equals")
+}
+
+func (q *chunkQuery) GetMetaEquals() map[string]string {
+	if q.IsMetaEqualsSet() {
+		return q.params["meta_equals"].(map[string]string)
+	}
+
+	return map[string]string{}
+}
+
+// SetMetaEquals filters by the value of the meta key. Can be set for
+// several keys, which must all match.
+func (q *chunkQuery) SetMetaEquals(key string, value string) ChunkQueryInterface {
+	metaEquals := maps.Clone(q.GetMetaEquals())
+	metaEquals[key] = value
+	q.params["meta_equals"] = metaEquals
+	return q
+}
+
+func (q *chunkQuery) IsMetaInSet() bool {
+	return q.hasProperty("meta_in")
+}
+
+func (q *chunkQuery) GetMetaIn() map[string][]string {
+	if q.IsMetaInSet() {
+		return q.params["meta_in"].(map[string][]string)
+	}
+
+	return map[string][]string{}
+}
+
+// SetMetaIn filters by the value of the meta key being one of the values.
+// Can be set for several keys, which must all match.
+func (q *chunkQuery) SetMetaIn(key string, values []string) ChunkQueryInterface {
+	metaIn := maps.Clone(q.GetMetaIn())
+	metaIn[key] = slices.Clone(values)
+	q.params["meta_in"] = metaIn
+	return q
+}
+
+func (q *chunkQuery) IsMetaExistsSet() bool {
+	return q.hasProperty("meta_exists")
+}
+
+func (q *chunkQuery) GetMetaExists() []string {
+	if q.IsMetaExistsSet() {
+		return q.params["meta_exists"].([]string)
+	}
+
+	return []string{}
+}
+
+// SetMetaExists filters by the meta key being set. Can be set for several
+// keys, which must all exist.
+func (q *chunkQuery) SetMetaExists(key string) ChunkQueryInterface {
+	q.params["meta_exists"] = append(slices.Clone(q.GetMetaExists()), key)
+	return q
+}
+
 func (q *chunkQuery) IsOffsetSet() bool {
 	return q.hasProperty("offset")
 }
@@ -428,6 +590,40 @@ func (q *chunkQuery) SetOrderBy(orderBy string) ChunkQueryInterface {
 	return q
 }
 
+func (q *chunkQuery) IsEmbeddingModelSet() bool {
+	return q.hasProperty("embedding_model")
+}
+
+func (q *chunkQuery) GetEmbeddingModel() string {
+	if q.IsEmbeddingModelSet() {
+		return q.params["embedding_model"].(string)
+	}
+
+	return ""
+}
+
+func (q *chunkQuery) SetEmbeddingModel(model string) ChunkQueryInterface {
+	q.params["embedding_model"] = model
+	return q
+}
+
+func (q *chunkQuery) IsOwnerIDSet() bool {
+	return q.hasProperty("owner_id")
+}
+
+func (q *chunkQuery) GetOwnerID() string {
+	if q.IsOwnerIDSet() {
+		return q.params["owner_id"].(string)
+	}
+
+	return ""
+}
+
+func (q *chunkQuery) SetOwnerID(ownerID string) ChunkQueryInterface {
+	q.params["owner_id"] = ownerID
+	return q
+}
+
 func (q *chunkQuery) IsSenderIDSet() bool {
 	return q.hasProperty("sender_id")
 }
@@ -16,6 +16,10 @@ type ChunkQueryInterface interface {
 	GetCreatedAtLte() string
 	SetCreatedAtLte(createdAt string) ChunkQueryInterface
 
+	IsEmbeddingModelSet() bool
+	GetEmbeddingModel() string
+	SetEmbeddingModel(model string) ChunkQueryInterface
+
 	IsIDSet() bool
 	GetID() string
 	SetID(id string) ChunkQueryInterface
@@ -40,6 +44,22 @@ type ChunkQueryInterface interface {
 	GetDocumentIDIn() []string
 	SetDocumentIDIn(chatIDs []string) ChunkQueryInterface
 
+	IsDocumentQuerySet() bool
+	GetDocumentQuery() DocumentQueryInterface
+	SetDocumentQuery(documentQuery DocumentQueryInterface) ChunkQueryInterface
+
+	IsMetaEqualsSet() bool
+	GetMetaEquals() map[string]string
+	SetMetaEquals(key string, value string) ChunkQueryInterface
+
+	IsMetaInSet() bool
+	GetMetaIn() map[string][]string
+	SetMetaIn(key string, values []string) ChunkQueryInterface
+
+	IsMetaExistsSet() bool
+	GetMetaExists() []string
+	SetMetaExists(key string) ChunkQueryInterface
+
 	IsOffsetSet() bool
 	GetOffset() int
 	SetOffset(offset int) ChunkQueryInterface
@@ -52,6 +72,18 @@ type ChunkQueryInterface interface {
 	GetOrderDirection() string
 	SetOrderDirection(orderDirection string) ChunkQueryInterface
 
+	IsOwnerIDSet() bool
+	GetOwnerID() string
+	SetOwnerID(ownerID string) ChunkQueryInterface
+
+	IsStatusSet() bool
+	GetStatus() string
+	SetStatus(status string) ChunkQueryInterface
+
+	IsStatusInSet() bool
+	GetStatusIn() []string
+	SetStatusIn(statuses []string) ChunkQueryInterface
+
 	// Count related methods
 	IsCountOnlySet() bool
 	GetCountOnly() bool
@@ -0,0 +1,123 @@
+package ragstore
+
+// ChunkSearchOptions defines the options for a chunk similarity search
+type ChunkSearchOptions struct {
+	// Limit is the number of top ranked chunks to return (defaults to 10)
+	Limit int
+
+	// Query optionally restricts the chunks that are searched, i.e. by
+	// document ID or soft delete status. Limit, offset and ordering
+	// set on the query are ignored.
+	Query ChunkQueryInterface
+
+	// DocumentQuery optionally restricts the chunks that are searched to
+	// those of the matching documents, i.e. by status or metas. It replaces
+	// a document query set on Query. The filters are applied before
+	// ranking, so the limit is filled from the matching chunks.
+	DocumentQuery DocumentQueryInterface
+
+	// DistanceMetric overrides the store distance metric for this search,
+	// one of the DISTANCE_METRIC_* constants
+	DistanceMetric string
+
+	// VectorSpace is the named vector space searched (defaults to the chunk
+	// embeddings). Named vector spaces are scanned exactly, and the
+	// EmbeddingModel is not checked, as each space holds a single model.
+	VectorSpace string
+
+	// EmbeddingModel is the model the query embedding was made with
+	// (defaults to the store embedding model). Only the chunks of the model
+	// are compared. Without a model, the search fails when the best ranked
+	// chunks were embedded with different models.
+	EmbeddingModel string
+
+	// Exact forces an exact full precision scan, even when the HNSW index
+	// or quantization is enabled
+	Exact bool
+}
+
+// ChunkKeywordSearchOptions defines the options for a chunk keyword search
+type ChunkKeywordSearchOptions struct {
+	// Limit is the number of top ranked chunks to return (defaults to 10)
+	Limit int
+
+	// Query optionally restricts the chunks that are searched, see
+	// ChunkSearchOptions
+	Query ChunkQueryInterface
+
+	// DocumentQuery optionally restricts the chunks that are searched to
+	// those of the matching documents, see ChunkSearchOptions
+	DocumentQuery DocumentQueryInterface
+}
+
+// ChunkSearchResult is a single ranked result of a chunk search
+type ChunkSearchResult struct {
+	Chunk ChunkInterface
+
+	// Score is higher for more similar chunks. For the euclidean and
+	// manhattan metrics it is the negated distance, for keyword search
+	// it is the BM25 score.
+	Score float64
+}
+
+// ChunkHybridSearchOptions defines the options for a chunk hybrid search,
+// which fuses the results of a similarity and a keyword search
+type ChunkHybridSearchOptions struct {
+	// Limit is the number of top ranked chunks to return (defaults to 10)
+	Limit int
+
+	// CandidateLimit is the number of chunks retrieved from each search
+	// before fusion (defaults to 5 times the limit)
+	CandidateLimit int
+
+	// Query optionally restricts the chunks that are searched, see
+	// ChunkSearchOptions
+	Query ChunkQueryInterface
+
+	// DocumentQuery optionally restricts the chunks that are searched to
+	// those of the matching documents, see ChunkSearchOptions
+	DocumentQuery DocumentQueryInterface
+
+	// DistanceMetric overrides the store distance metric for the
+	// similarity search, one of the DISTANCE_METRIC_* constants
+	DistanceMetric string
+
+	// VectorSpace is the named vector space of the similarity search, see
+	// ChunkSearchOptions
+	VectorSpace string
+
+	// EmbeddingModel is the model the query embedding was made with, see
+	// ChunkSearchOptions
+	EmbeddingModel string
+
+	// Fusion is the method the results are fused with, one of the
+	// FUSION_* constants (defaults to reciprocal rank fusion)
+	Fusion string
+
+	// RRFK is the rank constant of reciprocal rank fusion, higher values
+	// flatten the differences between ranks (defaults to 60)
+	RRFK int
+
+	// VectorWeight and KeywordWeight weigh the min-max normalised scores
+	// of the searches for weighted fusion (both default to 0.5)
+	VectorWeight  float64
+	KeywordWeight float64
+}
+
+// ChunkHybridSearchResult is a single ranked result of a hybrid search
+type ChunkHybridSearchResult struct {
+	Chunk ChunkInterface
+
+	// Score is the fused score, higher for more relevant chunks
+	Score float64
+
+	// VectorScore and VectorRank are the similarity search score and its
+	// 1-based rank. The rank is 0 when the search did not return the chunk.
+	VectorScore float64
+	VectorRank  int
+
+	// KeywordScore and KeywordRank are the BM25 score and its 1-based
+	// rank. The rank is 0 when the search did not return the chunk.
+	KeywordScore float64
+	KeywordRank  int
+}
@@ -0,0 +1,262 @@
+package ragstore
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Chunker splits a text into the contents of chunks
+type Chunker interface {
+	Split(text string) ([]string, error)
+}
+
+// ============================================================================
+// == FIXED SIZE
+// ============================================================================
+
+type fixedSizeChunker struct {
+	size    int
+	overlap int
+}
+
+var _ Chunker = (*fixedSizeChunker)(nil)
+
+// NewFixedSizeChunker creates a chunker, which splits the text into chunks
+// of size characters, each repeating the last overlap characters of the
+// previous one
+func NewFixedSizeChunker(size int, overlap int) Chunker {
+	return &fixedSizeChunker{
+		size:    size,
+		overlap: overlap,
+	}
+}
+
+// Split splits the text into chunks of the configured size
+func (c *fixedSizeChunker) Split(text string) ([]string, error) {
+	if c.size < 1 {
+		return nil, errors.New("chunker: size must be positive")
+	}
+
+	if c.overlap < 0 || c.overlap >= c.size {
+		return nil, errors.New("chunker: overlap must be between 0 and size")
+	}
+
+	return splitFixedSize([]rune(text), c.size, c.overlap), nil
+}
+
+// splitFixedSize splits the runes into windows of the size, moving by
+// size minus overlap, skipping blank windows
+func splitFixedSize(runes []rune, size int, overlap int) []string {
+	chunks := []string{}
+
+	for start := 0; start < len(runes); start += size - overlap {
+		end := min(start+size, len(runes))
+		chunk := string(runes[start:end])
+
+		if strings.TrimSpace(chunk) != "" {
+			chunks = append(chunks, chunk)
+		}
+
+		if end == len(runes) {
+			break
+		}
+	}
+
+	return chunks
+}
+
+// ============================================================================
+// == SENTENCE
+// ============================================================================
+
+type sentenceChunker struct {
+	maxSize int
+}
+
+var _ Chunker = (*sentenceChunker)(nil)
+
+// NewSentenceChunker creates a chunker, which groups whole sentences into
+// chunks of at most maxSize characters. Sentences longer than maxSize are
+// split at the size.
+func NewSentenceChunker(maxSize int) Chunker {
+	return &sentenceChunker{
+		maxSize: maxSize,
+	}
+}
+
+// Split splits the text into chunks of whole sentences
+func (c *sentenceChunker) Split(text string) ([]string, error) {
+	if c.maxSize < 1 {
+		return nil, errors.New("chunker: max size must be positive")
+	}
+
+	pieces := []string{}
+
+	for _, sentence := range splitSentences(text) {
+		if len([]rune(sentence)) > c.maxSize {
+			pieces = append(pieces, splitFixedSize([]rune(sentence), c.maxSize, 0)...)
+			continue
+		}
+
+		pieces = append(pieces, sentence)
+	}
+
+	return mergePieces(pieces, " ", c.maxSize), nil
+}
+
+// splitSentences splits the text after sentence ending punctuation, which
+// is followed by white space, and at blank lines. The sentences are trimmed.
+func splitSentences(text string) []string {
+	sentences := []string{}
+	runes := []rune(text)
+	start := 0
+
+	add := func(end int) {
+		sentence := strings.TrimSpace(string(runes[start:end]))
+
+		if sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+
+		start = end
+	}
+
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\n' && i+1 < len(runes) && runes[i+1] == '\n':
+			add(i)
+		case strings.ContainsRune(".!?", runes[i]):
+			end := i + 1
+
+			// closing quotes and brackets belong to the sentence
+			for end < len(runes) && strings.ContainsRune(`"')]`, runes[end]) {
+				end++
+			}
+
+			if end == len(runes) || unicode.IsSpace(runes[end]) {
+				add(end)
+				i = end - 1
+			}
+		}
+	}
+
+	add(len(runes))
+
+	return sentences
+}
+
+// ============================================================================
+// == RECURSIVE
+// ============================================================================
+
+// recursiveChunkerSeparators are the default separators of the recursive
+// chunker, from paragraphs down to words
+var recursiveChunkerSeparators = []string{"\n\n", "\n", ". ", " "}
+
+type recursiveChunker struct {
+	size       int
+	separators []string
+}
+
+var _ Chunker = (*recursiveChunker)(nil)
+
+// NewRecursiveChunker creates a chunker, which splits the text at the first
+// separator and merges the pieces into chunks of at most size characters.
+// Pieces still longer than size are split again at the next separator,
+// and at the size when no separators are left. Without separators,
+// paragraphs, lines, sentences and words are used.
+func NewRecursiveChunker(size int, separators ...string) Chunker {
+	if len(separators) < 1 {
+		separators = recursiveChunkerSeparators
+	}
+
+	return &recursiveChunker{
+		size:       size,
+		separators: separators,
+	}
+}
+
+// Split splits the text recursively at the separators
+func (c *recursiveChunker) Split(text string) ([]string, error) {
+	if c.size < 1 {
+		return nil, errors.New("chunker: size must be positive")
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return []string{}, nil
+	}
+
+	return c.split(text, c.separators), nil
+}
+
+func (c *recursiveChunker) split(text string, separators []string) []string {
+	if len([]rune(text)) <= c.size {
+		return []string{strings.TrimSpace(text)}
+	}
+
+	if len(separators) < 1 {
+		return splitFixedSize([]rune(text), c.size, 0)
+	}
+
+	separator := separators[0]
+
+	if separator == "" || !strings.Contains(text, separator) {
+		return c.split(text, separators[1:])
+	}
+
+	chunks := []string{}
+	pieces := []string{}
+
+	// the separators are kept, so that the merged chunks read as the text
+	for _, piece := range strings.SplitAfter(text, separator) {
+		if strings.TrimSpace(piece) == "" {
+			continue
+		}
+
+		if len([]rune(piece)) > c.size {
+			chunks = append(chunks, mergePieces(pieces, "", c.size)...)
+			chunks = append(chunks, c.split(piece, separators[1:])...)
+			pieces = []string{}
+			continue
+		}
+
+		pieces = append(pieces, piece)
+	}
+
+	return append(chunks, mergePieces(pieces, "", c.size)...)
+}
+
+// mergePieces joins consecutive pieces with the joiner, as long as the
+// joined text is at most size characters. The chunks are trimmed.
+func mergePieces(pieces []string, joiner string, size int) []string {
+	chunks := []string{}
+	current := ""
+
+	add := func() {
+		if chunk := strings.TrimSpace(current); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+
+	for _, piece := range pieces {
+		if current == "" {
+			current = piece
+			continue
+		}
+
+		joined := current + joiner + piece
+
+		if len([]rune(strings.TrimSpace(joined))) > size {
+			add()
+			current = piece
+			continue
+		}
+
+		current = joined
+	}
+
+	add()
+
+	return chunks
+}
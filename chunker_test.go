@@ -0,0 +1,119 @@
+package ragstore
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFixedSizeChunker(t *testing.T) {
+	chunks, err := NewFixedSizeChunker(4, 1).Split("abcdefghij")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := []string{"abcd", "defg", "ghij"}
+
+	if !slices.Equal(chunks, expected) {
+		t.Fatalf("Expected chunks %v, got %v", expected, chunks)
+	}
+
+	// Multi-byte characters count as one
+	chunks, err = NewFixedSizeChunker(2, 0).Split("äöüß")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !slices.Equal(chunks, []string{"äö", "üß"}) {
+		t.Fatalf("Unexpected chunks %v", chunks)
+	}
+
+	if _, err := NewFixedSizeChunker(4, 4).Split("abc"); err == nil {
+		t.Fatal("expected error for overlap not below size, but got nil")
+	}
+
+	if _, err := NewFixedSizeChunker(0, 0).Split("abc"); err == nil {
+		t.Fatal("expected error for zero size, but got nil")
+	}
+}
+
+func TestSentenceChunker(t *testing.T) {
+	text := "First sentence. Second one!  Is this the third? \"Quoted.\" Last\n\nNew paragraph"
+
+	chunks, err := NewSentenceChunker(30).Split(text)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := []string{
+		"First sentence. Second one!",
+		"Is this the third? \"Quoted.\"",
+		"Last New paragraph",
+	}
+
+	if !slices.Equal(chunks, expected) {
+		t.Fatalf("Expected chunks %q, got %q", expected, chunks)
+	}
+
+	// Sentences longer than the max size are split
+	chunks, err = NewSentenceChunker(5).Split("Abcdefghij.")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !slices.Equal(chunks, []string{"Abcde", "fghij", "."}) {
+		t.Fatalf("Unexpected chunks %q", chunks)
+	}
+}
+
+func TestRecursiveChunker(t *testing.T) {
+	text := "Paragraph one is short.\n\nParagraph two has two sentences. It is longer than the size.\n\nThree."
+
+	chunks, err := NewRecursiveChunker(40).Split(text)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	expected := []string{
+		"Paragraph one is short.",
+		"Paragraph two has two sentences.",
+		"It is longer than the size.",
+		"Three.",
+	}
+
+	if !slices.Equal(chunks, expected) {
+		t.Fatalf("Expected chunks %q, got %q", expected, chunks)
+	}
+
+	for _, chunk := range chunks {
+		if len(chunk) > 40 {
+			t.Fatalf("Chunk longer than the size: %q", chunk)
+		}
+	}
+
+	// Custom separators, falling back to the size
+	chunks, err = NewRecursiveChunker(5, ",").Split("ab,cd,efghijkl")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !slices.Equal(chunks, []string{"ab,", "cd,", "efghi", "jkl"}) {
+		t.Fatalf("Unexpected chunks %q", chunks)
+	}
+
+	chunks, err = NewRecursiveChunker(10).Split(strings.Repeat(" ", 20))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(chunks) != 0 {
+		t.Fatalf("Expected no chunks for blank text, got %q", chunks)
+	}
+}
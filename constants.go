@@ -1,19 +1,57 @@
 package ragstore
 
+const COLUMN_CHUNK_ID = "chunk_id"
 const COLUMN_CHUNK_INDEX = "chunk_index"
+const COLUMN_CHUNK_LENGTH = "chunk_length"
 const COLUMN_CONTENT = "content"
+const COLUMN_CONTENT_HASH = "content_hash"
 const COLUMN_EMBEDDING = "embedding"
+const COLUMN_EMBEDDING_DIM = "embedding_dim"
+const COLUMN_EMBEDDING_MODEL = "embedding_model"
+const COLUMN_EMBEDDING_NEXT = "embedding_next"
+const COLUMN_EMBEDDING_NEXT_MODEL = "embedding_next_model"
+const COLUMN_EMBEDDING_QUANTIZED = "embedding_quantized"
+const COLUMN_EXPIRES_AT = "expires_at"
 const COLUMN_CREATED_AT = "created_at"
 const COLUMN_DOCUMENT_ID = "document_id"
 const COLUMN_ID = "id"
 const COLUMN_MEMO = "memo"
 const COLUMN_METAS = "metas"
 const COLUMN_FILE_NAME = "file_name"
+const COLUMN_HOLDER = "holder"
+const COLUMN_OWNER_ID = "owner_id"
 const COLUMN_SOFT_DELETED_AT = "soft_deleted_at"
+const COLUMN_SOFT_DELETED_CASCADE = "soft_deleted_cascade"
 const COLUMN_STATUS = "status"
+const COLUMN_TERM = "term"
+const COLUMN_TERM_FREQUENCY = "term_frequency"
 const COLUMN_TEXT = "text"
 const COLUMN_UPDATED_AT = "updated_at"
+const COLUMN_VECTOR_SPACE = "vector_space"
 
 const DOCUMENT_STATUS_ACTIVE = "active"
 const DOCUMENT_STATUS_INACTIVE = "inactive"
 const DOCUMENT_STATUS_DELETED = "deleted"
+
+const CHUNK_STATUS_PENDING = "pending"
+const CHUNK_STATUS_EMBEDDED = "embedded"
+const CHUNK_STATUS_FAILED = "failed"
+const CHUNK_STATUS_STALE = "stale"
+
+// VECTOR_SPACE_DEFAULT is the vector space of the chunk embedding column
+const VECTOR_SPACE_DEFAULT = "default"
+
+const DISTANCE_METRIC_COSINE = "cosine"
+const DISTANCE_METRIC_DOT_PRODUCT = "dot_product"
+const DISTANCE_METRIC_EUCLIDEAN = "euclidean"
+const DISTANCE_METRIC_MANHATTAN = "manhattan"
+
+const EMBEDDING_FORMAT_JSON = "json"
+const EMBEDDING_FORMAT_BINARY = "binary"
+
+const QUANTIZATION_NONE = "none"
+const QUANTIZATION_INT8 = "int8"
+const QUANTIZATION_BINARY = "binary"
+
+const FUSION_RRF = "rrf"
+const FUSION_WEIGHTED = "weighted"
@@ -0,0 +1,23 @@
+package ragstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// contentHash returns the SHA-256 hash of the text, hex encoded, used to
+// tell unchanged chunks apart when a document is reindexed
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// chunkContentHash returns the content hash of the chunk, hashing the
+// content when no hash is recorded
+func chunkContentHash(chunk ChunkInterface) string {
+	if hash := chunk.ContentHash(); hash != "" {
+		return hash
+	}
+
+	return contentHash(chunk.Content())
+}
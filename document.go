@@ -27,7 +27,7 @@ func NewDocument() DocumentInterface {
 	o := &documentImplementation{}
 
 	o.SetID(uid.HumanUid()).
-		// REQUIRED:SetOwnerID("").
+		SetOwnerID("").
 		SetStatus(DOCUMENT_STATUS_ACTIVE).
 		SetMemo("").
 		SetCreatedAt(carbon.Now(carbon.UTC).Format("Y-m-d H:i:s")).
@@ -160,6 +160,15 @@ func (o *documentImplementation) UpsertMetas(metas map[string]string) error {
 	return o.SetMetas(currentMetas)
 }
 
+func (o *documentImplementation) OwnerID() string {
+	return o.Get(COLUMN_OWNER_ID)
+}
+
+func (o *documentImplementation) SetOwnerID(ownerID string) DocumentInterface {
+	o.Set(COLUMN_OWNER_ID, ownerID)
+	return o
+}
+
 func (o *documentImplementation) SoftDeletedAt() string {
 	return o.Get(COLUMN_SOFT_DELETED_AT)
 }
@@ -186,11 +195,18 @@ func (o *documentImplementation) Text() string {
 	return o.Get(COLUMN_TEXT)
 }
 
+// SetText sets the text, along with its content hash
 func (o *documentImplementation) SetText(text string) DocumentInterface {
 	o.Set(COLUMN_TEXT, text)
+	o.Set(COLUMN_CONTENT_HASH, contentHash(text))
 	return o
 }
 
+// ContentHash returns the SHA-256 hash of the text, hex encoded
+func (o *documentImplementation) ContentHash() string {
+	return o.Get(COLUMN_CONTENT_HASH)
+}
+
 func (o *documentImplementation) UpdatedAt() string {
 	return o.Get(COLUMN_UPDATED_AT)
 }
@@ -23,6 +23,8 @@ type DocumentInterface interface {
 	Text() string
 	SetText(text string) DocumentInterface
 
+	ContentHash() string
+
 	Memo() string
 	SetMemo(memo string) DocumentInterface
 
@@ -34,6 +36,9 @@ type DocumentInterface interface {
 
 	UpsertMetas(metas map[string]string) error
 
+	OwnerID() string
+	SetOwnerID(ownerID string) DocumentInterface
+
 	CreatedAt() string
 	CreatedAtCarbon() *carbon.Carbon
 	SetCreatedAt(createdAt string) DocumentInterface
@@ -2,6 +2,8 @@ package ragstore
 
 import (
 	"errors"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/doug-martin/goqu/v9"
@@ -61,6 +63,28 @@ func (q *documentQuery) Validate() error {
 		return errors.New("document query: status_in cannot be empty array")
 	}
 
+	for key, values := range q.GetMetaIn() {
+		if len(values) < 1 {
+			return errors.New("document query: meta_in values cannot be empty array")
+		}
+
+		if err := validateMetaKey(key); err != nil {
+			return errors.New("document query: " + err.Error())
+		}
+	}
+
+	for key := range q.GetMetaEquals() {
+		if err := validateMetaKey(key); err != nil {
+			return errors.New("document query: " + err.Error())
+		}
+	}
+
+	for _, key := range q.GetMetaExists() {
+		if err := validateMetaKey(key); err != nil {
+			return errors.New("document query: " + err.Error())
+		}
+	}
+
 	return nil
 }
 
@@ -94,6 +118,11 @@ func (q *documentQuery) ToSelectDataset(st *store) (selectDataset *goqu.SelectDa
 		sql = sql.Where(goqu.C(COLUMN_ID).In(q.GetIDIn()))
 	}
 
+	// Owner ID filter
+	if q.IsOwnerIDSet() {
+		sql = sql.Where(goqu.C(COLUMN_OWNER_ID).Eq(q.GetOwnerID()))
+	}
+
 	// Status filter
 	if q.IsStatusSet() {
 		sql = sql.Where(goqu.C(COLUMN_STATUS).Eq(q.GetStatus()))
@@ -113,6 +142,9 @@ func (q *documentQuery) ToSelectDataset(st *store) (selectDataset *goqu.SelectDa
 		sql = sql.Where(goqu.C(COLUMN_UPDATED_AT).Lte(q.GetUpdatedAtLte()))
 	}
 
+	// Meta filters
+	sql = st.sqlMetaFilters(sql, q.GetMetaEquals(), q.GetMetaIn(), q.GetMetaExists())
+
 	if !q.IsCountOnlySet() {
 		if q.IsLimitSet() {
 			sql = sql.Limit(uint(q.GetLimit()))
@@ -302,6 +334,67 @@ func (q *documentQuery) SetLimit(limit int) DocumentQueryInterface {
 	return q
 }
 
+func (q *documentQuery) IsMetaEqualsSet() bool {
+	return q.hasProperty("meta_equals")
+}
+
+func (q *documentQuery) GetMetaEquals() map[string]string {
+	if q.IsMetaEqualsSet() {
+		return q.params["meta_equals"].(map[string]string)
+	}
+
+	return map[string]string{}
+}
+
+// SetMetaEquals filters by the value of the meta key. Can be set for
+// several keys, which must all match.
+func (q *documentQuery) SetMetaEquals(key string, value string) DocumentQueryInterface {
+	metaEquals := maps.Clone(q.GetMetaEquals())
+	metaEquals[key] = value
+	q.params["meta_equals"] = metaEquals
+	return q
+}
+
+func (q *documentQuery) IsMetaInSet() bool {
+	return q.hasProperty("meta_in")
+}
+
+func (q *documentQuery) GetMetaIn() map[string][]string {
+	if q.IsMetaInSet() {
+		return q.params["meta_in"].(map[string][]string)
+	}
+
+	return map[string][]string{}
+}
+
+// SetMetaIn filters by the value of the meta key being one of the values.
+// Can be set for several keys, which must all match.
+func (q *documentQuery) SetMetaIn(key string, values []string) DocumentQueryInterface {
+	metaIn := maps.Clone(q.GetMetaIn())
+	metaIn[key] = slices.Clone(values)
+	q.params["meta_in"] = metaIn
+	return q
+}
+
+func (q *documentQuery) IsMetaExistsSet() bool {
+	return q.hasProperty("meta_exists")
+}
+
+func (q *documentQuery) GetMetaExists() []string {
+	if q.IsMetaExistsSet() {
+		return q.params["meta_exists"].([]string)
+	}
+
+	return []string{}
+}
+
+// SetMetaExists filters by the meta key being set. Can be set for several
+// keys, which must all exist.
+func (q *documentQuery) SetMetaExists(key string) DocumentQueryInterface {
+	q.params["meta_exists"] = append(slices.Clone(q.GetMetaExists()), key)
+	return q
+}
+
 func (q *documentQuery) IsOffsetSet() bool {
 	return q.hasProperty("offset")
 }
@@ -46,6 +46,18 @@ type DocumentQueryInterface interface {
 	GetLimit() int
 	SetLimit(limit int) DocumentQueryInterface
 
+	IsMetaEqualsSet() bool
+	GetMetaEquals() map[string]string
+	SetMetaEquals(key string, value string) DocumentQueryInterface
+
+	IsMetaInSet() bool
+	GetMetaIn() map[string][]string
+	SetMetaIn(key string, values []string) DocumentQueryInterface
+
+	IsMetaExistsSet() bool
+	GetMetaExists() []string
+	SetMetaExists(key string) DocumentQueryInterface
+
 	IsOffsetSet() bool
 	GetOffset() int
 	SetOffset(offset int) DocumentQueryInterface
@@ -54,6 +66,10 @@ type DocumentQueryInterface interface {
 	GetOrderBy() string
 	SetOrderBy(orderBy string) DocumentQueryInterface
 
+	IsOwnerIDSet() bool
+	GetOwnerID() string
+	SetOwnerID(ownerID string) DocumentQueryInterface
+
 	IsOrderDirectionSet() bool
 	GetOrderDirection() string
 	SetOrderDirection(orderDirection string) DocumentQueryInterface
@@ -0,0 +1,63 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+)
+
+// Embedder turns texts into embeddings, one per text, in the same order
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+type hashEmbedder struct {
+	dimension int
+}
+
+var _ Embedder = (*hashEmbedder)(nil)
+
+// NewHashEmbedder creates a deterministic embedder, which hashes the terms
+// of each text into an embedding of the dimension, normalised to unit
+// length. Texts sharing terms are similar, so it can stand in for a model
+// in tests and offline pipelines, but it captures no meaning.
+func NewHashEmbedder(dimension int) Embedder {
+	return &hashEmbedder{
+		dimension: dimension,
+	}
+}
+
+// Embed embeds each text
+func (e *hashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.dimension < 1 {
+		return nil, errors.New("hash embedder: dimension must be positive")
+	}
+
+	embeddings := make([][]float32, 0, len(texts))
+
+	for _, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		embedding := make([]float32, e.dimension)
+
+		for _, term := range keywordTerms(text) {
+			hash := fnv.New64a()
+			hash.Write([]byte(term))
+			sum := hash.Sum64()
+
+			// the top bit decides the sign, so that collisions cancel out
+			// instead of adding up
+			if sum>>63 == 1 {
+				embedding[sum%uint64(e.dimension)]--
+			} else {
+				embedding[sum%uint64(e.dimension)]++
+			}
+		}
+
+		embeddings = append(embeddings, NormalizeEmbedding(embedding))
+	}
+
+	return embeddings, nil
+}
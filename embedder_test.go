@@ -0,0 +1,41 @@
+package ragstore
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	embedder := NewHashEmbedder(64)
+
+	embeddings, err := embedder.Embed(context.Background(), []string{
+		"the paper tray is empty",
+		"the paper tray is empty",
+		"refill the paper tray",
+		"router firmware update",
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(embeddings) != 4 || len(embeddings[0]) != 64 {
+		t.Fatalf("Expected 4 embeddings of dimension 64, got %d", len(embeddings))
+	}
+
+	if !slices.Equal(embeddings[0], embeddings[1]) {
+		t.Fatal("Expected the same text to have the same embedding")
+	}
+
+	related := cosineSimilarity(embeddings[0], embeddings[2])
+	unrelated := cosineSimilarity(embeddings[0], embeddings[3])
+
+	if related <= unrelated {
+		t.Fatalf("Expected texts sharing terms to be more similar, got %f <= %f", related, unrelated)
+	}
+
+	if _, err := NewHashEmbedder(0).Embed(context.Background(), []string{"text"}); err == nil {
+		t.Fatal("expected error for zero dimension, but got nil")
+	}
+}
@@ -0,0 +1,221 @@
+package ragstore
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+)
+
+// embeddingFormats lists the supported embedding storage formats
+var embeddingFormats = []string{
+	EMBEDDING_FORMAT_JSON,
+	EMBEDDING_FORMAT_BINARY,
+}
+
+// validateEmbeddingFormat checks the format is one of the supported ones
+func validateEmbeddingFormat(format string) error {
+	if !slices.Contains(embeddingFormats, format) {
+		return errors.New("unsupported embedding format: " + format)
+	}
+
+	return nil
+}
+
+// embeddingBinaryMagic prefixes the binary embeddings, telling them apart
+// from the JSON ones. A JSON value never starts with a NUL byte. The last
+// byte is the version of the binary format.
+const embeddingBinaryMagic = "\x00RSE\x01"
+
+// embeddingIsBinary checks if a stored embedding value is in the binary format
+func embeddingIsBinary(value string) bool {
+	return strings.HasPrefix(value, embeddingBinaryMagic)
+}
+
+// embeddingToBinary encodes the embedding as little-endian float32 values,
+// after the binary format prefix
+func embeddingToBinary(embedding []float32) []byte {
+	encoded := make([]byte, len(embeddingBinaryMagic)+len(embedding)*4)
+	values := encoded[copy(encoded, embeddingBinaryMagic):]
+
+	for i, value := range embedding {
+		binary.LittleEndian.PutUint32(values[i*4:], math.Float32bits(value))
+	}
+
+	return encoded
+}
+
+// embeddingFromBinary decodes little-endian float32 values, after
+// the binary format prefix
+func embeddingFromBinary(encoded []byte) ([]float32, error) {
+	if !embeddingIsBinary(string(encoded)) {
+		return []float32{}, errors.New("embedding: binary format prefix is missing")
+	}
+
+	values := encoded[len(embeddingBinaryMagic):]
+
+	if len(values)%4 != 0 {
+		return []float32{}, errors.New("embedding: binary length is not a multiple of 4")
+	}
+
+	embedding := make([]float32, len(values)/4)
+
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(values[i*4:]))
+	}
+
+	return embedding, nil
+}
+
+// embeddingToJSON encodes the embedding as a JSON array
+func embeddingToJSON(embedding []float32) (string, error) {
+	encoded, err := json.Marshal(embedding)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+// embeddingFromString decodes a stored embedding value, which is in the
+// binary format when it has its prefix, and in the JSON format otherwise
+func embeddingFromString(value string) ([]float32, error) {
+	if embeddingIsBinary(value) {
+		return embeddingFromBinary([]byte(value))
+	}
+
+	if value == "" || value == "null" {
+		return []float32{}, nil
+	}
+
+	var embedding []float32
+
+	if err := json.Unmarshal([]byte(value), &embedding); err != nil {
+		return []float32{}, err
+	}
+
+	return embedding, nil
+}
+
+// embeddingToStoreValue converts a stored embedding value to the value
+// written to the database in the given format
+func embeddingToStoreValue(format string, value string) (any, error) {
+	embedding, err := embeddingFromString(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if format == EMBEDDING_FORMAT_BINARY {
+		return embeddingToBinary(embedding), nil
+	}
+
+	if len(embedding) < 1 {
+		return value, nil
+	}
+
+	return embeddingToJSON(embedding)
+}
+
+// chunkRecord converts chunk data to a database record, encoding the
+// embedding in the store embedding format, along with its quantized copy
+func (st *store) chunkRecord(data map[string]string) (goqu.Record, error) {
+	record := goqu.Record{}
+
+	for key, value := range data {
+		record[key] = value
+	}
+
+	value, exists := data[COLUMN_EMBEDDING]
+
+	if !exists {
+		return record, nil
+	}
+
+	storeValue, err := embeddingToStoreValue(st.embeddingFormat, value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	record[COLUMN_EMBEDDING] = storeValue
+
+	if st.quantization != QUANTIZATION_NONE {
+		embedding, err := embeddingFromString(value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		record[COLUMN_EMBEDDING_QUANTIZED] = quantizedToStoreValue(quantizeEmbedding(st.quantization, embedding))
+	} else if _, exists := data[COLUMN_EMBEDDING_QUANTIZED]; exists {
+		record[COLUMN_EMBEDDING_QUANTIZED] = nil // stale, once the embedding is written
+	}
+
+	return record, nil
+}
+
+// embeddingPrepare validates an embedding kept apart from the chunk
+// embeddings, i.e. staged by ReembedAll or of a named vector space, the
+// way chunk writes do, except for the configured dimension and model,
+// which belong to the chunk embeddings. Instead, the embedding must have
+// the dimension, which is taken from it when 0.
+func (st *store) embeddingPrepare(embedding []float32, dimension *int) ([]float32, error) {
+	if len(embedding) < 1 {
+		return nil, errors.New("embedding is empty")
+	}
+
+	if *dimension == 0 {
+		*dimension = len(embedding)
+	}
+
+	if len(embedding) != *dimension {
+		return nil, errors.New("embedding dimension " + strconv.Itoa(len(embedding)) + " does not match the dimension " + strconv.Itoa(*dimension) + " of the other embeddings")
+	}
+
+	if st.embeddingRejectNonFinite && !embeddingIsFinite(embedding) {
+		return nil, errors.New("embedding contains NaN or infinite values")
+	}
+
+	if st.normalizeEmbeddings {
+		return NormalizeEmbedding(embedding), nil
+	}
+
+	return embedding, nil
+}
+
+// embeddingStoreValue encodes the embedding in the given format
+func embeddingStoreValue(format string, embedding []float32) (any, error) {
+	if format == EMBEDDING_FORMAT_BINARY {
+		return embeddingToBinary(embedding), nil
+	}
+
+	return embeddingToJSON(embedding)
+}
+
+// quantizedToStoreValue returns the value written to the database for
+// a quantized embedding, NULL when there is none
+func quantizedToStoreValue(quantized []byte) any {
+	if len(quantized) < 1 {
+		return nil
+	}
+
+	return quantized
+}
+
+// embeddingIsFinite checks the embedding contains no NaN or infinite values
+func embeddingIsFinite(embedding []float32) bool {
+	for _, value := range embedding {
+		if math.IsNaN(float64(value)) || math.IsInf(float64(value), 0) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,62 @@
+package ragstore
+
+import (
+	"errors"
+	"slices"
+)
+
+// fusions lists the supported fusion methods
+var fusions = []string{
+	FUSION_RRF,
+	FUSION_WEIGHTED,
+}
+
+// validateFusion checks the fusion is one of the supported ones
+func validateFusion(fusion string) error {
+	if !slices.Contains(fusions, fusion) {
+		return errors.New("unsupported fusion: " + fusion)
+	}
+
+	return nil
+}
+
+// fuseReciprocalRank scores each ID by the sum of 1 / (k + rank) over
+// the ranked lists it is found in
+func fuseReciprocalRank(k int, rankings ...[]scoredID) map[string]float64 {
+	fused := map[string]float64{}
+
+	for _, ranked := range rankings {
+		for i, item := range ranked {
+			fused[item.id] += 1 / float64(k+i+1)
+		}
+	}
+
+	return fused
+}
+
+// fuseWeighted scores each ID by the weighted sum of its min-max
+// normalised scores over the ranked lists it is found in
+func fuseWeighted(weights []float64, rankings ...[]scoredID) map[string]float64 {
+	fused := map[string]float64{}
+
+	for i, ranked := range rankings {
+		if len(ranked) < 1 {
+			continue
+		}
+
+		// ranked lists are sorted highest score first
+		highest, lowest := ranked[0].score, ranked[len(ranked)-1].score
+
+		for _, item := range ranked {
+			normalised := 1.0
+
+			if highest > lowest {
+				normalised = (item.score - lowest) / (highest - lowest)
+			}
+
+			fused[item.id] += weights[i] * normalised
+		}
+	}
+
+	return fused
+}
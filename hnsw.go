@@ -0,0 +1,439 @@
+package ragstore
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// hnswIndex is an in-memory Hierarchical Navigable Small World graph,
+// used for approximate nearest neighbour search over chunk embeddings.
+//
+// Removed nodes are kept as tombstones, so the graph stays navigable.
+// They are never returned by searches, and the graph is rebuilt once
+// tombstones outnumber the live nodes.
+type hnswIndex struct {
+	mu sync.RWMutex
+
+	metric         string
+	m              int
+	mMax0          int
+	efConstruction int
+	efSearch       int
+	levelFactor    float64
+	random         *rand.Rand
+
+	dimension  int
+	nodes      []*hnswNode
+	ids        map[string]int
+	entryPoint int
+	maxLevel   int
+	deleted    int
+}
+
+// hnswNode is a single vector in the graph with its neighbours per level
+type hnswNode struct {
+	id        string
+	vector    []float32
+	neighbors [][]int
+	deleted   bool
+}
+
+// hnswCandidate is a node index together with its distance to the query
+type hnswCandidate struct {
+	index    int
+	distance float64
+}
+
+func newHnswIndex(metric string, m int, efConstruction int, efSearch int) *hnswIndex {
+	return &hnswIndex{
+		metric:         metric,
+		m:              m,
+		mMax0:          m * 2,
+		efConstruction: efConstruction,
+		efSearch:       efSearch,
+		levelFactor:    1 / math.Log(float64(m)),
+		random:         rand.New(rand.NewPCG(uint64(m), uint64(efConstruction))),
+		ids:            map[string]int{},
+		entryPoint:     -1,
+	}
+}
+
+// Len returns the number of live (not removed) vectors in the index
+func (h *hnswIndex) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.ids)
+}
+
+// Has checks if the index holds a live vector for the ID
+func (h *hnswIndex) Has(id string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	_, exists := h.ids[id]
+
+	return exists
+}
+
+// Upsert adds the vector for the ID, replacing any previous one.
+// An empty vector removes the ID from the index.
+func (h *hnswIndex) Upsert(id string, vector []float32) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(vector) < 1 {
+		h.remove(id)
+		return nil
+	}
+
+	if index, exists := h.ids[id]; exists {
+		if slices.Equal(h.nodes[index].vector, vector) {
+			return nil
+		}
+
+		h.remove(id)
+	}
+
+	// without live nodes the tombstones are dropped, so the vector may be
+	// of another dimension than the vectors removed before
+	if len(h.ids) == 0 {
+		h.reset()
+		h.dimension = len(vector)
+	}
+
+	if len(vector) != h.dimension {
+		return errors.New("hnsw: embedding dimension does not match the index")
+	}
+
+	h.insert(id, slices.Clone(vector))
+
+	h.compactIfNeeded()
+
+	return nil
+}
+
+// Remove removes the ID from the index
+func (h *hnswIndex) Remove(id string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(id)
+
+	h.compactIfNeeded()
+}
+
+// Replace swaps the graph for the graph of the other index, which must not
+// be used afterwards
+func (h *hnswIndex) Replace(other *hnswIndex) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.dimension = other.dimension
+	h.nodes = other.nodes
+	h.ids = other.ids
+	h.entryPoint = other.entryPoint
+	h.maxLevel = other.maxLevel
+	h.deleted = other.deleted
+}
+
+// Search returns up to k IDs nearest to the query, best first, scored the
+// same way as an exact search. When allow is not nil only the IDs it
+// accepts are returned, so fewer than k IDs may be found.
+func (h *hnswIndex) Search(query []float32, k int, allow func(id string) bool) ([]scoredID, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if h.entryPoint < 0 || len(h.ids) == 0 {
+		return []scoredID{}, nil
+	}
+
+	if len(query) != h.dimension {
+		return nil, errors.New("hnsw: query embedding dimension does not match the index")
+	}
+
+	ef := max(h.efSearch, k)
+
+	entryPoint := h.entryPoint
+	entryDistance := h.distance(query, h.nodes[entryPoint].vector)
+
+	for level := h.maxLevel; level > 0; level-- {
+		entryPoint, entryDistance = h.searchGreedy(query, entryPoint, entryDistance, level)
+	}
+
+	found := h.searchLayer(query, []hnswCandidate{{index: entryPoint, distance: entryDistance}}, ef, 0)
+
+	results := []scoredID{}
+
+	for _, candidate := range found {
+		node := h.nodes[candidate.index]
+
+		if node.deleted {
+			continue
+		}
+
+		if allow != nil && !allow(node.id) {
+			continue
+		}
+
+		results = append(results, scoredID{id: node.id, score: -candidate.distance})
+
+		if len(results) >= k {
+			break
+		}
+	}
+
+	return results, nil
+}
+
+// distance returns the distance of two vectors, lower is nearer
+func (h *hnswIndex) distance(a, b []float32) float64 {
+	return -vectorScore(h.metric, a, b)
+}
+
+// insert adds a new node to the graph, the lock must be held
+func (h *hnswIndex) insert(id string, vector []float32) {
+	level := int(math.Floor(-math.Log(1-h.random.Float64()) * h.levelFactor))
+
+	node := &hnswNode{
+		id:        id,
+		vector:    vector,
+		neighbors: make([][]int, level+1),
+	}
+
+	index := len(h.nodes)
+	h.nodes = append(h.nodes, node)
+	h.ids[id] = index
+
+	if h.entryPoint < 0 {
+		h.entryPoint = index
+		h.maxLevel = level
+		return
+	}
+
+	entryPoint := h.entryPoint
+	entryDistance := h.distance(vector, h.nodes[entryPoint].vector)
+
+	for l := h.maxLevel; l > level; l-- {
+		entryPoint, entryDistance = h.searchGreedy(vector, entryPoint, entryDistance, l)
+	}
+
+	entryPoints := []hnswCandidate{{index: entryPoint, distance: entryDistance}}
+
+	for l := min(level, h.maxLevel); l >= 0; l-- {
+		found := h.searchLayer(vector, entryPoints, h.efConstruction, l)
+
+		neighbors := found
+		if len(neighbors) > h.m {
+			neighbors = neighbors[:h.m]
+		}
+
+		node.neighbors[l] = make([]int, 0, len(neighbors))
+
+		for _, neighbor := range neighbors {
+			node.neighbors[l] = append(node.neighbors[l], neighbor.index)
+			h.connect(neighbor.index, index, l)
+		}
+
+		entryPoints = found
+	}
+
+	if level > h.maxLevel {
+		h.maxLevel = level
+		h.entryPoint = index
+	}
+}
+
+// connect links the node to the neighbour on the level, pruning the
+// farthest links when the node has too many
+func (h *hnswIndex) connect(index int, neighbor int, level int) {
+	node := h.nodes[index]
+	node.neighbors[level] = append(node.neighbors[level], neighbor)
+
+	maxConnections := h.m
+	if level == 0 {
+		maxConnections = h.mMax0
+	}
+
+	if len(node.neighbors[level]) <= maxConnections {
+		return
+	}
+
+	candidates := make([]hnswCandidate, len(node.neighbors[level]))
+
+	for i, n := range node.neighbors[level] {
+		candidates[i] = hnswCandidate{index: n, distance: h.distance(node.vector, h.nodes[n].vector)}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	pruned := make([]int, maxConnections)
+
+	for i := range pruned {
+		pruned[i] = candidates[i].index
+	}
+
+	node.neighbors[level] = pruned
+}
+
+// searchGreedy walks the level towards the query, one nearest neighbour at a time
+func (h *hnswIndex) searchGreedy(query []float32, entryPoint int, entryDistance float64, level int) (int, float64) {
+	for changed := true; changed; {
+		changed = false
+
+		for _, neighbor := range h.nodes[entryPoint].neighbors[level] {
+			distance := h.distance(query, h.nodes[neighbor].vector)
+
+			if distance < entryDistance {
+				entryPoint = neighbor
+				entryDistance = distance
+				changed = true
+			}
+		}
+	}
+
+	return entryPoint, entryDistance
+}
+
+// searchLayer returns up to ef nodes nearest to the query on the level,
+// nearest first
+func (h *hnswIndex) searchLayer(query []float32, entryPoints []hnswCandidate, ef int, level int) []hnswCandidate {
+	visited := map[int]bool{}
+	candidates := &hnswMinHeap{}
+	results := &hnswMaxHeap{}
+
+	for _, entryPoint := range entryPoints {
+		visited[entryPoint.index] = true
+		heap.Push(candidates, entryPoint)
+		heap.Push(results, entryPoint)
+
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+
+	for candidates.Len() > 0 {
+		candidate := heap.Pop(candidates).(hnswCandidate)
+
+		if results.Len() >= ef && candidate.distance > (*results)[0].distance {
+			break
+		}
+
+		for _, neighbor := range h.nodes[candidate.index].neighbors[level] {
+			if visited[neighbor] {
+				continue
+			}
+
+			visited[neighbor] = true
+
+			distance := h.distance(query, h.nodes[neighbor].vector)
+
+			if results.Len() < ef || distance < (*results)[0].distance {
+				heap.Push(candidates, hnswCandidate{index: neighbor, distance: distance})
+				heap.Push(results, hnswCandidate{index: neighbor, distance: distance})
+
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+
+	found := make([]hnswCandidate, results.Len())
+
+	for i := len(found) - 1; i >= 0; i-- {
+		found[i] = heap.Pop(results).(hnswCandidate)
+	}
+
+	return found
+}
+
+// remove tombstones the node of the ID, the lock must be held
+func (h *hnswIndex) remove(id string) {
+	index, exists := h.ids[id]
+
+	if !exists {
+		return
+	}
+
+	h.nodes[index].deleted = true
+	h.deleted++
+
+	delete(h.ids, id)
+}
+
+// compactIfNeeded rebuilds the graph from the live nodes once tombstones
+// outnumber them, the lock must be held
+func (h *hnswIndex) compactIfNeeded() {
+	if h.deleted < 64 || h.deleted < len(h.ids) {
+		return
+	}
+
+	nodes := h.nodes
+
+	h.reset()
+
+	for _, node := range nodes {
+		if !node.deleted {
+			h.insert(node.id, node.vector)
+		}
+	}
+}
+
+// reset empties the graph, the lock must be held
+func (h *hnswIndex) reset() {
+	h.nodes = nil
+	h.ids = map[string]int{}
+	h.entryPoint = -1
+	h.maxLevel = 0
+	h.deleted = 0
+}
+
+// ============================================================================
+// == HEAPS
+// ============================================================================
+
+// hnswMinHeap is a min-heap on distance
+type hnswMinHeap []hnswCandidate
+
+func (h hnswMinHeap) Len() int           { return len(h) }
+func (h hnswMinHeap) Less(i, j int) bool { return h[i].distance < h[j].distance }
+func (h hnswMinHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *hnswMinHeap) Push(x any) {
+	*h = append(*h, x.(hnswCandidate))
+}
+
+func (h *hnswMinHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
+
+// hnswMaxHeap is a max-heap on distance
+type hnswMaxHeap []hnswCandidate
+
+func (h hnswMaxHeap) Len() int           { return len(h) }
+func (h hnswMaxHeap) Less(i, j int) bool { return h[i].distance > h[j].distance }
+func (h hnswMaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *hnswMaxHeap) Push(x any) {
+	*h = append(*h, x.(hnswCandidate))
+}
+
+func (h *hnswMaxHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}
@@ -0,0 +1,148 @@
+package ragstore
+
+import (
+	"encoding/gob"
+	"errors"
+	"io"
+	"math/rand/v2"
+)
+
+// hnswSnapshotVersion is increased whenever the snapshot layout changes
+const hnswSnapshotVersion = 1
+
+// hnswSnapshot is the serialised form of an HNSW index
+type hnswSnapshot struct {
+	Version        int
+	Metric         string
+	M              int
+	EfConstruction int
+	Dimension      int
+	EntryPoint     int
+	MaxLevel       int
+	Nodes          []hnswSnapshotNode
+
+	// Watermark is the latest chunk updated_at value when the snapshot
+	// was taken, chunks changed since are replayed on load
+	Watermark string
+}
+
+// hnswSnapshotNode is the serialised form of an HNSW node
+type hnswSnapshotNode struct {
+	ID        string
+	Vector    []float32
+	Neighbors [][]int
+	Deleted   bool
+}
+
+// WriteSnapshot serialises the index, tagged with the watermark
+func (h *hnswIndex) WriteSnapshot(w io.Writer, watermark string) error {
+	h.mu.RLock()
+
+	snapshot := hnswSnapshot{
+		Version:        hnswSnapshotVersion,
+		Metric:         h.metric,
+		M:              h.m,
+		EfConstruction: h.efConstruction,
+		Dimension:      h.dimension,
+		EntryPoint:     h.entryPoint,
+		MaxLevel:       h.maxLevel,
+		Nodes:          make([]hnswSnapshotNode, len(h.nodes)),
+		Watermark:      watermark,
+	}
+
+	for i, node := range h.nodes {
+		neighbors := make([][]int, len(node.neighbors))
+
+		for level := range node.neighbors {
+			neighbors[level] = append([]int{}, node.neighbors[level]...)
+		}
+
+		snapshot.Nodes[i] = hnswSnapshotNode{
+			ID:        node.id,
+			Vector:    node.vector,
+			Neighbors: neighbors,
+			Deleted:   node.deleted,
+		}
+	}
+
+	h.mu.RUnlock()
+
+	return gob.NewEncoder(w).Encode(snapshot)
+}
+
+// ReadSnapshot replaces the index with a serialised one, and returns its
+// watermark. The snapshot must have been taken with the same metric and
+// build parameters.
+func (h *hnswIndex) ReadSnapshot(r io.Reader) (string, error) {
+	snapshot := hnswSnapshot{}
+
+	if err := gob.NewDecoder(r).Decode(&snapshot); err != nil {
+		return "", err
+	}
+
+	if snapshot.Version != hnswSnapshotVersion {
+		return "", errors.New("hnsw snapshot: unsupported version")
+	}
+
+	if snapshot.Metric != h.metric || snapshot.M != h.m || snapshot.EfConstruction != h.efConstruction {
+		return "", errors.New("hnsw snapshot: taken with different index parameters")
+	}
+
+	nodes := make([]*hnswNode, len(snapshot.Nodes))
+	ids := map[string]int{}
+	deleted := 0
+
+	for i, node := range snapshot.Nodes {
+		for _, neighbors := range node.Neighbors {
+			for _, neighbor := range neighbors {
+				if neighbor < 0 || neighbor >= len(snapshot.Nodes) {
+					return "", errors.New("hnsw snapshot: neighbour out of range")
+				}
+			}
+		}
+
+		nodes[i] = &hnswNode{
+			id:        node.ID,
+			vector:    node.Vector,
+			neighbors: node.Neighbors,
+			deleted:   node.Deleted,
+		}
+
+		if node.Deleted {
+			deleted++
+		} else {
+			ids[node.ID] = i
+		}
+	}
+
+	if snapshot.EntryPoint >= len(nodes) || (len(nodes) > 0 && snapshot.EntryPoint < 0) {
+		return "", errors.New("hnsw snapshot: entry point out of range")
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.dimension = snapshot.Dimension
+	h.nodes = nodes
+	h.ids = ids
+	h.entryPoint = snapshot.EntryPoint
+	h.maxLevel = snapshot.MaxLevel
+	h.deleted = deleted
+	h.random = rand.New(rand.NewPCG(uint64(h.m), uint64(len(nodes))))
+
+	return snapshot.Watermark, nil
+}
+
+// IDs returns the IDs of the live vectors in the index
+func (h *hnswIndex) IDs() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ids := make([]string, 0, len(h.ids))
+
+	for id := range h.ids {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
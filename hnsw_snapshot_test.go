@@ -0,0 +1,171 @@
+package ragstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dromara/carbon/v2"
+	_ "modernc.org/sqlite"
+)
+
+func TestStore_HNSWSnapshotSaveAndLoad(t *testing.T) {
+	db := initDB(":memory:")
+	snapshotPath := filepath.Join(t.TempDir(), "hnsw.snapshot")
+
+	options := NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+		HNSWEnabled:            true,
+		HNSWSnapshotPath:       snapshotPath,
+	}
+
+	storeSaved, err := NewStore(options)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunkKept := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("kept").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	chunkDeleted := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("deleted").
+		SetEmbedding([]float32{0.0, 1.0})
+
+	chunkSoftDeleted := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(3).
+		SetContent("soft deleted").
+		SetEmbedding([]float32{-1.0, 0.0})
+
+	for _, chunk := range []ChunkInterface{chunkKept, chunkDeleted, chunkSoftDeleted} {
+		if err := storeSaved.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	if err := storeSaved.HNSWSnapshotSave(context.Background(), ""); err != nil {
+		t.Fatal("unexpected error saving snapshot:", err)
+	}
+
+	if _, err := os.Stat(snapshotPath); err != nil {
+		t.Fatal("Expected snapshot file to exist:", err)
+	}
+
+	// Changes after the snapshot
+	chunkCreated := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(4).
+		SetContent("created").
+		SetEmbedding([]float32{0.0, -1.0})
+
+	if err := storeSaved.ChunkCreate(context.Background(), chunkCreated); err != nil {
+		t.Fatal("unexpected error creating chunk:", err)
+	}
+
+	if err := storeSaved.ChunkDelete(context.Background(), chunkDeleted); err != nil {
+		t.Fatal("unexpected error on delete:", err)
+	}
+
+	if err := storeSaved.ChunkSoftDelete(context.Background(), chunkSoftDeleted); err != nil {
+		t.Fatal("unexpected error on soft delete:", err)
+	}
+
+	// A row older than the snapshot, which a warm start does not replay
+	past := carbon.Now(carbon.UTC).SubHours(1).ToDateTimeString()
+
+	_, err = db.Exec(`INSERT INTO document_chunk_table (id, document_id, chunk_index, content, embedding, created_at, updated_at, soft_deleted_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		"not-replayed", testDocument_O1, 5, "not replayed", "[0.5,0.5]", past, past, "9999-12-31 23:59:59")
+
+	if err != nil {
+		t.Fatal("unexpected error inserting row:", err)
+	}
+
+	storeWarm, err := NewStore(options)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	index := storeWarm.(*store).hnsw
+
+	if !index.Has(chunkKept.ID()) {
+		t.Fatal("Expected kept chunk to be loaded from the snapshot")
+	}
+
+	if !index.Has(chunkCreated.ID()) {
+		t.Fatal("Expected chunk created after the snapshot to be replayed")
+	}
+
+	if index.Has(chunkDeleted.ID()) {
+		t.Fatal("Deleted chunk MUST NOT be in the index")
+	}
+
+	if index.Has(chunkSoftDeleted.ID()) {
+		t.Fatal("Soft deleted chunk MUST NOT be in the index")
+	}
+
+	if index.Has("not-replayed") {
+		t.Fatal("Expected only rows changed since the snapshot to be replayed")
+	}
+}
+
+func TestStore_HNSWSnapshotMismatchRebuilds(t *testing.T) {
+	db := initDB(":memory:")
+	snapshotPath := filepath.Join(t.TempDir(), "hnsw.snapshot")
+
+	storeSaved, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+		HNSWEnabled:            true,
+		HNSWSnapshotPath:       snapshotPath,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	if err := storeSaved.ChunkCreate(context.Background(), chunk); err != nil {
+		t.Fatal("unexpected error creating chunk:", err)
+	}
+
+	if err := storeSaved.HNSWSnapshotSave(context.Background(), ""); err != nil {
+		t.Fatal("unexpected error saving snapshot:", err)
+	}
+
+	// Another metric does not match the snapshot, so the index is rebuilt
+	storeRebuilt, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		HNSWEnabled:            true,
+		HNSWSnapshotPath:       snapshotPath,
+		DistanceMetric:         DISTANCE_METRIC_EUCLIDEAN,
+		Logger:                 testDiscardLogger(),
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !storeRebuilt.(*store).hnsw.Has(chunk.ID()) {
+		t.Fatal("Expected the rebuilt index to contain the chunk")
+	}
+}
@@ -0,0 +1,348 @@
+package ragstore
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func randomEmbedding(random *rand.Rand, dimension int) []float32 {
+	embedding := make([]float32, dimension)
+
+	for i := range embedding {
+		embedding[i] = random.Float32()*2 - 1
+	}
+
+	return embedding
+}
+
+func TestHnswIndex_UpsertRemoveSearch(t *testing.T) {
+	index := newHnswIndex(DISTANCE_METRIC_EUCLIDEAN, 4, 16, 16)
+
+	if err := index.Upsert("a", []float32{0, 0}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := index.Upsert("b", []float32{1, 1}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := index.Upsert("c", []float32{5, 5}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := index.Upsert("d", []float32{1, 2, 3}); err == nil {
+		t.Fatal("expected error for dimension mismatch, but got nil")
+	}
+
+	results, err := index.Search([]float32{0.9, 0.9}, 2, nil)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 2 || results[0].id != "b" || results[1].id != "a" {
+		t.Fatalf("Unexpected results: %v", results)
+	}
+
+	index.Remove("b")
+
+	if index.Len() != 2 {
+		t.Fatalf("Expected 2 live vectors, got %d", index.Len())
+	}
+
+	results, err = index.Search([]float32{0.9, 0.9}, 1, nil)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].id != "a" {
+		t.Fatalf("Removed vector MUST NOT be returned, got %v", results)
+	}
+
+	results, err = index.Search([]float32{0.9, 0.9}, 1, func(id string) bool { return id == "c" })
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].id != "c" {
+		t.Fatalf("Expected only the allowed vector, got %v", results)
+	}
+}
+
+func TestHnswIndex_UpsertAfterRemovingAll(t *testing.T) {
+	index := newHnswIndex(DISTANCE_METRIC_COSINE, 4, 16, 16)
+
+	if err := index.Upsert("a", []float32{1, 0}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	index.Remove("a")
+
+	// the emptied index accepts another dimension
+	if err := index.Upsert("b", []float32{0, 1, 0}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := index.Upsert("c", []float32{0, 0, 1}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	results, err := index.Search([]float32{0, 1, 0}, 2, nil)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 2 || results[0].id != "b" {
+		t.Fatalf("Unexpected results: %v", results)
+	}
+}
+
+func TestStore_ChunkSearchSimilarHnswRecall(t *testing.T) {
+	store := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+	})
+
+	random := rand.New(rand.NewPCG(1, 2))
+
+	for i := 0; i < 1000; i++ {
+		chunk := NewChunk().
+			SetDocumentID(testDocument_O1).
+			SetChunkIndex(i).
+			SetContent("chunk").
+			SetEmbedding(randomEmbedding(random, 16))
+
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	queries := 20
+	limit := 10
+	hits := 0
+
+	for i := 0; i < queries; i++ {
+		queryEmbedding := randomEmbedding(random, 16)
+
+		exact, err := store.ChunkSearchSimilar(context.Background(), queryEmbedding, ChunkSearchOptions{
+			Limit: limit,
+			Exact: true,
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		approximate, err := store.ChunkSearchSimilar(context.Background(), queryEmbedding, ChunkSearchOptions{
+			Limit: limit,
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		exactIDs := map[string]bool{}
+
+		for _, result := range exact {
+			exactIDs[result.Chunk.ID()] = true
+		}
+
+		for _, result := range approximate {
+			if exactIDs[result.Chunk.ID()] {
+				hits++
+			}
+		}
+	}
+
+	recall := float64(hits) / float64(queries*limit)
+
+	if recall < 0.9 {
+		t.Fatalf("Expected recall@%d of at least 0.9, got %.2f", limit, recall)
+	}
+}
+
+func TestStore_ChunkSearchSimilarHnswFiltered(t *testing.T) {
+	storeHnsw := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+	})
+
+	random := rand.New(rand.NewPCG(3, 4))
+
+	for i := 0; i < 500; i++ {
+		chunk := NewChunk().
+			SetDocumentID(testDocument_O1).
+			SetChunkIndex(i).
+			SetContent("chunk").
+			SetEmbedding(randomEmbedding(random, 16))
+
+		parity := "even"
+		if i%2 == 1 {
+			parity = "odd"
+		}
+
+		if err := chunk.SetMeta("parity", parity); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if err := storeHnsw.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	// the statements are logged, to check which chunks the search reads
+	var statements bytes.Buffer
+
+	log.SetOutput(&statements)
+	defer log.SetOutput(os.Stderr)
+
+	storeHnsw.(*store).debugEnabled = true
+
+	results, err := storeHnsw.ChunkSearchSimilar(context.Background(), randomEmbedding(random, 16), ChunkSearchOptions{
+		Limit: 10,
+		Query: ChunkQuery().SetMetaEquals("parity", "even"),
+	})
+
+	storeHnsw.(*store).debugEnabled = false
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 10 {
+		t.Fatal("Expected 10 results, got", len(results))
+	}
+
+	for _, result := range results {
+		if parity, _ := result.Chunk.Meta("parity"); parity != "even" {
+			t.Fatal("Expected only chunks matching the query, got", parity)
+		}
+	}
+
+	if !strings.Contains(statements.String(), `"id" IN (`) {
+		t.Fatal("Expected the candidate chunks to be filtered by the query")
+	}
+
+	for _, statement := range strings.Split(statements.String(), "\n") {
+		if strings.Contains(statement, `FROM "document_chunk_table"`) && !strings.Contains(statement, `"id" IN (`) {
+			t.Fatal("Expected only the candidate chunks to be read, got", statement)
+		}
+	}
+}
+
+func TestStore_ChunkSearchSimilarHnswIncremental(t *testing.T) {
+	store := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+	})
+
+	chunk1 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	chunk2 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("chunk 2").
+		SetEmbedding([]float32{0.0, 1.0})
+
+	chunk3 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(3).
+		SetContent("chunk 3").
+		SetEmbedding([]float32{-1.0, 0.0})
+
+	for _, chunk := range []ChunkInterface{chunk1, chunk2, chunk3} {
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	search := func() []ChunkSearchResult {
+		results, err := store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{
+			Limit: 1,
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		return results
+	}
+
+	if results := search(); len(results) != 1 || results[0].Chunk.ID() != chunk1.ID() {
+		t.Fatal("Expected chunk 1 to be the nearest")
+	}
+
+	// Update moves chunk 3 nearest to the query
+	chunk3.SetEmbedding([]float32{0.9, 0.1})
+
+	if err := store.ChunkUpdate(context.Background(), chunk3); err != nil {
+		t.Fatal("unexpected error on update:", err)
+	}
+
+	if err := store.ChunkDelete(context.Background(), chunk1); err != nil {
+		t.Fatal("unexpected error on delete:", err)
+	}
+
+	if results := search(); len(results) != 1 || results[0].Chunk.ID() != chunk3.ID() {
+		t.Fatal("Expected updated chunk 3 to be the nearest")
+	}
+
+	if err := store.ChunkSoftDelete(context.Background(), chunk3); err != nil {
+		t.Fatal("unexpected error on soft delete:", err)
+	}
+
+	if results := search(); len(results) != 1 || results[0].Chunk.ID() != chunk2.ID() {
+		t.Fatal("Soft deleted chunk MUST NOT be returned")
+	}
+}
+
+func TestNewStoreBuildsHnswIndex(t *testing.T) {
+	db := initDB(":memory:")
+
+	storePlain, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	if err := storePlain.ChunkCreate(context.Background(), chunk); err != nil {
+		t.Fatal("unexpected error creating chunk:", err)
+	}
+
+	storeHnsw, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		HNSWEnabled:            true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !storeHnsw.(*store).hnsw.Has(chunk.ID()) {
+		t.Fatal("Expected existing chunk to be in the HNSW index")
+	}
+}
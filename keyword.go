@@ -0,0 +1,88 @@
+package ragstore
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters, the commonly used defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// keywordConnectors join the parts of compound terms,
+// i.e. product codes (AB-1234), identifiers (ERR_TIMEOUT) or versions (1.2.3)
+const keywordConnectors = "-_."
+
+// keywordTermMaxLength is the longest indexed term, longer ones are skipped
+const keywordTermMaxLength = 255
+
+// keywordTerms splits the text into lower cased terms. Compound terms are
+// kept whole, followed by their parts, so that both "ab-1234" and "1234"
+// match the text "AB-1234".
+func keywordTerms(text string) []string {
+	terms := []string{}
+
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !keywordIsTermRune(r)
+	})
+
+	for _, token := range tokens {
+		token = strings.Trim(token, keywordConnectors)
+
+		if token == "" || len(token) > keywordTermMaxLength {
+			continue
+		}
+
+		terms = append(terms, token)
+
+		if !strings.ContainsAny(token, keywordConnectors) {
+			continue
+		}
+
+		parts := strings.FieldsFunc(token, func(r rune) bool {
+			return strings.ContainsRune(keywordConnectors, r)
+		})
+
+		terms = append(terms, parts...)
+	}
+
+	return terms
+}
+
+// keywordIsTermRune checks if the rune is part of a term
+func keywordIsTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(keywordConnectors, r)
+}
+
+// keywordTermFrequencies counts the occurrences of each term
+func keywordTermFrequencies(terms []string) map[string]int {
+	frequencies := map[string]int{}
+
+	for _, term := range terms {
+		frequencies[term]++
+	}
+
+	return frequencies
+}
+
+// bm25IDF returns the inverse document frequency of a term found in
+// documentFrequency of the total chunks
+func bm25IDF(chunks int64, documentFrequency int64) float64 {
+	return math.Log(1 + (float64(chunks)-float64(documentFrequency)+0.5)/(float64(documentFrequency)+0.5))
+}
+
+// bm25TermScore returns the BM25 score contribution of a term found
+// termFrequency times in a chunk of chunkLength terms
+func bm25TermScore(idf float64, termFrequency int, chunkLength int, averageLength float64) float64 {
+	tf := float64(termFrequency)
+	norm := 1 - bm25B
+
+	if averageLength > 0 {
+		norm += bm25B * float64(chunkLength) / averageLength
+	}
+
+	return idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+}
@@ -0,0 +1,28 @@
+package ragstore
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestKeywordTerms(t *testing.T) {
+	terms := keywordTerms("Error ERR_CONN_RESET on part AB-1234, see v1.2.")
+
+	expected := []string{
+		"error",
+		"err_conn_reset", "err", "conn", "reset",
+		"on",
+		"part",
+		"ab-1234", "ab", "1234",
+		"see",
+		"v1.2", "v1", "2",
+	}
+
+	if !slices.Equal(terms, expected) {
+		t.Fatalf("Expected terms %v, got %v", expected, terms)
+	}
+
+	if len(keywordTerms("  ... -- ")) != 0 {
+		t.Fatal("Expected no terms for punctuation only")
+	}
+}
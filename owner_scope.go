@@ -0,0 +1,38 @@
+package ragstore
+
+import "context"
+
+// ownerScopeKey is the context key of the owner scope
+type ownerScopeKey struct{}
+
+// ownerScope is the owner scope of a context. All is set by the
+// maintenance methods, which work across all owners.
+type ownerScope struct {
+	ownerID string
+	all     bool
+}
+
+// WithOwnerScope returns a copy of the context, which scopes the store calls
+// made with it to the owner. Queries and searches only match the documents
+// and chunks of the owner, new documents and chunks are created for the
+// owner, and the records of other owners cannot be updated or deleted.
+func WithOwnerScope(ctx context.Context, ownerID string) context.Context {
+	return context.WithValue(ctx, ownerScopeKey{}, ownerScope{ownerID: ownerID})
+}
+
+// OwnerScope returns the owner the context is scoped to, if any
+func OwnerScope(ctx context.Context) (string, bool) {
+	scope, ok := ctx.Value(ownerScopeKey{}).(ownerScope)
+
+	if !ok || scope.all || scope.ownerID == "" {
+		return "", false
+	}
+
+	return scope.ownerID, true
+}
+
+// withOwnerScopeAll returns a copy of the context, which lifts the owner
+// scope for the maintenance methods
+func withOwnerScopeAll(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ownerScopeKey{}, ownerScope{all: true})
+}
@@ -0,0 +1,172 @@
+package ragstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+// quantizations lists the supported embedding quantizations
+var quantizations = []string{
+	QUANTIZATION_NONE,
+	QUANTIZATION_INT8,
+	QUANTIZATION_BINARY,
+}
+
+// quantized embedding layouts, all prefixed with the kind byte and
+// the little-endian uint32 dimension:
+//
+//   - int8: float32 minimum, float32 step, one byte per value
+//   - binary: one bit per value, set for positive values
+const (
+	quantizedKindInt8   byte = 1
+	quantizedKindBinary byte = 2
+
+	quantizedHeaderSize = 5
+)
+
+// validateQuantization checks the quantization is one of the supported ones
+func validateQuantization(quantization string) error {
+	if !slices.Contains(quantizations, quantization) {
+		return errors.New("unsupported quantization: " + quantization)
+	}
+
+	return nil
+}
+
+// quantizeEmbedding encodes the embedding with the given quantization.
+// Returns nil for no quantization or an empty embedding.
+func quantizeEmbedding(quantization string, embedding []float32) []byte {
+	if len(embedding) < 1 {
+		return nil
+	}
+
+	switch quantization {
+	case QUANTIZATION_INT8:
+		return quantizeInt8(embedding)
+	case QUANTIZATION_BINARY:
+		return quantizeBinary(embedding)
+	default:
+		return nil
+	}
+}
+
+// quantizeInt8 maps each value linearly to one of 256 steps between
+// the minimum and the maximum value of the embedding
+func quantizeInt8(embedding []float32) []byte {
+	minimum, maximum := slices.Min(embedding), slices.Max(embedding)
+	step := (maximum - minimum) / 255
+
+	encoded := quantizedHeader(quantizedKindInt8, len(embedding), 8+len(embedding))
+	encoded = binary.LittleEndian.AppendUint32(encoded, math.Float32bits(minimum))
+	encoded = binary.LittleEndian.AppendUint32(encoded, math.Float32bits(step))
+
+	for _, value := range embedding {
+		level := float32(0)
+
+		if step > 0 {
+			level = float32(math.Round(float64((value - minimum) / step)))
+		}
+
+		encoded = append(encoded, byte(min(max(level, 0), 255)))
+	}
+
+	return encoded
+}
+
+// quantizeBinary keeps the sign of each value as a single bit
+func quantizeBinary(embedding []float32) []byte {
+	encoded := quantizedHeader(quantizedKindBinary, len(embedding), (len(embedding)+7)/8)
+	encoded = append(encoded, quantizedBits(embedding)...)
+
+	return encoded
+}
+
+// quantizedHeader starts a quantized embedding of the given kind
+func quantizedHeader(kind byte, dimension int, size int) []byte {
+	encoded := make([]byte, 0, quantizedHeaderSize+size)
+	encoded = append(encoded, kind)
+
+	return binary.LittleEndian.AppendUint32(encoded, uint32(dimension))
+}
+
+// quantizedBits packs the signs of the values, set for positive values
+func quantizedBits(embedding []float32) []byte {
+	packed := make([]byte, (len(embedding)+7)/8)
+
+	for i, value := range embedding {
+		if value > 0 {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+
+	return packed
+}
+
+// quantizedScorer scores quantized embeddings against a query embedding.
+// The scores are approximations, only comparable with each other.
+type quantizedScorer struct {
+	metric      string
+	query       []float32
+	queryBits   []byte
+	dequantized []float32
+}
+
+// newQuantizedScorer creates a scorer for the query embedding
+func newQuantizedScorer(metric string, query []float32) *quantizedScorer {
+	return &quantizedScorer{
+		metric:      metric,
+		query:       query,
+		queryBits:   quantizedBits(query),
+		dequantized: make([]float32, len(query)),
+	}
+}
+
+// Score returns the approximate score of the quantized embedding. For the
+// int8 kind the embedding is dequantized and scored with the metric, for
+// the binary kind it is the negated hamming distance of the signs.
+func (s *quantizedScorer) Score(encoded []byte) (float64, error) {
+	if len(encoded) < quantizedHeaderSize {
+		return 0, errors.New("quantized embedding: too short")
+	}
+
+	dimension := int(binary.LittleEndian.Uint32(encoded[1:quantizedHeaderSize]))
+
+	if dimension != len(s.query) {
+		return 0, errors.New("embedding dimension does not match query embedding")
+	}
+
+	payload := encoded[quantizedHeaderSize:]
+
+	switch encoded[0] {
+	case quantizedKindInt8:
+		if len(payload) != 8+dimension {
+			return 0, errors.New("quantized embedding: invalid int8 length")
+		}
+
+		minimum := math.Float32frombits(binary.LittleEndian.Uint32(payload[0:]))
+		step := math.Float32frombits(binary.LittleEndian.Uint32(payload[4:]))
+
+		for i, level := range payload[8:] {
+			s.dequantized[i] = minimum + float32(level)*step
+		}
+
+		return vectorScore(s.metric, s.query, s.dequantized), nil
+	case quantizedKindBinary:
+		if len(payload) != len(s.queryBits) {
+			return 0, errors.New("quantized embedding: invalid binary length")
+		}
+
+		distance := 0
+
+		for i := range payload {
+			distance += bits.OnesCount8(payload[i] ^ s.queryBits[i])
+		}
+
+		return -float64(distance), nil
+	default:
+		return 0, errors.New("quantized embedding: unsupported kind")
+	}
+}
@@ -0,0 +1,245 @@
+package ragstore
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestQuantizedScorer(t *testing.T) {
+	query := []float32{0.5, -0.25, 1.0}
+
+	scorer := newQuantizedScorer(DISTANCE_METRIC_EUCLIDEAN, query)
+
+	score, err := scorer.Score(quantizeEmbedding(QUANTIZATION_INT8, query))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if score < -0.01 {
+		t.Fatalf("Expected int8 round trip to be close to the query, got distance %f", -score)
+	}
+
+	score, err = scorer.Score(quantizeEmbedding(QUANTIZATION_BINARY, []float32{1, 1, -1}))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if score != -2 {
+		t.Fatalf("Expected hamming distance 2, got %f", -score)
+	}
+
+	if _, err := scorer.Score(quantizeEmbedding(QUANTIZATION_INT8, []float32{1, 2})); err == nil {
+		t.Fatal("expected error for dimension mismatch, but got nil")
+	}
+
+	if quantizeEmbedding(QUANTIZATION_NONE, query) != nil {
+		t.Fatal("Expected no quantized embedding without quantization")
+	}
+}
+
+func TestStore_ChunkSearchSimilarQuantizedRecall(t *testing.T) {
+	cases := []struct {
+		quantization  string
+		rescoreFactor int
+	}{
+		{QUANTIZATION_INT8, 0},
+		{QUANTIZATION_BINARY, 10},
+	}
+
+	for _, c := range cases {
+		t.Run(c.quantization, func(t *testing.T) {
+			store := initStoreWith(t, func(opts *NewStoreOptions) {
+				opts.Quantization = c.quantization
+				opts.QuantizationRescoreFactor = c.rescoreFactor
+			})
+
+			random := rand.New(rand.NewPCG(3, 4))
+
+			for i := 0; i < 500; i++ {
+				chunk := NewChunk().
+					SetDocumentID(testDocument_O1).
+					SetChunkIndex(i).
+					SetContent("chunk").
+					SetEmbedding(randomEmbedding(random, 64))
+
+				if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+					t.Fatal("unexpected error creating chunk:", err)
+				}
+			}
+
+			queries := 20
+			limit := 10
+			hits := 0
+
+			for i := 0; i < queries; i++ {
+				queryEmbedding := randomEmbedding(random, 64)
+
+				exact, err := store.ChunkSearchSimilar(context.Background(), queryEmbedding, ChunkSearchOptions{
+					Limit: limit,
+					Exact: true,
+				})
+
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+
+				quantized, err := store.ChunkSearchSimilar(context.Background(), queryEmbedding, ChunkSearchOptions{
+					Limit: limit,
+				})
+
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+
+				if len(quantized) != limit {
+					t.Fatalf("Expected %d results, got %d", limit, len(quantized))
+				}
+
+				exactScores := map[string]float64{}
+
+				for _, result := range exact {
+					exactScores[result.Chunk.ID()] = result.Score
+				}
+
+				for _, result := range quantized {
+					score, found := exactScores[result.Chunk.ID()]
+
+					if !found {
+						continue
+					}
+
+					hits++
+
+					if score != result.Score {
+						t.Fatal("Expected rescored results to have the full precision score")
+					}
+				}
+			}
+
+			recall := float64(hits) / float64(queries*limit)
+
+			if recall < 0.8 {
+				t.Fatalf("Expected recall@%d of at least 0.8, got %.2f", limit, recall)
+			}
+		})
+	}
+}
+
+func TestStore_ChunkEmbeddingsMigrateQuantizes(t *testing.T) {
+	db := initDB(":memory:")
+
+	storePlain, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk1 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	chunk2 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("chunk 2").
+		SetEmbedding([]float32{0.0, 1.0})
+
+	for _, chunk := range []ChunkInterface{chunk1, chunk2} {
+		if err := storePlain.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	storeQuantized, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		Quantization:           QUANTIZATION_INT8,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// Chunks not quantized yet are still found
+	results, err := storeQuantized.ChunkSearchSimilar(context.Background(), []float32{0.1, 1.0}, ChunkSearchOptions{
+		Limit: 1,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunk2.ID() {
+		t.Fatal("Expected chunk 2 to be the nearest")
+	}
+
+	converted, err := storeQuantized.ChunkEmbeddingsMigrate(context.Background())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if converted != 2 {
+		t.Fatalf("Expected 2 quantized chunks, got %d", converted)
+	}
+
+	var count int
+
+	err = db.QueryRow(`SELECT COUNT(*) FROM document_chunk_table WHERE embedding_quantized IS NOT NULL`).Scan(&count)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected 2 chunks with a quantized embedding, got %d", count)
+	}
+
+	converted, err = storeQuantized.ChunkEmbeddingsMigrate(context.Background())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if converted != 0 {
+		t.Fatalf("Expected nothing left to quantize, got %d", converted)
+	}
+
+	results, err = storeQuantized.ChunkSearchSimilar(context.Background(), []float32{0.1, 1.0}, ChunkSearchOptions{
+		Limit: 1,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunk2.ID() {
+		t.Fatal("Expected chunk 2 to be the nearest")
+	}
+}
+
+func TestNewStoreUnsupportedQuantization(t *testing.T) {
+	_, err := NewStore(NewStoreOptions{
+		DB:                     initDB(":memory:"),
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		Quantization:           "int4",
+	})
+
+	if err == nil {
+		t.Fatal("expected error for unsupported quantization, but got nil")
+	}
+}
@@ -1,12 +1,20 @@
 package ragstore
 
 import (
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/gouniverse/sb"
+	"github.com/samber/lo"
 )
 
 // sqlChatTableCreate returns a SQL string for creating the chat table
 func (st *store) sqlDocumentTableCreate() string {
-	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+	builder := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
 		Table(st.tableDocument).
 		Column(sb.Column{
 			Name:       COLUMN_ID,
@@ -48,15 +56,25 @@ func (st *store) sqlDocumentTableCreate() string {
 		Column(sb.Column{
 			Name: COLUMN_SOFT_DELETED_AT,
 			Type: sb.COLUMN_TYPE_DATETIME,
-		}).
-		CreateIfNotExists()
+		})
 
-	return sql
+	for _, column := range st.sqlDocumentTableColumnsAdded() {
+		builder = builder.Column(column)
+	}
+
+	return builder.CreateIfNotExists()
 }
 
 // sqlMessageTableCreate returns a SQL string for creating the chat message table
 func (st *store) sqlDocumentChunkTableCreate() string {
-	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+	// JSON array of float32 embeddings, or little-endian float32 values
+	embeddingColumnType := sb.COLUMN_TYPE_TEXT
+
+	if st.embeddingFormat == EMBEDDING_FORMAT_BINARY {
+		embeddingColumnType = sb.COLUMN_TYPE_BLOB
+	}
+
+	builder := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
 		Table(st.tableDocumentChunk).
 		Column(sb.Column{
 			Name:       COLUMN_ID,
@@ -77,10 +95,9 @@ func (st *store) sqlDocumentChunkTableCreate() string {
 			Name: COLUMN_CONTENT,
 			Type: sb.COLUMN_TYPE_TEXT,
 		}).
-		// JSON array of float32 embeddings
 		Column(sb.Column{
 			Name: COLUMN_EMBEDDING,
-			Type: sb.COLUMN_TYPE_TEXT,
+			Type: embeddingColumnType,
 		}).
 		Column(sb.Column{
 			Name: COLUMN_CREATED_AT,
@@ -93,8 +110,358 @@ func (st *store) sqlDocumentChunkTableCreate() string {
 		Column(sb.Column{
 			Name: COLUMN_SOFT_DELETED_AT,
 			Type: sb.COLUMN_TYPE_DATETIME,
+		})
+
+	for _, column := range st.sqlDocumentChunkTableColumnsAdded() {
+		builder = builder.Column(column)
+	}
+
+	return builder.CreateIfNotExists()
+}
+
+// sqlDocumentChunkTermTableCreate returns a SQL string for creating the
+// chunk term table, the inverted index used by keyword search
+func (st *store) sqlDocumentChunkTermTableCreate() string {
+	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+		Table(st.tableDocumentChunkTerm).
+		Column(sb.Column{
+			Name:   COLUMN_CHUNK_ID,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 40,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_TERM,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: keywordTermMaxLength,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_TERM_FREQUENCY,
+			Type: sb.COLUMN_TYPE_INTEGER,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_CHUNK_LENGTH,
+			Type: sb.COLUMN_TYPE_INTEGER,
+		}).
+		CreateIfNotExists()
+
+	return sql
+}
+
+// sqlDocumentChunkTermTableIndexesCreate returns the SQL strings for
+// creating the indexes of the chunk term table
+func (st *store) sqlDocumentChunkTermTableIndexesCreate() []string {
+	builder := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+		Table(st.tableDocumentChunkTerm)
+
+	return []string{
+		builder.CreateIndex(st.tableDocumentChunkTerm+"_term_idx", COLUMN_TERM),
+		builder.CreateIndex(st.tableDocumentChunkTerm+"_chunk_id_idx", COLUMN_CHUNK_ID),
+	}
+}
+
+// sqlDocumentChunkVectorTableCreate returns a SQL string for creating the
+// chunk vector table, which holds the embeddings of the named vector spaces
+func (st *store) sqlDocumentChunkVectorTableCreate() string {
+	embeddingColumnType := sb.COLUMN_TYPE_TEXT
+
+	if st.embeddingFormat == EMBEDDING_FORMAT_BINARY {
+		embeddingColumnType = sb.COLUMN_TYPE_BLOB
+	}
+
+	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+		Table(st.tableDocumentChunkVector).
+		Column(sb.Column{
+			Name:   COLUMN_CHUNK_ID,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 40,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_VECTOR_SPACE,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: vectorSpaceMaxLength,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_EMBEDDING,
+			Type: embeddingColumnType,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_EMBEDDING_DIM,
+			Type: sb.COLUMN_TYPE_INTEGER,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_UPDATED_AT,
+			Type: sb.COLUMN_TYPE_DATETIME,
 		}).
 		CreateIfNotExists()
 
 	return sql
 }
+
+// sqlDocumentChunkVectorTableIndexesCreate returns the SQL strings for
+// creating the indexes of the chunk vector table
+func (st *store) sqlDocumentChunkVectorTableIndexesCreate() []string {
+	builder := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+		Table(st.tableDocumentChunkVector)
+
+	return []string{
+		builder.CreateIndex(st.tableDocumentChunkVector+"_vector_space_chunk_id_idx", COLUMN_VECTOR_SPACE, COLUMN_CHUNK_ID),
+		builder.CreateIndex(st.tableDocumentChunkVector+"_chunk_id_idx", COLUMN_CHUNK_ID),
+	}
+}
+
+// sqlLeaseTableCreate returns a SQL string for creating the lease table,
+// which holds a row per maintenance run in progress
+func (st *store) sqlLeaseTableCreate() string {
+	sql := sb.NewBuilder(sb.DatabaseDriverName(st.db)).
+		Table(st.tableLease).
+		Column(sb.Column{
+			Name:       COLUMN_ID,
+			Type:       sb.COLUMN_TYPE_STRING,
+			PrimaryKey: true,
+			Length:     40,
+		}).
+		Column(sb.Column{
+			Name:   COLUMN_HOLDER,
+			Type:   sb.COLUMN_TYPE_STRING,
+			Length: 40,
+		}).
+		Column(sb.Column{
+			Name: COLUMN_EXPIRES_AT,
+			Type: sb.COLUMN_TYPE_DATETIME,
+		}).
+		CreateIfNotExists()
+
+	return sql
+}
+
+// sqlDocumentTableColumnsAdded returns the columns added to the document
+// table after its first release. AutoMigrate adds them to existing tables,
+// so they are nullable.
+func (st *store) sqlDocumentTableColumnsAdded() []sb.Column {
+	return []sb.Column{
+		{
+			Name:     COLUMN_OWNER_ID,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   40,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_CONTENT_HASH,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   64,
+			Nullable: true,
+		},
+	}
+}
+
+// sqlDocumentChunkTableColumnsAdded returns the columns added to the chunk
+// table after its first release. AutoMigrate adds them to existing tables,
+// so they are nullable.
+func (st *store) sqlDocumentChunkTableColumnsAdded() []sb.Column {
+	return []sb.Column{
+		{
+			Name:     COLUMN_EMBEDDING_QUANTIZED,
+			Type:     sb.COLUMN_TYPE_BLOB,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_METAS,
+			Type:     sb.COLUMN_TYPE_TEXT,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_OWNER_ID,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   40,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_STATUS,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   40,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_EMBEDDING_MODEL,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   100,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_EMBEDDING_DIM,
+			Type:     sb.COLUMN_TYPE_INTEGER,
+			Nullable: true,
+		},
+		{
+			// staged by ReembedAll in the binary format, until the cutover
+			Name:     COLUMN_EMBEDDING_NEXT,
+			Type:     sb.COLUMN_TYPE_BLOB,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_EMBEDDING_NEXT_MODEL,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   100,
+			Nullable: true,
+		},
+		{
+			Name:     COLUMN_CONTENT_HASH,
+			Type:     sb.COLUMN_TYPE_STRING,
+			Length:   64,
+			Nullable: true,
+		},
+		{
+			// set to 1 on the chunks soft deleted along with their document
+			Name:     COLUMN_SOFT_DELETED_CASCADE,
+			Type:     sb.COLUMN_TYPE_INTEGER,
+			Nullable: true,
+		},
+	}
+}
+
+// sqlDocumentChunkStatusBackfill returns a SQL string for setting the status
+// of the chunks created before the status column was added, pending or
+// embedded, depending on whether they have an embedding. An empty embedding
+// is stored as an empty JSON array, or the binary format prefix alone.
+func (st *store) sqlDocumentChunkStatusBackfill() (string, []any, error) {
+	length := "LENGTH(?)"
+
+	// LEN does not accept the TEXT and VARBINARY columns of MSSQL
+	if st.dbDriverName == sb.DIALECT_MSSQL {
+		length = "DATALENGTH(?)"
+	}
+
+	notEmbedded := goqu.Or(
+		goqu.C(COLUMN_EMBEDDING).IsNull(),
+		goqu.L(length, goqu.C(COLUMN_EMBEDDING)).Lte(2),
+	)
+
+	if st.embeddingFormat == EMBEDDING_FORMAT_BINARY {
+		notEmbedded = notEmbedded.Append(goqu.C(COLUMN_EMBEDDING).Eq([]byte(embeddingBinaryMagic)))
+	}
+
+	return goqu.Dialect(st.dbDriverName).
+		Update(st.tableDocumentChunk).
+		Prepared(true).
+		Set(goqu.Record{
+			COLUMN_STATUS: goqu.Case().
+				When(notEmbedded, CHUNK_STATUS_PENDING).
+				Else(CHUNK_STATUS_EMBEDDED),
+		}).
+		Where(goqu.C(COLUMN_STATUS).IsNull()).
+		ToSQL()
+}
+
+// sqlTableColumnsAdd returns the SQL strings for adding the columns, which
+// are missing from the existing columns of the table
+func (st *store) sqlTableColumnsAdd(table string, existing []string, columns []sb.Column) ([]string, error) {
+	sqls := []string{}
+
+	for _, column := range columns {
+		if slices.Contains(existing, column.Name) {
+			continue
+		}
+
+		sql, err := sb.NewBuilder(sb.DatabaseDriverName(st.db)).TableColumnAdd(table, column)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sqls = append(sqls, sql)
+	}
+
+	return sqls, nil
+}
+
+// sqlDocumentChunkEmbeddingColumnToBlob returns a SQL string for changing the
+// embedding column of an existing chunk table to a binary type, keeping the
+// bytes of the stored JSON embeddings. MSSQL cannot convert TEXT to binary
+// directly, so the column is changed to VARCHAR(MAX) first. SQLite is
+// dynamically typed and needs no change, so an empty string is returned.
+func (st *store) sqlDocumentChunkEmbeddingColumnToBlob() string {
+	switch st.dbDriverName {
+	case sb.DIALECT_POSTGRES:
+		return `ALTER TABLE "` + st.tableDocumentChunk + `" ALTER COLUMN "` + COLUMN_EMBEDDING + `" TYPE BYTEA USING "` + COLUMN_EMBEDDING + `"::bytea;`
+	case sb.DIALECT_MYSQL:
+		return "ALTER TABLE `" + st.tableDocumentChunk + "` MODIFY `" + COLUMN_EMBEDDING + "` LONGBLOB;"
+	case sb.DIALECT_MSSQL:
+		return `ALTER TABLE "` + st.tableDocumentChunk + `" ALTER COLUMN "` + COLUMN_EMBEDDING + `" VARCHAR(MAX);` +
+			` ALTER TABLE "` + st.tableDocumentChunk + `" ALTER COLUMN "` + COLUMN_EMBEDDING + `" VARBINARY(MAX);`
+	default:
+		return ""
+	}
+}
+
+// sqlParameterLimit returns the maximum number of bind parameters of a
+// single statement in the dialect. SQLite builds before 3.32 allow 999.
+func (st *store) sqlParameterLimit() int {
+	switch st.dbDriverName {
+	case sb.DIALECT_MSSQL:
+		return 2000
+	case sb.DIALECT_MYSQL, sb.DIALECT_POSTGRES:
+		return 65535
+	default:
+		return 999
+	}
+}
+
+// sqlMetaValue returns an expression for the text value of the meta key,
+// extracted from the JSON metas column with the functions of the dialect.
+// The value is NULL, when the key does not exist. Rows without metas, which
+// hold an empty string or NULL, are read as an empty JSON object, as the
+// JSON functions fail on an empty string.
+func (st *store) sqlMetaValue(key string) exp.LiteralExpression {
+	path := `$."` + key + `"`
+
+	column := exp.Expression(goqu.C(COLUMN_METAS))
+
+	// MSSQL cannot compare TEXT columns, nor pass them to JSON_VALUE
+	if st.dbDriverName == sb.DIALECT_MSSQL {
+		column = goqu.L(`CAST(? AS NVARCHAR(MAX))`, goqu.C(COLUMN_METAS))
+	}
+
+	metas := goqu.L(`COALESCE(NULLIF(?, ''), '{}')`, column)
+
+	switch st.dbDriverName {
+	case sb.DIALECT_POSTGRES:
+		return goqu.L(`CAST(? AS JSONB) ->> ?`, metas, key)
+	case sb.DIALECT_MYSQL:
+		return goqu.L(`JSON_UNQUOTE(JSON_EXTRACT(?, ?))`, metas, path)
+	case sb.DIALECT_MSSQL:
+		return goqu.L(`JSON_VALUE(?, ?)`, metas, path)
+	default:
+		return goqu.L(`json_extract(?, ?)`, metas, path)
+	}
+}
+
+// sqlMetaFilters adds the meta filters of a query to the select dataset
+func (st *store) sqlMetaFilters(sql *goqu.SelectDataset, equals map[string]string, in map[string][]string, exists []string) *goqu.SelectDataset {
+	// sorted, so that the same filters result in the same SQL
+	for _, key := range slices.Sorted(maps.Keys(equals)) {
+		sql = sql.Where(st.sqlMetaValue(key).Eq(equals[key]))
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(in)) {
+		sql = sql.Where(st.sqlMetaValue(key).In(in[key]))
+	}
+
+	for _, key := range lo.Uniq(exists) {
+		sql = sql.Where(st.sqlMetaValue(key).IsNotNull())
+	}
+
+	return sql
+}
+
+// validateMetaKey checks the meta key can be used in a JSON path
+func validateMetaKey(key string) error {
+	if key == "" {
+		return errors.New("meta key cannot be empty")
+	}
+
+	if strings.ContainsAny(key, `"\`) {
+		return errors.New("meta key cannot contain quotes or backslashes: " + key)
+	}
+
+	return nil
+}
@@ -1,10 +1,16 @@
 package ragstore
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log/slog"
 	"os"
+	"slices"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dracory/base/database"
+	"github.com/gouniverse/sb"
 )
 
 // ============================================================================
@@ -19,6 +25,47 @@ type store struct {
 	automigrateEnabled bool
 	debugEnabled       bool
 	logger             *slog.Logger
+
+	// tableDocumentChunkTerm is the inverted index for keyword search,
+	// which is disabled when empty
+	tableDocumentChunkTerm string
+
+	// tableDocumentChunkVector holds the embeddings of the named vector
+	// spaces, which are disabled when empty
+	tableDocumentChunkVector string
+
+	// tableLease holds the leases of the maintenance runs, which are
+	// disabled when empty
+	tableLease string
+
+	distanceMetric      string
+	normalizeEmbeddings bool
+	embeddingFormat     string
+
+	embeddingModel           string
+	embeddingDimension       int
+	embeddingRejectNonFinite bool
+
+	quantization              string
+	quantizationRescoreFactor int
+
+	embeddingBatchSize int
+	chunkBatchSize     int
+
+	cascadeDeleteEnabled bool
+	ownerScopeRequired   bool
+
+	hnsw             *hnswIndex
+	hnswSnapshotPath string
+
+	// tx is set on the store passed to the WithTx function, along with the
+	// HNSW index changes applied once the transaction commits
+	tx          *sql.Tx
+	hnswPending *[]func() error
+
+	// hnswContextTxs are the transactions carried by contexts, which
+	// changed the HNSW index, when it is enabled
+	hnswContextTxs *contextTxSet
 }
 
 // ============================================================================
@@ -32,7 +79,7 @@ var _ StoreInterface = (*store)(nil) // verify it extends the interface
 // ============================================================================
 
 // AutoMigrate auto migrate
-func (store *store) AutoMigrate() error {
+func (store *store) AutoMigrate(ctx context.Context) error {
 	if store.db == nil {
 		return errors.New("chatstore: database is nil")
 	}
@@ -42,21 +89,205 @@ func (store *store) AutoMigrate() error {
 		store.sqlDocumentChunkTableCreate(),
 	}
 
+	if store.tableDocumentChunkTerm != "" {
+		// the indexes have no "if not exists", so are only created with the table
+		if _, err := store.tableColumns(ctx, store.tableDocumentChunkTerm); err != nil {
+			sqls = append(sqls, store.sqlDocumentChunkTermTableCreate())
+			sqls = append(sqls, store.sqlDocumentChunkTermTableIndexesCreate()...)
+		}
+	}
+
+	if store.tableDocumentChunkVector != "" {
+		if _, err := store.tableColumns(ctx, store.tableDocumentChunkVector); err != nil {
+			sqls = append(sqls, store.sqlDocumentChunkVectorTableCreate())
+			sqls = append(sqls, store.sqlDocumentChunkVectorTableIndexesCreate()...)
+		}
+	}
+
+	if store.tableLease != "" {
+		sqls = append(sqls, store.sqlLeaseTableCreate())
+	}
+
 	for _, sql := range sqls {
 		if sql == "" {
 			return errors.New("table create sql is empty")
 		}
 
-		_, err := store.db.Exec(sql)
+		_, err := database.Execute(store.toQueryableContext(ctx), sql)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	documentAdded, err := store.autoMigrateColumns(ctx, store.tableDocument, store.sqlDocumentTableColumnsAdded())
+
+	if err != nil {
+		return err
+	}
+
+	chunkAdded, err := store.autoMigrateColumns(ctx, store.tableDocumentChunk, store.sqlDocumentChunkTableColumnsAdded())
+
+	if err != nil {
+		return err
+	}
+
+	if slices.Contains(chunkAdded, COLUMN_STATUS) {
+		sqlStr, sqlParams, err := store.sqlDocumentChunkStatusBackfill()
 
 		if err != nil {
 			return err
 		}
+
+		if _, err := database.Execute(store.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+			return err
+		}
+	}
+
+	if slices.Contains(documentAdded, COLUMN_CONTENT_HASH) {
+		if err := store.contentHashBackfill(ctx, store.tableDocument, COLUMN_TEXT); err != nil {
+			return err
+		}
+	}
+
+	if slices.Contains(chunkAdded, COLUMN_CONTENT_HASH) {
+		if err := store.contentHashBackfill(ctx, store.tableDocumentChunk, COLUMN_CONTENT); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
+// contentHashBackfill sets the content hash of the existing rows of the
+// table, hashed from the column, as the hash cannot be computed in SQL
+// the same way for all databases
+func (store *store) contentHashBackfill(ctx context.Context, table string, column string) error {
+	for {
+		sqlStr, sqlParams, err := goqu.Dialect(store.dbDriverName).
+			From(table).
+			Select(COLUMN_ID, column).
+			Where(goqu.C(COLUMN_CONTENT_HASH).IsNull()).
+			Order(goqu.C(COLUMN_ID).Asc()).
+			Limit(chunkEmbeddingsMigrateBatchSize).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return err
+		}
+
+		rows, err := database.SelectToMapString(store.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			sqlStr, sqlParams, err := goqu.Dialect(store.dbDriverName).
+				Update(table).
+				Prepared(true).
+				Set(goqu.Record{COLUMN_CONTENT_HASH: contentHash(row[column])}).
+				Where(goqu.C(COLUMN_ID).Eq(row[COLUMN_ID])).
+				ToSQL()
+
+			if err != nil {
+				return err
+			}
+
+			if _, err := database.Execute(store.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+				return err
+			}
+		}
+
+		if len(rows) < chunkEmbeddingsMigrateBatchSize {
+			return nil
+		}
+	}
+}
+
+// autoMigrateColumns adds the columns missing from an existing table, and
+// returns the names of the added columns
+func (store *store) autoMigrateColumns(ctx context.Context, table string, columns []sb.Column) ([]string, error) {
+	existing, err := store.tableColumns(ctx, table)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sqls, err := store.sqlTableColumnsAdd(table, existing, columns)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sql := range sqls {
+		if _, err := database.Execute(store.toQueryableContext(ctx), sql); err != nil {
+			return nil, err
+		}
+	}
+
+	added := []string{}
+
+	for _, column := range columns {
+		if !slices.Contains(existing, column.Name) {
+			added = append(added, column.Name)
+		}
+	}
+
+	return added, nil
+}
+
+// tableColumns returns the column names of an existing table
+func (store *store) tableColumns(ctx context.Context, table string) ([]string, error) {
+	sqlStr, _, err := goqu.Dialect(store.dbDriverName).
+		From(table).
+		Where(goqu.L("1 = 0")).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.Query(store.toQueryableContext(ctx), sqlStr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	return rows.Columns()
+}
+
+// toQueryableContext returns the context with the store transaction, when
+// the store runs in a transaction. Otherwise it returns the context as is,
+// when it already carries a database or transaction to query, or with the
+// store database.
+//
+// Within a transaction, a context carrying the store database is queried
+// in the transaction as well, while the queries of a context carrying any
+// other database or transaction fail, as they would escape the rollback.
+func (st *store) toQueryableContext(ctx context.Context) database.QueryableContext {
+	if st.tx == nil {
+		if database.IsQueryableContext(ctx) {
+			return ctx.(database.QueryableContext)
+		}
+
+		return database.Context(ctx, st.db)
+	}
+
+	if queryableContext, isQueryable := ctx.(database.QueryableContext); isQueryable {
+		switch queryableContext.Queryable() {
+		case st.tx, st.db:
+		default:
+			return database.Context(ctx, queryableForeign)
+		}
+	}
+
+	return database.Context(ctx, st.tx)
+}
+
 // EnableDebug - enables the debug option
 func (st *store) EnableDebug(debug bool) {
 	st.debugEnabled = debug
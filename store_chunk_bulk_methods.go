@@ -0,0 +1,346 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dracory/base/database"
+	"github.com/samber/lo"
+)
+
+// chunkBulkRow is a chunk passed to a bulk method, along with its index
+// in the passed slice
+type chunkBulkRow struct {
+	index  int
+	id     string
+	chunk  ChunkInterface
+	record goqu.Record
+}
+
+// ChunkCreateMany creates the chunks with multi-row inserts, in batches
+// of the configured chunk batch size. Each batch is written in its own
+// transaction. When a batch fails, its chunks are created one by one,
+// so that only the failing chunks are left out.
+//
+// Within an owner scope, the chunks of documents out of the scope are
+// not created.
+//
+// Returns a *BulkError with the errors of the chunks, which were not
+// created. Within WithTx, or a transaction carried by the context, a failed
+// batch is not retried, and its error is returned instead.
+func (st *store) ChunkCreateMany(ctx context.Context, chunks []ChunkInterface) error {
+	if st.db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	bulkErr := newBulkError()
+
+	documentIDs := []string{}
+
+	for _, chunk := range chunks {
+		if chunk != nil {
+			documentIDs = append(documentIDs, chunk.DocumentID())
+		}
+	}
+
+	documentIDs, err := st.documentIDsInScope(ctx, documentIDs)
+
+	if err != nil {
+		return err
+	}
+
+	// chunks with different data keys cannot share an insert statement
+	groups := map[string][]chunkBulkRow{}
+	groupKeys := []string{}
+
+	for index, chunk := range chunks {
+		if chunk == nil {
+			bulkErr.add(index, errors.New("chunk is nil"))
+			continue
+		}
+
+		if chunk.ID() == "" {
+			bulkErr.add(index, errors.New("chunk ID is required"))
+			continue
+		}
+
+		if !slices.Contains(documentIDs, chunk.DocumentID()) {
+			bulkErr.add(index, errors.New("chunk document is not in the owner scope"))
+			continue
+		}
+
+		record, err := st.chunkCreateRecord(ctx, chunk)
+
+		if err != nil {
+			bulkErr.add(index, err)
+			continue
+		}
+
+		columns := lo.Keys(record)
+		slices.Sort(columns)
+		groupKey := strings.Join(columns, ",")
+
+		if _, exists := groups[groupKey]; !exists {
+			groupKeys = append(groupKeys, groupKey)
+		}
+
+		groups[groupKey] = append(groups[groupKey], chunkBulkRow{
+			index:  index,
+			id:     chunk.ID(),
+			chunk:  chunk,
+			record: record,
+		})
+	}
+
+	for _, groupKey := range groupKeys {
+		rows := groups[groupKey]
+		batches := lo.Chunk(rows, st.chunkBulkBatchSize(len(rows[0].record)))
+
+		if err := st.chunkBulkWrite(ctx, batches, bulkErr, (*store).chunkCreateBatch); err != nil {
+			return err
+		}
+	}
+
+	return bulkErr.errorOrNil()
+}
+
+// ChunkUpdateMany updates the changed data of the chunks, in batches of
+// the configured chunk batch size. Each batch is written in its own
+// transaction. When a batch fails, its chunks are updated one by one,
+// so that only the failing chunks are left out.
+//
+// Returns a *BulkError with the errors of the chunks, which were not
+// updated. Within WithTx, or a transaction carried by the context, a failed
+// batch is not retried, and its error is returned instead.
+func (st *store) ChunkUpdateMany(ctx context.Context, chunks []ChunkInterface) error {
+	if st.db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	bulkErr := newBulkError()
+	rows := []chunkBulkRow{}
+
+	for index, chunk := range chunks {
+		if chunk == nil {
+			bulkErr.add(index, errors.New("chunk is nil"))
+			continue
+		}
+
+		if chunk.ID() == "" {
+			bulkErr.add(index, errors.New("chunk ID is required"))
+			continue
+		}
+
+		rows = append(rows, chunkBulkRow{
+			index: index,
+			id:    chunk.ID(),
+			chunk: chunk,
+		})
+	}
+
+	batches := lo.Chunk(rows, st.chunkBatchSize)
+
+	if err := st.chunkBulkWrite(ctx, batches, bulkErr, (*store).chunkUpdateBatch); err != nil {
+		return err
+	}
+
+	return bulkErr.errorOrNil()
+}
+
+// ChunkDeleteMany permanently deletes the chunks with the IDs, in batches
+// of the configured chunk batch size. Each batch is deleted in its own
+// transaction. When a batch fails, its chunks are deleted one by one,
+// so that only the failing chunks are left out.
+//
+// Returns a *BulkError with the errors of the IDs, which were not
+// deleted. Within WithTx, or a transaction carried by the context, a failed
+// batch is not retried, and its error is returned instead.
+func (st *store) ChunkDeleteMany(ctx context.Context, ids []string) error {
+	if st.db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	bulkErr := newBulkError()
+	rows := []chunkBulkRow{}
+
+	for index, id := range ids {
+		if id == "" {
+			bulkErr.add(index, errors.New("chunk ID is required"))
+			continue
+		}
+
+		rows = append(rows, chunkBulkRow{
+			index: index,
+			id:    id,
+		})
+	}
+
+	batches := lo.Chunk(rows, st.chunkBulkBatchSize(1))
+
+	if err := st.chunkBulkWrite(ctx, batches, bulkErr, (*store).chunkDeleteBatch); err != nil {
+		return err
+	}
+
+	return bulkErr.errorOrNil()
+}
+
+// chunkBulkBatchSize returns the number of rows per batch, keeping the
+// bind parameters of a batch within the limit of the database
+func (st *store) chunkBulkBatchSize(parametersPerRow int) int {
+	return max(1, min(st.chunkBatchSize, st.sqlParameterLimit()/max(1, parametersPerRow)))
+}
+
+// chunkBulkWrite writes each batch in a transaction. When a batch fails,
+// its rows are written one by one, and the errors of the failing rows are
+// added to the bulk error. The written chunks are marked as not dirty.
+//
+// Within a running transaction, of the store or carried by the context,
+// a failed batch cannot be retried, as the transaction may be aborted,
+// and its error is returned.
+func (st *store) chunkBulkWrite(ctx context.Context, batches [][]chunkBulkRow, bulkErr *BulkError, write func(txStore *store, ctx context.Context, batch []chunkBulkRow) error) error {
+	writeBatch := func(batch []chunkBulkRow) error {
+		err := st.withTx(ctx, func(txStore *store) error {
+			return write(txStore, ctx, batch)
+		})
+
+		if err != nil {
+			return err
+		}
+
+		for _, row := range batch {
+			if row.chunk != nil {
+				row.chunk.MarkAsNotDirty()
+			}
+		}
+
+		return nil
+	}
+
+	_, inContextTx := contextTx(ctx)
+
+	for _, batch := range batches {
+		err := writeBatch(batch)
+
+		if err == nil {
+			continue
+		}
+
+		if st.tx != nil || inContextTx {
+			return err
+		}
+
+		if len(batch) == 1 {
+			bulkErr.add(batch[0].index, err)
+			continue
+		}
+
+		for _, row := range batch {
+			if err := writeBatch([]chunkBulkRow{row}); err != nil {
+				bulkErr.add(row.index, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// chunkCreateBatch inserts the chunks of the batch with one statement,
+// and adds them to the keyword and HNSW indexes
+func (st *store) chunkCreateBatch(ctx context.Context, batch []chunkBulkRow) error {
+	records := lo.Map(batch, func(row chunkBulkRow, _ int) any {
+		return row.record
+	})
+
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Insert(st.tableDocumentChunk).
+		Prepared(true).
+		Rows(records...).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	if _, err := database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+		return err
+	}
+
+	chunks := lo.Map(batch, func(row chunkBulkRow, _ int) ChunkInterface {
+		return row.chunk
+	})
+
+	if err := st.chunkKeywordIndexMany(ctx, chunks); err != nil {
+		return err
+	}
+
+	for _, chunk := range chunks {
+		if err := st.hnswChunkUpsert(chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chunkUpdateBatch updates the chunks of the batch
+func (st *store) chunkUpdateBatch(ctx context.Context, batch []chunkBulkRow) error {
+	for _, row := range batch {
+		if err := st.chunkUpdate(ctx, row.chunk); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chunkDeleteBatch deletes the chunks of the batch with one statement,
+// and removes them from the keyword and HNSW indexes
+func (st *store) chunkDeleteBatch(ctx context.Context, batch []chunkBulkRow) error {
+	ids, err := st.chunkIDsInScope(ctx, lo.Map(batch, func(row chunkBulkRow, _ int) string {
+		return row.id
+	}))
+
+	if err != nil || len(ids) < 1 {
+		return err // chunks of other owners are left untouched
+	}
+
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Delete(st.tableDocumentChunk).
+		Prepared(true).
+		Where(goqu.C(COLUMN_ID).In(ids)).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	if _, err := database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+		return err
+	}
+
+	if err := st.chunkKeywordRemove(ctx, ids...); err != nil {
+		return err
+	}
+
+	if err := st.chunkVectorsRemove(ctx, ids...); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		st.hnswChunkRemove(id)
+	}
+
+	return nil
+}
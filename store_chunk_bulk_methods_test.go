@@ -0,0 +1,269 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/dracory/base/database"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestStore_ChunkCreateMany(t *testing.T) {
+	storeBulk := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.ChunkBatchSize = 20
+		opts.HNSWEnabled = true
+	})
+
+	existing := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(0).
+		SetContent("existing").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	if err := storeBulk.ChunkCreate(context.Background(), existing); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunks := []ChunkInterface{}
+
+	for i := 0; i < 100; i++ {
+		chunks = append(chunks, NewChunk().
+			SetDocumentID(testDocument_O1).
+			SetChunkIndex(i).
+			SetContent("chunk ERR-"+strconv.Itoa(i)).
+			SetEmbedding([]float32{float32(i), 1.0}))
+	}
+
+	// a duplicate ID and a nil chunk fail, the others are created
+	chunks[30].SetID(existing.ID())
+	chunks[70] = nil
+
+	err := storeBulk.ChunkCreateMany(context.Background(), chunks)
+
+	var bulkErr *BulkError
+
+	if !errors.As(err, &bulkErr) {
+		t.Fatal("Expected a bulk error, got:", err)
+	}
+
+	if !slices.Equal(bulkErr.Indexes(), []int{30, 70}) {
+		t.Fatal("Expected chunks 30 and 70 to fail, got:", bulkErr.Indexes())
+	}
+
+	count, err := storeBulk.ChunkCount(context.Background(), ChunkQuery())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 99 {
+		t.Fatalf("Expected 99 chunks, got %d", count)
+	}
+
+	if chunks[0].IsDirty() {
+		t.Fatal("Expected the created chunk not to be dirty")
+	}
+
+	results, err := storeBulk.ChunkSearchKeyword(context.Background(), "err-42", ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) < 1 || results[0].Chunk.ID() != chunks[42].ID() {
+		t.Fatal("Expected the created chunk to be keyword indexed")
+	}
+
+	if !storeBulk.(*store).hnsw.Has(chunks[99].ID()) {
+		t.Fatal("Expected the created chunk to be in the HNSW index")
+	}
+
+	if storeBulk.ChunkCreateMany(context.Background(), []ChunkInterface{}) != nil {
+		t.Fatal("Expected no error for no chunks")
+	}
+}
+
+func TestStore_ChunkUpdateMany(t *testing.T) {
+	storeBulk := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.ChunkBatchSize = 20
+		opts.HNSWEnabled = true
+	})
+
+	chunks := []ChunkInterface{}
+
+	for i := 0; i < 50; i++ {
+		chunks = append(chunks, NewChunk().
+			SetDocumentID(testDocument_O1).
+			SetChunkIndex(i).
+			SetContent("before"))
+	}
+
+	if err := storeBulk.ChunkCreateMany(context.Background(), chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, chunk := range chunks {
+		chunk.SetContent("after")
+	}
+
+	err := storeBulk.ChunkUpdateMany(context.Background(), append(chunks, nil))
+
+	var bulkErr *BulkError
+
+	if !errors.As(err, &bulkErr) || !slices.Equal(bulkErr.Indexes(), []int{50}) {
+		t.Fatal("Expected the nil chunk to fail, got:", err)
+	}
+
+	list, err := storeBulk.ChunkList(context.Background(), ChunkQuery())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, chunk := range list {
+		if chunk.Content() != "after" {
+			t.Fatal("Expected all chunks to be updated, got:", chunk.Content())
+		}
+	}
+
+	results, err := storeBulk.ChunkSearchKeyword(context.Background(), "after", ChunkKeywordSearchOptions{Limit: 100})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 50 {
+		t.Fatalf("Expected 50 chunks keyword indexed with the new content, got %d", len(results))
+	}
+}
+
+func TestStore_ChunkDeleteMany(t *testing.T) {
+	storeBulk := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.ChunkBatchSize = 20
+		opts.HNSWEnabled = true
+	})
+
+	chunks := []ChunkInterface{}
+
+	for i := 0; i < 50; i++ {
+		chunks = append(chunks, NewChunk().
+			SetDocumentID(testDocument_O1).
+			SetChunkIndex(i).
+			SetContent("chunk").
+			SetEmbedding([]float32{float32(i), 1.0}))
+	}
+
+	if err := storeBulk.ChunkCreateMany(context.Background(), chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ids := []string{}
+
+	for _, chunk := range chunks[:40] {
+		ids = append(ids, chunk.ID())
+	}
+
+	err := storeBulk.ChunkDeleteMany(context.Background(), append(ids, ""))
+
+	var bulkErr *BulkError
+
+	if !errors.As(err, &bulkErr) || !slices.Equal(bulkErr.Indexes(), []int{40}) {
+		t.Fatal("Expected the empty ID to fail, got:", err)
+	}
+
+	count, err := storeBulk.ChunkCount(context.Background(), ChunkQuery())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 10 {
+		t.Fatalf("Expected 10 chunks left, got %d", count)
+	}
+
+	if storeBulk.(*store).hnsw.Has(chunks[0].ID()) {
+		t.Fatal("Deleted chunk MUST NOT be in the HNSW index")
+	}
+
+	results, err := storeBulk.ChunkSearchKeyword(context.Background(), "chunk", ChunkKeywordSearchOptions{Limit: 100})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 10 {
+		t.Fatalf("Expected 10 chunks keyword indexed, got %d", len(results))
+	}
+}
+
+func TestStore_ChunkBulkBatchSize(t *testing.T) {
+	storeBulk := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.ChunkBatchSize = 20
+		opts.HNSWEnabled = true
+	}).(*store)
+
+	if size := storeBulk.chunkBulkBatchSize(10); size != 20 {
+		t.Fatalf("Expected the configured batch size 20, got %d", size)
+	}
+
+	if size := storeBulk.chunkBulkBatchSize(100); size != 9 {
+		t.Fatalf("Expected 9 rows within the SQLite parameter limit, got %d", size)
+	}
+}
+
+func TestStore_ChunkCreateManyContextTx(t *testing.T) {
+	storeBulk := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.ChunkBatchSize = 20
+	})
+
+	existing := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(0).
+		SetContent("existing").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	if err := storeBulk.ChunkCreate(context.Background(), existing); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	tx, err := storeBulk.(*store).db.Begin()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	defer tx.Rollback()
+
+	chunks := []ChunkInterface{}
+
+	for i := 0; i < 10; i++ {
+		chunks = append(chunks, NewChunk().
+			SetDocumentID(testDocument_O1).
+			SetChunkIndex(i+1).
+			SetContent("chunk "+strconv.Itoa(i)).
+			SetEmbedding([]float32{float32(i), 1.0}))
+	}
+
+	chunks[5].SetID(existing.ID())
+
+	// the failed batch is not retried within the transaction of the caller
+	err = storeBulk.ChunkCreateMany(database.Context(context.Background(), tx), chunks)
+
+	if err == nil {
+		t.Fatal("Expected an error for the failed batch")
+	}
+
+	var bulkErr *BulkError
+
+	if errors.As(err, &bulkErr) {
+		t.Fatal("Expected the error of the batch, not a bulk error, got:", err)
+	}
+}
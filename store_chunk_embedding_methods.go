@@ -0,0 +1,149 @@
+package ragstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dracory/base/database"
+)
+
+// chunkEmbeddingsMigrateBatchSize is the number of chunks read per batch
+// while migrating embeddings
+const chunkEmbeddingsMigrateBatchSize = 500
+
+// ChunkEmbeddingsMigrate converts the stored embeddings of all chunks
+// (including soft deleted ones) to the embedding format of the store,
+// i.e. existing JSON rows to the binary format. For the binary format
+// the embedding column is first changed to a binary type, where the
+// database requires it. When quantization is enabled, the missing or
+// outdated quantized copies are written as well.
+//
+// Returns the number of converted chunks.
+func (st *store) ChunkEmbeddingsMigrate(ctx context.Context) (int64, error) {
+	if st.db == nil {
+		return 0, errors.New("database is not initialized")
+	}
+
+	if st.embeddingFormat == EMBEDDING_FORMAT_BINARY {
+		if sqlStr := st.sqlDocumentChunkEmbeddingColumnToBlob(); sqlStr != "" {
+			if st.debugEnabled {
+				log.Println(sqlStr)
+			}
+
+			if _, err := database.Execute(st.toQueryableContext(ctx), sqlStr); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	converted := int64(0)
+	cursor := ""
+
+	columns := []any{COLUMN_ID, COLUMN_EMBEDDING}
+
+	if st.quantization != QUANTIZATION_NONE {
+		columns = append(columns, COLUMN_EMBEDDING_QUANTIZED)
+	}
+
+	for {
+		sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+			From(st.tableDocumentChunk).
+			Select(columns...).
+			Where(goqu.C(COLUMN_ID).Gt(cursor)).
+			Order(goqu.C(COLUMN_ID).Asc()).
+			Limit(chunkEmbeddingsMigrateBatchSize).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return converted, err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+		if err != nil {
+			return converted, err
+		}
+
+		for _, row := range rows {
+			id := row[COLUMN_ID]
+			value := row[COLUMN_EMBEDDING]
+
+			needsQuantization, err := st.embeddingNeedsQuantization(value, row[COLUMN_EMBEDDING_QUANTIZED])
+
+			if err != nil {
+				return converted, errors.New("chunk " + id + ": " + err.Error())
+			}
+
+			if !st.embeddingNeedsMigration(value) && !needsQuantization {
+				continue
+			}
+
+			record, err := st.chunkRecord(map[string]string{COLUMN_EMBEDDING: value})
+
+			if err != nil {
+				return converted, errors.New("chunk " + id + ": " + err.Error())
+			}
+
+			sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+				Update(st.tableDocumentChunk).
+				Prepared(true).
+				Set(record).
+				Where(goqu.C(COLUMN_ID).Eq(id)).
+				ToSQL()
+
+			if err != nil {
+				return converted, err
+			}
+
+			if _, err := database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+				return converted, err
+			}
+
+			converted++
+		}
+
+		if len(rows) < chunkEmbeddingsMigrateBatchSize {
+			return converted, nil
+		}
+
+		cursor = rows[len(rows)-1][COLUMN_ID]
+	}
+}
+
+// embeddingNeedsMigration checks if a stored embedding value is in
+// a different format than the store embedding format
+func (st *store) embeddingNeedsMigration(value string) bool {
+	if value == "" || value == "null" {
+		return false
+	}
+
+	if st.embeddingFormat == EMBEDDING_FORMAT_BINARY {
+		return !embeddingIsBinary(value)
+	}
+
+	return embeddingIsBinary(value)
+}
+
+// embeddingNeedsQuantization checks if the quantized copy of a stored
+// embedding value is missing or differs from the store quantization
+func (st *store) embeddingNeedsQuantization(value string, quantized string) (bool, error) {
+	if st.quantization == QUANTIZATION_NONE {
+		return false, nil
+	}
+
+	embedding, err := embeddingFromString(value)
+
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Equal(quantizeEmbedding(st.quantization, embedding), []byte(quantized)), nil
+}
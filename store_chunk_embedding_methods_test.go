@@ -0,0 +1,189 @@
+package ragstore
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestStore_ChunkEmbeddingsMigrate(t *testing.T) {
+	db := initDB(":memory:")
+
+	storeJSON, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk1 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.5, -2.0, 3.25})
+
+	chunk2 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("chunk 2")
+
+	for _, chunk := range []ChunkInterface{chunk1, chunk2} {
+		if err := storeJSON.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	var storedType string
+
+	err = db.QueryRow(`SELECT typeof(embedding) FROM document_chunk_table WHERE id = ?`, chunk1.ID()).Scan(&storedType)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if storedType != "text" {
+		t.Fatalf("Expected JSON embedding to be stored as text, got %s", storedType)
+	}
+
+	storeBinary, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		EmbeddingFormat:        EMBEDDING_FORMAT_BINARY,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	converted, err := storeBinary.ChunkEmbeddingsMigrate(context.Background())
+
+	if err != nil {
+		t.Fatal("unexpected error migrating embeddings:", err)
+	}
+
+	if converted != 1 {
+		t.Fatalf("Expected 1 converted chunk, got %d", converted)
+	}
+
+	err = db.QueryRow(`SELECT typeof(embedding) FROM document_chunk_table WHERE id = ?`, chunk1.ID()).Scan(&storedType)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if storedType != "blob" {
+		t.Fatalf("Expected binary embedding to be stored as blob, got %s", storedType)
+	}
+
+	chunkFound, err := storeBinary.ChunkFindByID(context.Background(), chunk1.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	embedding, err := chunkFound.EmbeddingE()
+
+	if err != nil {
+		t.Fatal("unexpected error decoding embedding:", err)
+	}
+
+	if len(embedding) != 3 || embedding[0] != 1.5 || embedding[1] != -2.0 || embedding[2] != 3.25 {
+		t.Fatalf("Unexpected embedding after migration: %v", embedding)
+	}
+
+	// Running again converts nothing
+	converted, err = storeBinary.ChunkEmbeddingsMigrate(context.Background())
+
+	if err != nil {
+		t.Fatal("unexpected error migrating embeddings:", err)
+	}
+
+	if converted != 0 {
+		t.Fatalf("Expected 0 converted chunks on second run, got %d", converted)
+	}
+}
+
+func TestStore_ChunkEmbeddingBinaryFormat(t *testing.T) {
+	store, err := NewStore(NewStoreOptions{
+		DB:                     initDB(":memory:"),
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+		EmbeddingFormat:        EMBEDDING_FORMAT_BINARY,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{0.25, 0.5})
+
+	if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+		t.Fatal("unexpected error creating chunk:", err)
+	}
+
+	results, err := store.ChunkSearchSimilar(context.Background(), []float32{0.25, 0.5}, ChunkSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+
+	embedding, err := results[0].Chunk.EmbeddingE()
+
+	if err != nil {
+		t.Fatal("unexpected error decoding embedding:", err)
+	}
+
+	if len(embedding) != 2 || embedding[0] != 0.25 || embedding[1] != 0.5 {
+		t.Fatalf("Unexpected embedding: %v", embedding)
+	}
+}
+
+func TestChunk_EmbeddingDecodeError(t *testing.T) {
+	chunk := NewChunkFromExistingData(map[string]string{
+		COLUMN_EMBEDDING: "abc",
+	})
+
+	_, err := chunk.EmbeddingE()
+
+	if err == nil {
+		t.Fatal("expected decode error, but got nil")
+	}
+
+	if embedding := chunk.Embedding(); len(embedding) != 0 {
+		t.Fatalf("Expected an empty embedding, got %v", embedding)
+	}
+}
+
+func TestChunk_EmbeddingBinaryLooksLikeJSON(t *testing.T) {
+	// a one value embedding, whose 4 bytes are valid JSON
+	value := math.Float32frombits(binary.LittleEndian.Uint32([]byte("[12]")))
+
+	chunk := NewChunk().SetEmbedding([]float32{value})
+
+	embedding, err := chunk.EmbeddingE()
+
+	if err != nil {
+		t.Fatal("unexpected error decoding embedding:", err)
+	}
+
+	if len(embedding) != 1 || embedding[0] != value {
+		t.Fatalf("Unexpected embedding: %v", embedding)
+	}
+}
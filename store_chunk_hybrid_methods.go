@@ -0,0 +1,156 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+)
+
+// ChunkSearchHybrid runs a similarity search for the query embedding and
+// a keyword search for the text, and fuses their results, highest fused
+// score first. Each result carries the score and rank of both searches.
+//
+// Either the text or the query embedding may be empty, to rank by the
+// other search only. Keyword search must be enabled for the text.
+func (st *store) ChunkSearchHybrid(ctx context.Context, text string, queryEmbedding []float32, options ChunkHybridSearchOptions) ([]ChunkHybridSearchResult, error) {
+	if st.db == nil {
+		return nil, errors.New("database is not initialized")
+	}
+
+	if text == "" && len(queryEmbedding) < 1 {
+		return nil, errors.New("query text or embedding is required")
+	}
+
+	if options.Limit < 0 || options.CandidateLimit < 0 {
+		return nil, errors.New("search limit cannot be negative")
+	}
+
+	if options.Limit == 0 {
+		options.Limit = 10
+	}
+
+	if options.CandidateLimit == 0 {
+		options.CandidateLimit = options.Limit * 5
+	}
+
+	if options.Fusion == "" {
+		options.Fusion = FUSION_RRF
+	}
+
+	if err := validateFusion(options.Fusion); err != nil {
+		return nil, err
+	}
+
+	if options.RRFK < 0 {
+		return nil, errors.New("rrf k cannot be negative")
+	}
+
+	if options.RRFK == 0 {
+		options.RRFK = 60
+	}
+
+	if options.VectorWeight < 0 || options.KeywordWeight < 0 {
+		return nil, errors.New("fusion weights cannot be negative")
+	}
+
+	if options.VectorWeight == 0 && options.KeywordWeight == 0 {
+		options.VectorWeight = 0.5
+		options.KeywordWeight = 0.5
+	}
+
+	query, err := st.chunkQueryScoped(ctx, chunkSearchQuery(options.Query, options.DocumentQuery))
+
+	if err != nil {
+		return nil, err
+	}
+
+	vectorRanked := []scoredID{}
+
+	if len(queryEmbedding) > 0 {
+		var err error
+
+		vectorRanked, err = st.chunkSearchSimilar(ctx, queryEmbedding, ChunkSearchOptions{
+			Limit:          options.CandidateLimit,
+			Query:          query,
+			DistanceMetric: options.DistanceMetric,
+			VectorSpace:    options.VectorSpace,
+			EmbeddingModel: options.EmbeddingModel,
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keywordRanked := []scoredID{}
+
+	if text != "" {
+		if st.tableDocumentChunkTerm == "" {
+			return nil, errors.New("keyword search is not enabled")
+		}
+
+		var err error
+
+		keywordRanked, err = st.chunkSearchKeyword(ctx, text, options.CandidateLimit, query)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var fused map[string]float64
+
+	if options.Fusion == FUSION_WEIGHTED {
+		fused = fuseWeighted([]float64{options.VectorWeight, options.KeywordWeight}, vectorRanked, keywordRanked)
+	} else {
+		fused = fuseReciprocalRank(options.RRFK, vectorRanked, keywordRanked)
+	}
+
+	top := newTopK(options.Limit)
+
+	for id, score := range fused {
+		top.Push(id, score)
+	}
+
+	results, err := st.chunkSearchResults(ctx, top.Sorted())
+
+	if err != nil {
+		return nil, err
+	}
+
+	vectorRanks := scoredIDRanks(vectorRanked)
+	keywordRanks := scoredIDRanks(keywordRanked)
+
+	hybridResults := make([]ChunkHybridSearchResult, 0, len(results))
+
+	for _, result := range results {
+		hybridResult := ChunkHybridSearchResult{
+			Chunk: result.Chunk,
+			Score: result.Score,
+		}
+
+		if rank, found := vectorRanks[result.Chunk.ID()]; found {
+			hybridResult.VectorRank = rank + 1
+			hybridResult.VectorScore = vectorRanked[rank].score
+		}
+
+		if rank, found := keywordRanks[result.Chunk.ID()]; found {
+			hybridResult.KeywordRank = rank + 1
+			hybridResult.KeywordScore = keywordRanked[rank].score
+		}
+
+		hybridResults = append(hybridResults, hybridResult)
+	}
+
+	return hybridResults, nil
+}
+
+// scoredIDRanks maps the IDs of a ranked list to their 0-based rank
+func scoredIDRanks(ranked []scoredID) map[string]int {
+	ranks := make(map[string]int, len(ranked))
+
+	for i, item := range ranked {
+		ranks[item.id] = i
+	}
+
+	return ranks
+}
@@ -0,0 +1,116 @@
+package ragstore
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestFuseReciprocalRank(t *testing.T) {
+	fused := fuseReciprocalRank(60,
+		[]scoredID{{id: "a", score: 0.9}, {id: "b", score: 0.8}},
+		[]scoredID{{id: "b", score: 12}, {id: "c", score: 3}},
+	)
+
+	if math.Abs(fused["b"]-(1.0/62+1.0/61)) > 1e-9 {
+		t.Fatalf("Unexpected fused score for b: %f", fused["b"])
+	}
+
+	if fused["b"] <= fused["a"] || fused["a"] <= fused["c"] {
+		t.Fatalf("Expected b to rank above a, and a above c, got %v", fused)
+	}
+}
+
+func TestFuseWeighted(t *testing.T) {
+	fused := fuseWeighted([]float64{0.3, 0.7},
+		[]scoredID{{id: "a", score: 0.9}, {id: "b", score: 0.5}},
+		[]scoredID{{id: "b", score: 12}, {id: "c", score: 2}},
+	)
+
+	if math.Abs(fused["a"]-0.3) > 1e-9 || math.Abs(fused["b"]-0.7) > 1e-9 || fused["c"] != 0 {
+		t.Fatalf("Unexpected fused scores: %v", fused)
+	}
+}
+
+func TestStore_ChunkSearchHybrid(t *testing.T) {
+	store := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+	})
+
+	chunk1 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("Resetting the router restores the factory settings.").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	chunk2 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("Error RT-503 means the router lost its uplink.").
+		SetEmbedding([]float32{0.6, 0.8})
+
+	chunk3 := NewChunk().
+		SetDocumentID(testDocument_O2).
+		SetChunkIndex(1).
+		SetContent("Unrelated text about printers.").
+		SetEmbedding([]float32{0.0, 1.0})
+
+	for _, chunk := range []ChunkInterface{chunk1, chunk2, chunk3} {
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	for _, fusion := range []string{FUSION_RRF, FUSION_WEIGHTED} {
+		results, err := store.ChunkSearchHybrid(context.Background(), "RT-503", []float32{0.9, 0.4}, ChunkHybridSearchOptions{
+			Limit:  2,
+			Fusion: fusion,
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 results, got %d", len(results))
+		}
+
+		// chunk 2 is found by both searches
+		if results[0].Chunk.ID() != chunk2.ID() {
+			t.Fatalf("Expected chunk 2 first with %s fusion, got %s", fusion, results[0].Chunk.Content())
+		}
+
+		if results[0].VectorRank != 2 || results[0].KeywordRank != 1 || results[0].KeywordScore <= 0 {
+			t.Fatalf("Unexpected per search ranks for chunk 2: %+v", results[0])
+		}
+
+		if results[1].Chunk.ID() != chunk1.ID() || results[1].KeywordRank != 0 || results[1].VectorRank != 1 {
+			t.Fatalf("Expected chunk 1 second, found by similarity only: %+v", results[1])
+		}
+
+		if results[0].Score < results[1].Score {
+			t.Fatal("Expected results to be ordered by fused score, highest first")
+		}
+	}
+
+	// Keyword only
+	results, err := store.ChunkSearchHybrid(context.Background(), "printers", nil, ChunkHybridSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunk3.ID() || results[0].VectorRank != 0 {
+		t.Fatal("Expected only chunk 3 from the keyword search")
+	}
+
+	if _, err := store.ChunkSearchHybrid(context.Background(), "", nil, ChunkHybridSearchOptions{}); err == nil {
+		t.Fatal("expected error for empty query, but got nil")
+	}
+
+	if _, err := store.ChunkSearchHybrid(context.Background(), "router", nil, ChunkHybridSearchOptions{Fusion: "max"}); err == nil {
+		t.Fatal("expected error for unsupported fusion, but got nil")
+	}
+}
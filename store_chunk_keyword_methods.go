@@ -0,0 +1,452 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dracory/base/database"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+// chunkKeywordInsertBatchSize is the number of term rows inserted per
+// statement, kept well below the bind parameter limits of the databases
+const chunkKeywordInsertBatchSize = 200
+
+// chunkKeywordIndexRebuildBatchSize is the number of chunks read per batch
+// while rebuilding the keyword index
+const chunkKeywordIndexRebuildBatchSize = 500
+
+// ChunkSearchKeyword returns the chunks matching the terms of the text,
+// ranked by BM25, highest score first. Matching is case insensitive,
+// on whole terms, so product codes, error codes and names are found
+// exactly, where similarity search often fails.
+func (st *store) ChunkSearchKeyword(ctx context.Context, text string, options ChunkKeywordSearchOptions) ([]ChunkSearchResult, error) {
+	if st.db == nil {
+		return nil, errors.New("database is not initialized")
+	}
+
+	if st.tableDocumentChunkTerm == "" {
+		return nil, errors.New("keyword search is not enabled")
+	}
+
+	if options.Limit < 0 {
+		return nil, errors.New("search limit cannot be negative")
+	}
+
+	if options.Limit == 0 {
+		options.Limit = 10
+	}
+
+	query, err := st.chunkQueryScoped(ctx, chunkSearchQuery(options.Query, options.DocumentQuery))
+
+	if err != nil {
+		return nil, err
+	}
+
+	ranked, err := st.chunkSearchKeyword(ctx, text, options.Limit, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return st.chunkSearchResults(ctx, ranked)
+}
+
+// ChunkKeywordIndexRebuild rebuilds the keyword index from the content
+// of all chunks (including soft deleted ones), i.e. for chunks created
+// before keyword search was enabled. The index is rebuilt in a single
+// transaction, so a failed rebuild leaves the previous index in place.
+//
+// Returns the number of indexed chunks.
+func (st *store) ChunkKeywordIndexRebuild(ctx context.Context) (int64, error) {
+	if st.db == nil {
+		return 0, errors.New("database is not initialized")
+	}
+
+	if st.tableDocumentChunkTerm == "" {
+		return 0, errors.New("keyword search is not enabled")
+	}
+
+	indexed := int64(0)
+
+	err := st.withTx(ctx, func(txStore *store) error {
+		var err error
+		indexed, err = txStore.chunkKeywordIndexRebuild(ctx)
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return indexed, nil
+}
+
+// chunkKeywordIndexRebuild replaces the terms of the keyword index with
+// the terms of all chunks
+func (st *store) chunkKeywordIndexRebuild(ctx context.Context) (int64, error) {
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Delete(st.tableDocumentChunkTerm).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	if _, err := database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+		return 0, err
+	}
+
+	indexed := int64(0)
+	cursor := ""
+
+	for {
+		sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+			From(st.tableDocumentChunk).
+			Select(COLUMN_ID, COLUMN_CONTENT).
+			Where(goqu.C(COLUMN_ID).Gt(cursor)).
+			Order(goqu.C(COLUMN_ID).Asc()).
+			Limit(chunkKeywordIndexRebuildBatchSize).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return indexed, err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+		if err != nil {
+			return indexed, err
+		}
+
+		for _, row := range rows {
+			if err := st.chunkKeywordTermsInsert(ctx, row[COLUMN_ID], row[COLUMN_CONTENT]); err != nil {
+				return indexed, err
+			}
+
+			indexed++
+		}
+
+		if len(rows) < chunkKeywordIndexRebuildBatchSize {
+			return indexed, nil
+		}
+
+		cursor = rows[len(rows)-1][COLUMN_ID]
+	}
+}
+
+// chunkSearchKeyword scores the chunks matching the query, which contain
+// any of the terms of the text, and returns the top ranked chunk IDs.
+//
+// The BM25 statistics are computed over the chunks, which are not soft
+// deleted, in the owner scope, so the scores do not depend on, nor reveal
+// the chunks of other owners.
+func (st *store) chunkSearchKeyword(ctx context.Context, text string, limit int, query ChunkQueryInterface) ([]scoredID, error) {
+	terms := lo.Uniq(keywordTerms(text))
+
+	if len(terms) < 1 {
+		return []scoredID{}, nil
+	}
+
+	if query == nil {
+		query = ChunkQuery()
+	}
+
+	corpus, err := st.chunkQueryScoped(ctx, ChunkQuery())
+
+	if err != nil {
+		return nil, err
+	}
+
+	corpusIDs, err := st.chunkKeywordIDs(corpus)
+
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, averageLength, err := st.chunkKeywordStats(ctx, corpusIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	idfs, err := st.chunkKeywordIDFs(ctx, terms, chunks, corpusIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	chunkIDs, err := st.chunkKeywordIDs(query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scores := map[string]float64{}
+
+	// the terms are matched in batches, to stay within the bind parameter
+	// limit of the database for long texts
+	for _, batch := range lo.Chunk(terms, st.chunkBulkBatchSize(1)) {
+		sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+			From(st.tableDocumentChunkTerm).
+			Select(COLUMN_CHUNK_ID, COLUMN_TERM, COLUMN_TERM_FREQUENCY, COLUMN_CHUNK_LENGTH).
+			Where(goqu.C(COLUMN_TERM).In(batch)).
+			Where(goqu.C(COLUMN_CHUNK_ID).In(chunkIDs)).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		rows, err := database.Query(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for rows.Next() {
+			var chunkID, term string
+			var termFrequency, chunkLength int
+
+			if err := rows.Scan(&chunkID, &term, &termFrequency, &chunkLength); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			scores[chunkID] += bm25TermScore(idfs[term], termFrequency, chunkLength, averageLength)
+		}
+
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	top := newTopK(limit)
+
+	for chunkID, score := range scores {
+		top.Push(chunkID, score)
+	}
+
+	return top.Sorted(), nil
+}
+
+// chunkKeywordIDs returns the subquery of the IDs of the chunks matching
+// the query
+func (st *store) chunkKeywordIDs(query ChunkQueryInterface) (*goqu.SelectDataset, error) {
+	q, _, err := query.ToSelectDataset(st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return q.
+		ClearLimit().
+		ClearOffset().
+		ClearOrder().
+		Select(COLUMN_ID), nil
+}
+
+// chunkKeywordStats returns the number of indexed chunks of the corpus and
+// their average length in terms
+func (st *store) chunkKeywordStats(ctx context.Context, corpusIDs *goqu.SelectDataset) (int64, float64, error) {
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		From(st.tableDocumentChunkTerm).
+		Select(
+			goqu.COUNT(goqu.DISTINCT(COLUMN_CHUNK_ID)).As("chunks"),
+			goqu.SUM(COLUMN_TERM_FREQUENCY).As("terms"),
+		).
+		Where(goqu.C(COLUMN_CHUNK_ID).In(corpusIDs)).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if len(rows) < 1 {
+		return 0, 0, nil
+	}
+
+	chunks := cast.ToInt64(rows[0]["chunks"])
+
+	if chunks < 1 {
+		return 0, 0, nil
+	}
+
+	return chunks, cast.ToFloat64(rows[0]["terms"]) / float64(chunks), nil
+}
+
+// chunkKeywordIDFs returns the inverse document frequency of each term
+// within the corpus
+func (st *store) chunkKeywordIDFs(ctx context.Context, terms []string, chunks int64, corpusIDs *goqu.SelectDataset) (map[string]float64, error) {
+	idfs := map[string]float64{}
+
+	for _, batch := range lo.Chunk(terms, st.chunkBulkBatchSize(1)) {
+		sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+			From(st.tableDocumentChunkTerm).
+			Select(COLUMN_TERM, goqu.COUNT(goqu.Star()).As("frequency")).
+			Where(goqu.C(COLUMN_TERM).In(batch)).
+			Where(goqu.C(COLUMN_CHUNK_ID).In(corpusIDs)).
+			GroupBy(COLUMN_TERM).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			idfs[row[COLUMN_TERM]] = bm25IDF(chunks, cast.ToInt64(row["frequency"]))
+		}
+	}
+
+	return idfs, nil
+}
+
+// chunkKeywordIndex brings the keyword index up to date with the
+// content of the written chunk
+func (st *store) chunkKeywordIndex(ctx context.Context, chunk ChunkInterface) error {
+	return st.chunkKeywordIndexMany(ctx, []ChunkInterface{chunk})
+}
+
+// chunkKeywordIndexMany brings the keyword index up to date with the
+// content of the written chunks, inserting their terms in batches
+func (st *store) chunkKeywordIndexMany(ctx context.Context, chunks []ChunkInterface) error {
+	if st.tableDocumentChunkTerm == "" || len(chunks) < 1 {
+		return nil
+	}
+
+	chunkIDs := lo.Map(chunks, func(chunk ChunkInterface, _ int) string {
+		return chunk.ID()
+	})
+
+	if err := st.chunkKeywordRemove(ctx, chunkIDs...); err != nil {
+		return err
+	}
+
+	records := []any{}
+
+	for _, chunk := range chunks {
+		records = append(records, chunkKeywordTermRecords(chunk.ID(), chunk.Content())...)
+	}
+
+	return st.chunkKeywordRecordsInsert(ctx, records)
+}
+
+// chunkKeywordTermsInsert adds the terms of the chunk content to the
+// keyword index
+func (st *store) chunkKeywordTermsInsert(ctx context.Context, chunkID string, content string) error {
+	return st.chunkKeywordRecordsInsert(ctx, chunkKeywordTermRecords(chunkID, content))
+}
+
+// chunkKeywordTermRecords returns the term table records of the chunk
+// content
+func chunkKeywordTermRecords(chunkID string, content string) []any {
+	terms := keywordTerms(content)
+	frequencies := keywordTermFrequencies(terms)
+
+	records := make([]any, 0, len(frequencies))
+
+	for term, frequency := range frequencies {
+		records = append(records, goqu.Record{
+			COLUMN_CHUNK_ID:       chunkID,
+			COLUMN_TERM:           term,
+			COLUMN_TERM_FREQUENCY: frequency,
+			COLUMN_CHUNK_LENGTH:   len(terms),
+		})
+	}
+
+	return records
+}
+
+// chunkKeywordRecordsInsert inserts the term table records in batches
+func (st *store) chunkKeywordRecordsInsert(ctx context.Context, records []any) error {
+	for _, batch := range lo.Chunk(records, chunkKeywordInsertBatchSize) {
+		sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+			Insert(st.tableDocumentChunkTerm).
+			Prepared(true).
+			Rows(batch...).
+			ToSQL()
+
+		if err != nil {
+			return err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		if _, err := database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chunkKeywordRemove removes the chunks from the keyword index
+func (st *store) chunkKeywordRemove(ctx context.Context, chunkIDs ...string) error {
+	if st.tableDocumentChunkTerm == "" || len(chunkIDs) < 1 {
+		return nil
+	}
+
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Delete(st.tableDocumentChunkTerm).
+		Prepared(true).
+		Where(goqu.C(COLUMN_CHUNK_ID).In(chunkIDs)).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	return err
+}
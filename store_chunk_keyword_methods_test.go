@@ -0,0 +1,302 @@
+package ragstore
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestStore_ChunkSearchKeyword(t *testing.T) {
+	store := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+	})
+
+	chunk1 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("The printer shows error E-4012 when the paper tray is empty.")
+
+	chunk2 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("Replace the paper tray. The paper tray fits model PX-200 only.")
+
+	chunk3 := NewChunk().
+		SetDocumentID(testDocument_O2).
+		SetChunkIndex(1).
+		SetContent("Model PX-300 has no paper tray.")
+
+	for _, chunk := range []ChunkInterface{chunk1, chunk2, chunk3} {
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	results, err := store.ChunkSearchKeyword(context.Background(), "e-4012", ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunk1.ID() {
+		t.Fatal("Expected only chunk 1 to match the error code")
+	}
+
+	results, err = store.ChunkSearchKeyword(context.Background(), "paper tray", ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+
+	if results[0].Chunk.ID() != chunk2.ID() {
+		t.Fatalf("Expected the chunk with the most occurrences first, got %s", results[0].Chunk.Content())
+	}
+
+	if results[0].Score < results[1].Score || results[1].Score < results[2].Score {
+		t.Fatal("Expected results to be ordered by score, highest first")
+	}
+
+	// Test filtering by document ID
+	results, err = store.ChunkSearchKeyword(context.Background(), "paper tray", ChunkKeywordSearchOptions{
+		Query: ChunkQuery().SetDocumentID(testDocument_O2),
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunk3.ID() {
+		t.Fatal("Expected only chunk 3 to be returned")
+	}
+}
+
+func TestStore_ChunkSearchKeywordStaysInSync(t *testing.T) {
+	store := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+	})
+
+	chunk1 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("alpha beta")
+
+	chunk2 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("gamma")
+
+	for _, chunk := range []ChunkInterface{chunk1, chunk2} {
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	search := func(text string) []ChunkSearchResult {
+		results, err := store.ChunkSearchKeyword(context.Background(), text, ChunkKeywordSearchOptions{})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		return results
+	}
+
+	chunk1.SetContent("delta")
+
+	if err := store.ChunkUpdate(context.Background(), chunk1); err != nil {
+		t.Fatal("unexpected error on update:", err)
+	}
+
+	if len(search("alpha")) != 0 {
+		t.Fatal("Expected the old content to be removed from the index")
+	}
+
+	if results := search("delta"); len(results) != 1 || results[0].Chunk.ID() != chunk1.ID() {
+		t.Fatal("Expected the updated content to be indexed")
+	}
+
+	if err := store.ChunkSoftDelete(context.Background(), chunk1); err != nil {
+		t.Fatal("unexpected error on soft delete:", err)
+	}
+
+	if len(search("delta")) != 0 {
+		t.Fatal("Soft deleted chunk MUST NOT be returned")
+	}
+
+	if err := store.ChunkDelete(context.Background(), chunk2); err != nil {
+		t.Fatal("unexpected error on delete:", err)
+	}
+
+	if len(search("gamma")) != 0 {
+		t.Fatal("Deleted chunk MUST NOT be returned")
+	}
+}
+
+func TestStore_ChunkCreateKeywordIndexFails(t *testing.T) {
+	storeKeyword := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+	})
+
+	ctx := context.Background()
+
+	// the terms cannot be written without the term table
+	if _, err := storeKeyword.(*store).db.ExecContext(ctx, `DROP TABLE "document_chunk_term_table"`); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("alpha beta")
+
+	if err := storeKeyword.ChunkCreate(ctx, chunk); err == nil {
+		t.Fatal("Expected an error indexing the chunk")
+	}
+
+	count, err := storeKeyword.ChunkCount(ctx, ChunkQuery().SetID(chunk.ID()))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("Expected the chunk not to be created without its terms")
+	}
+}
+
+func TestStore_ChunkKeywordIndexRebuild(t *testing.T) {
+	db := initDB(":memory:")
+
+	storePlain, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("created before keyword search")
+
+	if err := storePlain.ChunkCreate(context.Background(), chunk); err != nil {
+		t.Fatal("unexpected error creating chunk:", err)
+	}
+
+	if _, err := storePlain.ChunkSearchKeyword(context.Background(), "keyword", ChunkKeywordSearchOptions{}); err == nil {
+		t.Fatal("expected error for disabled keyword search, but got nil")
+	}
+
+	storeKeyword, err := NewStore(NewStoreOptions{
+		DB:                         db,
+		TableDocumentName:          "document_table",
+		TableDocumentChunkName:     "document_chunk_table",
+		TableDocumentChunkTermName: "document_chunk_term_table",
+		AutomigrateEnabled:         true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	indexed, err := storeKeyword.ChunkKeywordIndexRebuild(context.Background())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if indexed != 1 {
+		t.Fatalf("Expected 1 indexed chunk, got %d", indexed)
+	}
+
+	results, err := storeKeyword.ChunkSearchKeyword(context.Background(), "keyword", ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunk.ID() {
+		t.Fatal("Expected the rebuilt index to find the chunk")
+	}
+
+	// Migrating again keeps the existing term table and indexes
+	if err := storeKeyword.AutoMigrate(context.Background()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestStore_ChunkSearchKeywordDocumentQuery(t *testing.T) {
+	storeKeyword := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+	})
+
+	for _, status := range []string{DOCUMENT_STATUS_ACTIVE, DOCUMENT_STATUS_INACTIVE} {
+		document := NewDocument().
+			SetStatus(status).
+			SetFileName(status + ".txt").
+			SetText("text")
+
+		chunk := NewChunk().
+			SetChunkIndex(0).
+			SetContent("error code E1234 in " + status)
+
+		if err := storeKeyword.DocumentCreateWithChunks(context.Background(), document, []ChunkInterface{chunk}); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	results, err := storeKeyword.ChunkSearchKeyword(context.Background(), "E1234", ChunkKeywordSearchOptions{
+		DocumentQuery: DocumentQuery().SetStatus(DOCUMENT_STATUS_ACTIVE),
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.Content() != "error code E1234 in active" {
+		t.Fatal("Expected only the chunk of the active document to be found")
+	}
+}
+
+func TestStore_ChunkSearchKeywordLongText(t *testing.T) {
+	storeKeyword := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+	})
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("error code E1234")
+
+	if err := storeKeyword.ChunkCreate(context.Background(), chunk); err != nil {
+		t.Fatal("unexpected error creating chunk:", err)
+	}
+
+	// more terms than the bind parameter limit of older SQLite builds
+	terms := []string{"E1234"}
+
+	for i := 0; i < 1500; i++ {
+		terms = append(terms, "term"+strconv.Itoa(i), "term"+strconv.Itoa(i))
+	}
+
+	results, err := storeKeyword.ChunkSearchKeyword(context.Background(), strings.Join(terms, " "), ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunk.ID() {
+		t.Fatal("Expected the chunk to be found by its error code")
+	}
+}
@@ -9,11 +9,12 @@ import (
 	"github.com/doug-martin/goqu/v9"
 	"github.com/dracory/base/database"
 	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
 	"github.com/samber/lo"
 )
 
 // ChunkCount counts the number of chunks that match the query
-func (st *store) ChunkCount(options ChunkQueryInterface) (int64, error) {
+func (st *store) ChunkCount(ctx context.Context, options ChunkQueryInterface) (int64, error) {
 	if st.db == nil {
 		return 0, errors.New("database is not initialized")
 	}
@@ -22,6 +23,12 @@ func (st *store) ChunkCount(options ChunkQueryInterface) (int64, error) {
 		return 0, errors.New("query is nil")
 	}
 
+	options, err := st.chunkQueryScoped(ctx, options)
+
+	if err != nil {
+		return -1, err
+	}
+
 	options.SetCountOnly(true)
 
 	q, _, err := options.ToSelectDataset(st)
@@ -44,7 +51,7 @@ func (st *store) ChunkCount(options ChunkQueryInterface) (int64, error) {
 		log.Println(sqlStr)
 	}
 
-	mapped, err := database.SelectToMapString(database.Context(context.Background(), st.db), sqlStr, sqlParams...)
+	mapped, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
 	if err != nil {
 		return -1, err
 	}
@@ -64,8 +71,9 @@ func (st *store) ChunkCount(options ChunkQueryInterface) (int64, error) {
 	return count, nil
 }
 
-// ChunkCreate creates a new chunk
-func (st *store) ChunkCreate(chunk ChunkInterface) error {
+// ChunkCreate creates a new chunk. Within an owner scope, the document of
+// the chunk must be in the scope.
+func (st *store) ChunkCreate(ctx context.Context, chunk ChunkInterface) error {
 	if st.db == nil {
 		return errors.New("database is not initialized")
 	}
@@ -74,15 +82,16 @@ func (st *store) ChunkCreate(chunk ChunkInterface) error {
 		return errors.New("chunk ID is required")
 	}
 
-	chunk.SetCreatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
-	chunk.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+	record, err := st.chunkCreateRecord(ctx, chunk)
 
-	data := chunk.Data()
+	if err != nil {
+		return err
+	}
 
 	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
 		Insert(st.tableDocumentChunk).
 		Prepared(true).
-		Rows(data).
+		Rows(record).
 		ToSQL()
 
 	if err != nil {
@@ -93,7 +102,27 @@ func (st *store) ChunkCreate(chunk ChunkInterface) error {
 		st.logger.Debug("Chat create query", "query", sqlStr, "params", sqlParams)
 	}
 
-	_, err = database.Execute(database.Context(context.Background(), st.db), sqlStr, sqlParams...)
+	err = st.withTx(ctx, func(txStore *store) error {
+		inScope, err := txStore.documentIDsInScope(ctx, []string{chunk.DocumentID()})
+
+		if err != nil {
+			return err
+		}
+
+		if len(inScope) < 1 {
+			return errors.New("chunk document is not in the owner scope")
+		}
+
+		if _, err := database.Execute(txStore.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+			return err
+		}
+
+		if err := txStore.chunkKeywordIndex(ctx, chunk); err != nil {
+			return err
+		}
+
+		return txStore.hnswChunkUpsert(chunk)
+	})
 
 	if err != nil {
 		return err
@@ -105,16 +134,16 @@ func (st *store) ChunkCreate(chunk ChunkInterface) error {
 }
 
 // ChunkDelete permanently deletes an chunk
-func (st *store) ChunkDelete(chunk ChunkInterface) error {
+func (st *store) ChunkDelete(ctx context.Context, chunk ChunkInterface) error {
 	if chunk == nil {
 		return errors.New("chunk is nil")
 	}
 
-	return st.ChunkDeleteByID(chunk.ID())
+	return st.ChunkDeleteByID(ctx, chunk.ID())
 }
 
 // ChunkDeleteByID permanently deletes an chunk by ID
-func (st *store) ChunkDeleteByID(id string) error {
+func (st *store) ChunkDeleteByID(ctx context.Context, id string) error {
 	if st.db == nil {
 		return errors.New("database is not initialized")
 	}
@@ -123,6 +152,12 @@ func (st *store) ChunkDeleteByID(id string) error {
 		return errors.New("chunk ID is required")
 	}
 
+	ids, err := st.chunkIDsInScope(ctx, []string{id})
+
+	if err != nil || len(ids) < 1 {
+		return err // chunks of other owners are left untouched
+	}
+
 	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
 		Delete(st.tableDocumentChunk).
 		Prepared(true).
@@ -137,16 +172,27 @@ func (st *store) ChunkDeleteByID(id string) error {
 		st.logger.Debug("Chat delete query", "query", sqlStr, "params", sqlParams)
 	}
 
-	_, err = database.Execute(database.Context(context.Background(), st.db), sqlStr, sqlParams...)
-	if err != nil {
-		return err
-	}
+	return st.withTx(ctx, func(txStore *store) error {
+		if _, err := database.Execute(txStore.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+			return err
+		}
 
-	return nil
+		if err := txStore.chunkKeywordRemove(ctx, id); err != nil {
+			return err
+		}
+
+		if err := txStore.chunkVectorsRemove(ctx, id); err != nil {
+			return err
+		}
+
+		txStore.hnswChunkRemove(id)
+
+		return nil
+	})
 }
 
 // ChunkExists checks if an chunk exists
-func (st *store) ChunkExists(id string) (bool, error) {
+func (st *store) ChunkExists(ctx context.Context, id string) (bool, error) {
 	if st.db == nil {
 		return false, errors.New("database is not initialized")
 	}
@@ -155,7 +201,7 @@ func (st *store) ChunkExists(id string) (bool, error) {
 		return false, errors.New("chunk ID is required")
 	}
 
-	count, err := st.ChunkCount(ChunkQuery().SetID(id))
+	count, err := st.ChunkCount(ctx, ChunkQuery().SetID(id))
 
 	if err != nil {
 		return false, err
@@ -165,7 +211,7 @@ func (st *store) ChunkExists(id string) (bool, error) {
 }
 
 // ChunkFindByID finds an chunk by ID
-func (st *store) ChunkFindByID(chunkID string) (ChunkInterface, error) {
+func (st *store) ChunkFindByID(ctx context.Context, chunkID string) (ChunkInterface, error) {
 	if st.db == nil {
 		return nil, errors.New("database is not initialized")
 	}
@@ -174,7 +220,7 @@ func (st *store) ChunkFindByID(chunkID string) (ChunkInterface, error) {
 		return nil, errors.New("chunk ID is required")
 	}
 
-	list, err := st.ChunkList(ChunkQuery().
+	list, err := st.ChunkList(ctx, ChunkQuery().
 		SetID(chunkID).
 		SetLimit(1))
 	if err != nil {
@@ -189,7 +235,7 @@ func (st *store) ChunkFindByID(chunkID string) (ChunkInterface, error) {
 }
 
 // ChunkList lists chunks based on the query
-func (st *store) ChunkList(query ChunkQueryInterface) ([]ChunkInterface, error) {
+func (st *store) ChunkList(ctx context.Context, query ChunkQueryInterface) ([]ChunkInterface, error) {
 	if st.db == nil {
 		return nil, errors.New("database is not initialized")
 	}
@@ -198,6 +244,12 @@ func (st *store) ChunkList(query ChunkQueryInterface) ([]ChunkInterface, error)
 		return nil, errors.New("query is nil")
 	}
 
+	query, err := st.chunkQueryScoped(ctx, query)
+
+	if err != nil {
+		return []ChunkInterface{}, err
+	}
+
 	q, columns, err := query.ToSelectDataset(st)
 
 	if err != nil {
@@ -214,7 +266,7 @@ func (st *store) ChunkList(query ChunkQueryInterface) ([]ChunkInterface, error)
 		log.Println(sqlStr)
 	}
 
-	modelMaps, err := database.SelectToMapString(database.Context(context.Background(), st.db), sqlStr, sqlParams...)
+	modelMaps, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
 
 	if err != nil {
 		return []ChunkInterface{}, err
@@ -230,30 +282,67 @@ func (st *store) ChunkList(query ChunkQueryInterface) ([]ChunkInterface, error)
 	return list, nil
 }
 
+// ChunkRestore restores a soft deleted chunk
+func (st *store) ChunkRestore(ctx context.Context, chunk ChunkInterface) error {
+	if chunk == nil {
+		return errors.New("chunk is nil")
+	}
+
+	chunk.SetSoftDeletedAt(sb.MAX_DATETIME)
+
+	return st.ChunkUpdate(ctx, chunk)
+}
+
+// ChunkRestoreByID restores a soft deleted chunk by ID
+func (st *store) ChunkRestoreByID(ctx context.Context, id string) error {
+	if st.db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	if id == "" {
+		return errors.New("chunk ID is required")
+	}
+
+	list, err := st.ChunkList(ctx, ChunkQuery().
+		SetID(id).
+		SetWithSoftDeleted(true).
+		SetLimit(1))
+
+	if err != nil {
+		return err
+	}
+
+	if len(list) < 1 {
+		return errors.New("chunk not found")
+	}
+
+	return st.ChunkRestore(ctx, list[0])
+}
+
 // ChunkSoftDelete soft deletes an chunk
-func (st *store) ChunkSoftDelete(chunk ChunkInterface) error {
+func (st *store) ChunkSoftDelete(ctx context.Context, chunk ChunkInterface) error {
 	if chunk == nil {
 		return errors.New("chunk is nil")
 	}
 
 	chunk.SetSoftDeletedAt(carbon.Now(carbon.UTC).ToDateTimeString())
 
-	return st.ChunkUpdate(chunk)
+	return st.ChunkUpdate(ctx, chunk)
 }
 
 // ChunkSoftDeleteByID soft deletes an chunk by ID
-func (st *store) ChunkSoftDeleteByID(id string) error {
-	chunk, err := st.ChunkFindByID(id)
+func (st *store) ChunkSoftDeleteByID(ctx context.Context, id string) error {
+	chunk, err := st.ChunkFindByID(ctx, id)
 
 	if err != nil {
 		return err
 	}
 
-	return st.ChunkSoftDelete(chunk)
+	return st.ChunkSoftDelete(ctx, chunk)
 }
 
 // ChunkUpdate updates an chunk
-func (st *store) ChunkUpdate(chunk ChunkInterface) error {
+func (st *store) ChunkUpdate(ctx context.Context, chunk ChunkInterface) error {
 	if st.db == nil {
 		return errors.New("database is not initialized")
 	}
@@ -266,8 +355,87 @@ func (st *store) ChunkUpdate(chunk ChunkInterface) error {
 		return errors.New("chunk ID is required")
 	}
 
+	err := st.withTx(ctx, func(txStore *store) error {
+		return txStore.chunkUpdate(ctx, chunk)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	chunk.MarkAsNotDirty()
+
+	return nil
+}
+
+// chunkCreateRecord sets the owner and timestamps of the new chunk, and
+// returns the record inserted into the chunk table
+func (st *store) chunkCreateRecord(ctx context.Context, chunk ChunkInterface) (goqu.Record, error) {
+	ownerID, err := st.ownerScopeApply(ctx, chunk.OwnerID())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ownerID != chunk.OwnerID() {
+		chunk.SetOwnerID(ownerID)
+	}
+
+	chunk.SetCreatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+	chunk.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
+
+	if err := st.chunkPrepareEmbedding(chunk); err != nil {
+		return nil, err
+	}
+
+	// a new chunk is pending, until it has an embedding
+	if chunk.Status() == "" || chunk.IsPending() {
+		embedding, err := chunk.EmbeddingE()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(embedding) > 0 {
+			chunk.MarkEmbedded()
+		} else {
+			chunk.MarkPending()
+		}
+	}
+
+	if err := validateChunkStatus(chunk.Status()); err != nil {
+		return nil, err
+	}
+
+	return st.chunkRecord(chunk.Data())
+}
+
+// chunkUpdate writes the changed data of the chunk, and brings the keyword
+// and HNSW indexes up to date. The chunk is left dirty for the caller to
+// mark, once the changes are final.
+func (st *store) chunkUpdate(ctx context.Context, chunk ChunkInterface) error {
+	ownerID, err := st.ownerScopeApply(ctx, chunk.OwnerID())
+
+	if err != nil {
+		return err
+	}
+
+	if ownerID != chunk.OwnerID() {
+		chunk.SetOwnerID(ownerID)
+	}
+
 	chunk.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString())
 
+	if _, changed := chunk.DataChanged()[COLUMN_EMBEDDING]; changed {
+		if err := st.chunkPrepareEmbedding(chunk); err != nil {
+			return err
+		}
+	}
+
+	if err := chunkStatusUpdate(chunk); err != nil {
+		return err
+	}
+
 	dataChanged := chunk.DataChanged()
 
 	delete(dataChanged, COLUMN_ID) // ID is not updateable
@@ -276,10 +444,37 @@ func (st *store) ChunkUpdate(chunk ChunkInterface) error {
 		return nil
 	}
 
+	ids, err := st.chunkIDsInScope(ctx, []string{chunk.ID()})
+
+	if err != nil {
+		return err
+	}
+
+	if len(ids) < 1 {
+		return errors.New("chunk not found")
+	}
+
+	record, err := st.chunkRecord(dataChanged)
+
+	if err != nil {
+		return err
+	}
+
+	// an embedding staged by ReembedAll is outdated by the new content
+	if _, contentChanged := dataChanged[COLUMN_CONTENT]; contentChanged {
+		record[COLUMN_EMBEDDING_NEXT] = nil
+		record[COLUMN_EMBEDDING_NEXT_MODEL] = nil
+	}
+
+	// soft deleting or restoring the chunk on its own ends the cascade
+	if _, softDeletedChanged := dataChanged[COLUMN_SOFT_DELETED_AT]; softDeletedChanged {
+		record[COLUMN_SOFT_DELETED_CASCADE] = nil
+	}
+
 	sqlStr, params, errSql := goqu.Dialect(st.dbDriverName).
 		Update(st.tableDocumentChunk).
 		Prepared(true).
-		Set(dataChanged).
+		Set(record).
 		Where(goqu.C(COLUMN_ID).Eq(chunk.ID())).
 		ToSQL()
 
@@ -291,11 +486,104 @@ func (st *store) ChunkUpdate(chunk ChunkInterface) error {
 		log.Println(sqlStr)
 	}
 
-	_, err := st.db.Exec(sqlStr, params...)
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, params...)
 
-	chunk.MarkAsNotDirty()
+	if err != nil {
+		return err
+	}
+
+	if _, contentChanged := dataChanged[COLUMN_CONTENT]; contentChanged {
+		if err := st.chunkKeywordIndex(ctx, chunk); err != nil {
+			return err
+		}
+
+		if err := st.chunkVectorsRemove(ctx, chunk.ID()); err != nil {
+			return err
+		}
+	}
+
+	_, embeddingChanged := dataChanged[COLUMN_EMBEDDING]
+	_, softDeletedChanged := dataChanged[COLUMN_SOFT_DELETED_AT]
+
+	if embeddingChanged || softDeletedChanged {
+		return st.hnswChunkUpsert(chunk)
+	}
+
+	return nil
+}
+
+// chunkStatusUpdate moves the chunk along its lifecycle, when its status
+// is not changed explicitly. A new embedding makes the chunk embedded, or
+// pending when the embedding is removed, and a content change without a
+// new embedding makes an embedded chunk stale.
+func chunkStatusUpdate(chunk ChunkInterface) error {
+	dataChanged := chunk.DataChanged()
+
+	if _, changed := dataChanged[COLUMN_STATUS]; changed {
+		return validateChunkStatus(chunk.Status())
+	}
+
+	_, embeddingChanged := dataChanged[COLUMN_EMBEDDING]
+	_, contentChanged := dataChanged[COLUMN_CONTENT]
+
+	if embeddingChanged {
+		embedding, err := chunk.EmbeddingE()
+
+		if err != nil {
+			return err
+		}
+
+		if len(embedding) > 0 {
+			chunk.MarkEmbedded()
+		} else {
+			chunk.MarkPending()
+		}
+	} else if contentChanged && chunk.IsEmbedded() {
+		chunk.MarkStale()
+	}
+
+	return nil
+}
+
+// chunkPrepareEmbedding validates the embedding of the chunk against the
+// store embedding options, records the configured model and normalizes the
+// embedding, before it is written
+func (st *store) chunkPrepareEmbedding(chunk ChunkInterface) error {
+	embedding, err := chunk.EmbeddingE()
+
+	if err != nil {
+		return err
+	}
+
+	if len(embedding) < 1 {
+		return nil
+	}
 
-	return err
+	if st.embeddingDimension > 0 && len(embedding) != st.embeddingDimension {
+		return errors.New("embedding dimension " + strconv.Itoa(len(embedding)) + " does not match the configured dimension " + strconv.Itoa(st.embeddingDimension))
+	}
+
+	if st.embeddingRejectNonFinite && !embeddingIsFinite(embedding) {
+		return errors.New("embedding contains NaN or infinite values")
+	}
+
+	if st.embeddingModel != "" {
+		// a new embedding without a model of its own is made with the
+		// configured model
+		if _, modelChanged := chunk.DataChanged()[COLUMN_EMBEDDING_MODEL]; !modelChanged {
+			chunk.SetEmbeddingModel(st.embeddingModel)
+		}
+
+		if chunk.EmbeddingModel() != st.embeddingModel {
+			return errors.New("embedding model " + chunk.EmbeddingModel() + " does not match the configured model " + st.embeddingModel)
+		}
+	}
+
+	if st.normalizeEmbeddings {
+		chunk.SetEmbedding(NormalizeEmbedding(embedding))
+	}
+
+	return nil
 }
 
 // func (store *store) incidentSelectQuery(options IncidentQueryInterface) (selectDataset *goqu.SelectDataset, columns []any, err error) {
@@ -1,6 +1,9 @@
 package ragstore
 
 import (
+	"context"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/gouniverse/sb"
@@ -33,23 +36,23 @@ func TestStore_ChunkCount(t *testing.T) {
 		SetContent("chunk 3").
 		SetEmbedding([]float32{7.0, 8.0, 9.0})
 
-	err = store.ChunkCreate(chunk1)
+	err = store.ChunkCreate(context.Background(), chunk1)
 	if err != nil {
 		t.Fatal("unexpected error creating chunk1:", err)
 	}
 
-	err = store.ChunkCreate(chunk2)
+	err = store.ChunkCreate(context.Background(), chunk2)
 	if err != nil {
 		t.Fatal("unexpected error creating chunk2:", err)
 	}
 
-	err = store.ChunkCreate(chunk3)
+	err = store.ChunkCreate(context.Background(), chunk3)
 	if err != nil {
 		t.Fatal("unexpected error creating chunk3:", err)
 	}
 
 	// Test counting all chunks
-	allCount, err := store.ChunkCount(ChunkQuery())
+	allCount, err := store.ChunkCount(context.Background(), ChunkQuery())
 	if err != nil {
 		t.Fatal("unexpected error counting all chunks:", err)
 	}
@@ -59,7 +62,7 @@ func TestStore_ChunkCount(t *testing.T) {
 	}
 
 	// Test counting by monitor ID
-	chunkCount, err := store.ChunkCount(ChunkQuery().SetDocumentID(testDocument_O1))
+	chunkCount, err := store.ChunkCount(context.Background(), ChunkQuery().SetDocumentID(testDocument_O1))
 	if err != nil {
 		t.Fatal("unexpected error counting monitor chunks:", err)
 	}
@@ -86,7 +89,7 @@ func TestStore_ChunkCreate(t *testing.T) {
 		SetContent("chunk 1").
 		SetEmbedding([]float32{1.0, 2.0, 3.0})
 
-	err = store.ChunkCreate(chunk)
+	err = store.ChunkCreate(context.Background(), chunk)
 
 	if err != nil {
 		t.Fatal("unexpected error:", err)
@@ -106,13 +109,13 @@ func TestStore_ChunkCreateDuplicate(t *testing.T) {
 		SetContent("chunk 1").
 		SetEmbedding([]float32{1.0, 2.0, 3.0})
 
-	err = store.ChunkCreate(chunk)
+	err = store.ChunkCreate(context.Background(), chunk)
 	if err != nil {
 		t.Fatal("unexpected error on first create:", err)
 	}
 
 	// Try to create the same chunk again
-	err = store.ChunkCreate(chunk)
+	err = store.ChunkCreate(context.Background(), chunk)
 	if err == nil {
 		t.Fatal("expected error for duplicate chunk, but got nil")
 	}
@@ -131,12 +134,12 @@ func TestStore_ChunkFindByID(t *testing.T) {
 		SetContent("chunk 1").
 		SetEmbedding([]float32{1.0, 2.0, 3.0})
 
-	err = store.ChunkCreate(chunk)
+	err = store.ChunkCreate(context.Background(), chunk)
 	if err != nil {
 		t.Error("unexpected error:", err)
 	}
 
-	chunkFound, errFind := store.ChunkFindByID(chunk.ID())
+	chunkFound, errFind := store.ChunkFindByID(context.Background(), chunk.ID())
 
 	if errFind != nil {
 		t.Fatal("unexpected error:", errFind)
@@ -158,7 +161,7 @@ func TestStore_ChunkFindByIDNotFound(t *testing.T) {
 		t.Fatal("unexpected error:", err)
 	}
 
-	chunkFound, errFind := store.ChunkFindByID("non-existent-id")
+	chunkFound, errFind := store.ChunkFindByID(context.Background(), "non-existent-id")
 
 	if errFind != nil {
 		t.Fatal("unexpected error:", errFind)
@@ -180,7 +183,7 @@ func TestStore_ChunkFindByIDNotFound(t *testing.T) {
 // 		SetStatus(CHAT_STATUS_ACTIVE)
 
 // 	// Try to update a non-existent document
-// 	err = store.DocumentUpdate(document)
+// 	err = store.DocumentUpdate(context.Background(), document)
 // 	if err == nil {
 // 		t.Fatal("expected error for updating non-existent document, but got nil")
 // 	}
@@ -199,19 +202,19 @@ func TestStore_ChunkDelete(t *testing.T) {
 		SetContent("chunk 1").
 		SetEmbedding([]float32{1.0, 2.0, 3.0})
 
-	err = store.ChunkCreate(chunk)
+	err = store.ChunkCreate(context.Background(), chunk)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
 	// Delete the chunk
-	err = store.ChunkDelete(chunk)
+	err = store.ChunkDelete(context.Background(), chunk)
 	if err != nil {
 		t.Fatal("unexpected error on delete:", err)
 	}
 
 	// Verify the chunk is deleted
-	deletedChunk, err := store.ChunkFindByID(chunk.ID())
+	deletedChunk, err := store.ChunkFindByID(context.Background(), chunk.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding deleted chunk:", err)
 	}
@@ -234,19 +237,19 @@ func TestStore_ChunkDeleteByID(t *testing.T) {
 		SetContent("chunk 1").
 		SetEmbedding([]float32{1.0, 2.0, 3.0})
 
-	err = store.ChunkCreate(chunk)
+	err = store.ChunkCreate(context.Background(), chunk)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
 	// Delete the chunk by ID
-	err = store.ChunkDeleteByID(chunk.ID())
+	err = store.ChunkDeleteByID(context.Background(), chunk.ID())
 	if err != nil {
 		t.Fatal("unexpected error on delete by ID:", err)
 	}
 
 	// Verify the chunk is deleted
-	deletedChunk, err := store.ChunkFindByID(chunk.ID())
+	deletedChunk, err := store.ChunkFindByID(context.Background(), chunk.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding deleted chunk:", err)
 	}
@@ -276,18 +279,18 @@ func TestStore_ChunkList(t *testing.T) {
 		SetContent("chunk 2").
 		SetEmbedding([]float32{4.0, 5.0, 6.0})
 
-	err = store.ChunkCreate(chunk1)
+	err = store.ChunkCreate(context.Background(), chunk1)
 	if err != nil {
 		t.Fatal("unexpected error creating chunk1:", err)
 	}
 
-	err = store.ChunkCreate(chunk2)
+	err = store.ChunkCreate(context.Background(), chunk2)
 	if err != nil {
 		t.Fatal("unexpected error creating chunk2:", err)
 	}
 
 	// Test listing all chunks
-	allChunks, err := store.ChunkList(ChunkQuery())
+	allChunks, err := store.ChunkList(context.Background(), ChunkQuery())
 	if err != nil {
 		t.Fatal("unexpected error listing all chunks:", err)
 	}
@@ -297,7 +300,7 @@ func TestStore_ChunkList(t *testing.T) {
 	}
 
 	// Test filtering by document ID
-	documentChunks, err := store.ChunkList(ChunkQuery().SetDocumentID(testDocument_O1))
+	documentChunks, err := store.ChunkList(context.Background(), ChunkQuery().SetDocumentID(testDocument_O1))
 	if err != nil {
 		t.Fatal("unexpected error listing document chunks:", err)
 	}
@@ -307,7 +310,7 @@ func TestStore_ChunkList(t *testing.T) {
 	}
 
 	// Test limit and offset
-	limitedChunks, err := store.ChunkList(ChunkQuery().SetLimit(1))
+	limitedChunks, err := store.ChunkList(context.Background(), ChunkQuery().SetLimit(1))
 	if err != nil {
 		t.Fatal("unexpected error listing limited chunks:", err)
 	}
@@ -316,7 +319,7 @@ func TestStore_ChunkList(t *testing.T) {
 		t.Fatalf("Expected 1 chunk with limit, got %d", len(limitedChunks))
 	}
 
-	offsetChunks, err := store.ChunkList(ChunkQuery().SetOffset(1).SetLimit(2))
+	offsetChunks, err := store.ChunkList(context.Background(), ChunkQuery().SetOffset(1).SetLimit(2))
 	if err != nil {
 		t.Fatal("unexpected error listing offset chunks:", err)
 	}
@@ -326,6 +329,25 @@ func TestStore_ChunkList(t *testing.T) {
 	}
 }
 
+func TestStore_ChunkListCanceledContext(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.ChunkList(ctx, ChunkQuery()); err == nil {
+		t.Fatal("expected error for canceled context, but got nil")
+	}
+
+	if _, err := store.ChunkCount(ctx, ChunkQuery()); err == nil {
+		t.Fatal("expected error for canceled context, but got nil")
+	}
+}
+
 func TestStore_ChunkSoftDelete(t *testing.T) {
 	store, err := initStore(":memory:")
 
@@ -339,19 +361,19 @@ func TestStore_ChunkSoftDelete(t *testing.T) {
 		SetContent("chunk 1").
 		SetEmbedding([]float32{1.0, 2.0, 3.0})
 
-	err = store.ChunkCreate(chunk)
+	err = store.ChunkCreate(context.Background(), chunk)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
 	// Soft delete the chunk
-	err = store.ChunkSoftDelete(chunk)
+	err = store.ChunkSoftDelete(context.Background(), chunk)
 	if err != nil {
 		t.Fatal("unexpected error on soft delete:", err)
 	}
 
 	// Verify the chunk is soft deleted (not found by default)
-	softDeletedChunk, err := store.ChunkFindByID(chunk.ID())
+	softDeletedChunk, err := store.ChunkFindByID(context.Background(), chunk.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding soft deleted chunk:", err)
 	}
@@ -366,7 +388,7 @@ func TestStore_ChunkSoftDelete(t *testing.T) {
 		SetID(chunk.ID()).
 		SetLimit(1)
 
-	chunkFindWithDeleted, err := store.ChunkList(query)
+	chunkFindWithDeleted, err := store.ChunkList(context.Background(), query)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
@@ -397,19 +419,19 @@ func TestStore_ChunkSoftDeleteByID(t *testing.T) {
 		SetContent("chunk 1").
 		SetEmbedding([]float32{1.0, 2.0, 3.0})
 
-	err = store.ChunkCreate(chunk)
+	err = store.ChunkCreate(context.Background(), chunk)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
 	// Soft delete the chunk by ID
-	err = store.ChunkSoftDeleteByID(chunk.ID())
+	err = store.ChunkSoftDeleteByID(context.Background(), chunk.ID())
 	if err != nil {
 		t.Fatal("unexpected error on soft delete by ID:", err)
 	}
 
 	// Verify the chunk is soft deleted (not found by default)
-	softDeletedChunk, err := store.ChunkFindByID(chunk.ID())
+	softDeletedChunk, err := store.ChunkFindByID(context.Background(), chunk.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding soft deleted chunk:", err)
 	}
@@ -424,7 +446,7 @@ func TestStore_ChunkSoftDeleteByID(t *testing.T) {
 		SetID(chunk.ID()).
 		SetLimit(1)
 
-	chunkFindWithDeleted, err := store.ChunkList(query)
+	chunkFindWithDeleted, err := store.ChunkList(context.Background(), query)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
@@ -455,7 +477,7 @@ func TestStore_ChunkUpdate(t *testing.T) {
 		SetContent("chunk 1").
 		SetEmbedding([]float32{1.0, 2.0, 3.0})
 
-	err = store.ChunkCreate(chunk)
+	err = store.ChunkCreate(context.Background(), chunk)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
@@ -463,13 +485,13 @@ func TestStore_ChunkUpdate(t *testing.T) {
 	// Update the chunk
 	chunk.SetDocumentID(testDocument_O2)
 
-	err = store.ChunkUpdate(chunk)
+	err = store.ChunkUpdate(context.Background(), chunk)
 	if err != nil {
 		t.Fatal("unexpected error on update:", err)
 	}
 
 	// Verify the update
-	updatedChunk, err := store.ChunkFindByID(chunk.ID())
+	updatedChunk, err := store.ChunkFindByID(context.Background(), chunk.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding updated chunk:", err)
 	}
@@ -478,3 +500,397 @@ func TestStore_ChunkUpdate(t *testing.T) {
 		t.Fatalf("Text not updated. Expected 'Chunk 2', got '%s'", updatedChunk.DocumentID())
 	}
 }
+
+func TestStore_ChunkRestoreByID(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.0, 2.0, 3.0})
+
+	err = store.ChunkCreate(context.Background(), chunk)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.ChunkSoftDeleteByID(context.Background(), chunk.ID())
+	if err != nil {
+		t.Fatal("unexpected error on soft delete by ID:", err)
+	}
+
+	err = store.ChunkRestoreByID(context.Background(), chunk.ID())
+	if err != nil {
+		t.Fatal("unexpected error on restore by ID:", err)
+	}
+
+	restored, err := store.ChunkFindByID(context.Background(), chunk.ID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if restored == nil {
+		t.Fatal("Chunk should be found after restore")
+	}
+
+	if !strings.HasPrefix(restored.SoftDeletedAt(), sb.MAX_DATETIME) {
+		t.Fatal("Chunk should be restored, but SoftDeletedAt is:", restored.SoftDeletedAt())
+	}
+
+	if err := store.ChunkRestoreByID(context.Background(), "missing"); err == nil {
+		t.Fatal("expected error for missing chunk, but got nil")
+	}
+}
+
+func TestStore_ChunkMetas(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1")
+
+	err = chunk.SetMetas(map[string]string{
+		"page":    "12",
+		"section": "Installation",
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.ChunkCreate(context.Background(), chunk)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunkFound, err := store.ChunkFindByID(context.Background(), chunk.ID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	page, err := chunkFound.Meta("page")
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if page != "12" {
+		t.Fatal("Expected page meta to be 12, got:", page)
+	}
+
+	err = chunkFound.UpsertMetas(map[string]string{
+		"page":    "13",
+		"speaker": "Alice",
+	})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	err = store.ChunkUpdate(context.Background(), chunkFound)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunkFound, err = store.ChunkFindByID(context.Background(), chunk.ID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	metas, err := chunkFound.Metas()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(metas) != 3 || metas["page"] != "13" || metas["section"] != "Installation" || metas["speaker"] != "Alice" {
+		t.Fatal("Expected the upserted metas, got:", metas)
+	}
+}
+
+func TestStore_ChunkMetasEmpty(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1")
+
+	err = store.ChunkCreate(context.Background(), chunk)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunkFound, err := store.ChunkFindByID(context.Background(), chunk.ID())
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	metas, err := chunkFound.Metas()
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(metas) != 0 {
+		t.Fatal("Expected no metas, got:", metas)
+	}
+}
+
+func TestStore_ChunkListMetaFilters(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	metas := []map[string]string{
+		{"source": "confluence", "page": "1"},
+		{"source": "confluence", "page": "2", "speaker": "Alice"},
+		{"source": "jira", "page": "3"},
+		{},
+	}
+
+	chunks := []ChunkInterface{}
+
+	for i, meta := range metas {
+		chunk := NewChunk().
+			SetDocumentID(testDocument_O1).
+			SetChunkIndex(i).
+			SetContent("chunk")
+
+		if err := chunk.SetMetas(meta); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		chunks = append(chunks, chunk)
+	}
+
+	// a chunk without any metas stored
+	chunkNoMetas := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(4).
+		SetContent("chunk")
+
+	if err := store.ChunkCreate(context.Background(), chunkNoMetas); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	cases := []struct {
+		name     string
+		query    ChunkQueryInterface
+		expected []string
+	}{
+		{
+			name:     "equals",
+			query:    ChunkQuery().SetMetaEquals("source", "confluence"),
+			expected: []string{chunks[0].ID(), chunks[1].ID()},
+		},
+		{
+			name:     "equals several keys",
+			query:    ChunkQuery().SetMetaEquals("source", "confluence").SetMetaEquals("page", "2"),
+			expected: []string{chunks[1].ID()},
+		},
+		{
+			name:     "in",
+			query:    ChunkQuery().SetMetaIn("page", []string{"1", "3"}),
+			expected: []string{chunks[0].ID(), chunks[2].ID()},
+		},
+		{
+			name:     "exists",
+			query:    ChunkQuery().SetMetaExists("speaker"),
+			expected: []string{chunks[1].ID()},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			list, err := store.ChunkList(context.Background(), c.query.
+				SetOrderBy(COLUMN_CHUNK_INDEX).
+				SetOrderDirection(sb.ASC))
+
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			ids := []string{}
+
+			for _, chunk := range list {
+				ids = append(ids, chunk.ID())
+			}
+
+			if !slices.Equal(ids, c.expected) {
+				t.Fatalf("Expected chunks %v, got %v", c.expected, ids)
+			}
+		})
+	}
+
+	if _, err := store.ChunkList(context.Background(), ChunkQuery().SetMetaEquals(`so"urce`, "jira")); err == nil {
+		t.Fatal("expected error for meta key with quotes, but got nil")
+	}
+
+	if _, err := store.ChunkList(context.Background(), ChunkQuery().SetMetaIn("source", []string{})); err == nil {
+		t.Fatal("expected error for empty meta values, but got nil")
+	}
+}
+
+func TestChunkQueryCopyMetaFilters(t *testing.T) {
+	pages := []string{"1", "2"}
+
+	query := ChunkQuery().
+		SetMetaEquals("source", "confluence").
+		SetMetaIn("page", pages).
+		SetMetaExists("a").
+		SetMetaExists("b").
+		SetMetaExists("c")
+
+	copied := chunkQueryCopy(query).
+		SetMetaEquals("speaker", "Alice").
+		SetMetaIn("section", []string{"intro"}).
+		SetMetaExists("d")
+
+	query.SetMetaExists("e")
+	pages[0] = "3"
+
+	if len(query.GetMetaEquals()) != 1 || len(query.GetMetaIn()) != 1 {
+		t.Fatal("Expected the meta filters of the copy not to be added to the query, got", query.GetMetaEquals(), query.GetMetaIn())
+	}
+
+	if !slices.Equal(query.GetMetaIn()["page"], []string{"1", "2"}) {
+		t.Fatal("Expected the meta values to be copied, got", query.GetMetaIn()["page"])
+	}
+
+	if !slices.Equal(query.GetMetaExists(), []string{"a", "b", "c", "e"}) {
+		t.Fatal("Expected the meta keys of the query, got", query.GetMetaExists())
+	}
+
+	if !slices.Equal(copied.GetMetaExists(), []string{"a", "b", "c", "d"}) {
+		t.Fatal("Expected the meta keys of the copy, got", copied.GetMetaExists())
+	}
+
+	documentPages := []string{"1"}
+	documentQuery := DocumentQuery().SetMetaIn("page", documentPages)
+	documentPages[0] = "3"
+
+	if !slices.Equal(documentQuery.GetMetaIn()["page"], []string{"1"}) {
+		t.Fatal("Expected the document meta values to be copied, got", documentQuery.GetMetaIn()["page"])
+	}
+}
+
+func TestStore_ChunkStatusLifecycle(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(0).
+		SetContent("waiting")
+
+	if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !chunk.IsPending() {
+		t.Fatal("Expected a chunk without embedding to be pending, got", chunk.Status())
+	}
+
+	embedded := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("embedded").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	if err := store.ChunkCreate(context.Background(), embedded); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !embedded.IsEmbedded() {
+		t.Fatal("Expected a chunk with embedding to be embedded, got", embedded.Status())
+	}
+
+	pending, err := store.ChunkList(context.Background(), ChunkQuery().
+		SetStatusIn([]string{CHUNK_STATUS_PENDING, CHUNK_STATUS_FAILED}))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(pending) != 1 || pending[0].ID() != chunk.ID() {
+		t.Fatal("Expected only the pending chunk, got", len(pending))
+	}
+
+	// a failed embedding is recorded explicitly
+	pending[0].MarkFailed()
+
+	if err := store.ChunkUpdate(context.Background(), pending[0]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	count, err := store.ChunkCount(context.Background(), ChunkQuery().SetStatus(CHUNK_STATUS_FAILED))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 1 {
+		t.Fatal("Expected 1 failed chunk, got", count)
+	}
+
+	// the retry embeds it
+	pending[0].SetEmbedding([]float32{0.0, 1.0})
+
+	if !pending[0].IsEmbedded() {
+		t.Fatal("Expected setting the embedding to mark the chunk embedded, got", pending[0].Status())
+	}
+
+	if err := store.ChunkUpdate(context.Background(), pending[0]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !pending[0].IsEmbedded() {
+		t.Fatal("Expected the chunk to be embedded, got", pending[0].Status())
+	}
+
+	// changing the content without a new embedding makes it stale
+	pending[0].SetContent("changed")
+
+	if err := store.ChunkUpdate(context.Background(), pending[0]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	found, err := store.ChunkFindByID(context.Background(), chunk.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found == nil || !found.IsStale() {
+		t.Fatal("Expected the chunk to be stale")
+	}
+
+	if !found.MarkPending().IsPending() || !found.MarkStale().IsStale() {
+		t.Fatal("Expected the chunk to be marked pending, then stale")
+	}
+
+	found.SetStatus("unknown")
+
+	if err := store.ChunkUpdate(context.Background(), found); err == nil {
+		t.Fatal("Expected an error for an unsupported status")
+	}
+}
@@ -0,0 +1,499 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/dracory/base/database"
+	"github.com/dromara/carbon/v2"
+	"github.com/samber/lo"
+)
+
+// reembedLeaseName is the lease held by a re-embedding run or cutover
+const reembedLeaseName = "reembed"
+
+// ReembedOptions defines the options of a re-embedding run
+type ReembedOptions struct {
+	// Model is the name of the model the embedder embeds with, which is
+	// recorded on the chunks at the cutover (required)
+	Model string
+
+	// BatchSize is the number of chunks passed to the embedder per call
+	// (defaults to the store embedding batch size)
+	BatchSize int
+
+	// Progress is called after each batch is written
+	Progress func(progress ReembedProgress)
+
+	// Cutover switches the chunks to the new embeddings at the end of the
+	// run, when all chunks are re-embedded, see ReembedCutover
+	Cutover bool
+}
+
+// ReembedProgress is the progress of a re-embedding run
+type ReembedProgress struct {
+	// Reembedded is the number of chunks with a staged embedding of the
+	// model, including the chunks staged by earlier runs, or embedded with
+	// the model already
+	Reembedded int64
+
+	// Total is the number of chunks, soft deleted or not
+	Total int64
+}
+
+// ReembedAll re-embeds the contents of all chunks (including soft deleted
+// ones) with the embedder, when switching to another embedding model.
+//
+// The new embeddings are staged next to the current ones, which searches
+// keep using until the cutover. The chunks are read in ID order, in batches
+// written each in its own transaction. Chunks staged with the model already,
+// or embedded with it, are skipped, so an interrupted run resumes where it
+// stopped, when run again. Updating the content of a chunk drops its staged
+// embedding, and a chunk changed while its batch is embedded is left for
+// the next run.
+//
+// Only one run or cutover can be in progress at a time, across all
+// processes sharing the database, which requires the TableLeaseName option.
+// The run works across all owners, also within an owner scope.
+//
+// Returns the progress, also when an error stopped the run.
+func (st *store) ReembedAll(ctx context.Context, embedder Embedder, opts ReembedOptions) (ReembedProgress, error) {
+	progress := ReembedProgress{}
+
+	if st.db == nil {
+		return progress, errors.New("database is not initialized")
+	}
+
+	if embedder == nil {
+		return progress, errors.New("embedder is nil")
+	}
+
+	if opts.Model == "" {
+		return progress, errors.New("re-embedding model is required")
+	}
+
+	if opts.BatchSize < 0 {
+		return progress, errors.New("re-embedding batch size cannot be negative")
+	}
+
+	if opts.BatchSize == 0 {
+		opts.BatchSize = st.embeddingBatchSize
+	}
+
+	holder, acquired, err := st.leaseAcquire(ctx, reembedLeaseName)
+
+	if err != nil {
+		return progress, err
+	}
+
+	if !acquired {
+		return progress, errors.New("re-embedding is already running")
+	}
+
+	defer st.leaseRelease(ctx, reembedLeaseName, holder)
+
+	ctx = withOwnerScopeAll(ctx)
+
+	total, err := st.reembedCount(ctx, nil)
+
+	if err != nil {
+		return progress, err
+	}
+
+	pending, err := st.reembedCount(ctx, reembedPending(opts.Model))
+
+	if err != nil {
+		return progress, err
+	}
+
+	progress.Total = total
+	progress.Reembedded = total - pending
+
+	dimension := 0
+	cursor := ""
+
+	for {
+		rows, err := st.reembedBatch(ctx, opts.Model, cursor, opts.BatchSize)
+
+		if err != nil {
+			return progress, err
+		}
+
+		if len(rows) < 1 {
+			break
+		}
+
+		texts := lo.Map(rows, func(row map[string]string, _ int) string {
+			return row[COLUMN_CONTENT]
+		})
+
+		embeddings, err := embedder.Embed(ctx, texts)
+
+		if err != nil {
+			return progress, err
+		}
+
+		if len(embeddings) != len(rows) {
+			return progress, errors.New("embedder returned " + strconv.Itoa(len(embeddings)) + " embeddings for " + strconv.Itoa(len(rows)) + " texts")
+		}
+
+		for i, row := range rows {
+			embedding, err := st.embeddingPrepare(embeddings[i], &dimension)
+
+			if err != nil {
+				return progress, errors.New("chunk " + row[COLUMN_ID] + ": " + err.Error())
+			}
+
+			embeddings[i] = embedding
+		}
+
+		written, err := st.reembedWrite(ctx, opts.Model, rows, embeddings)
+
+		if err != nil {
+			return progress, err
+		}
+
+		progress.Reembedded += written
+
+		if err := st.leaseRenew(ctx, reembedLeaseName, holder); err != nil {
+			return progress, err
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+
+		if len(rows) < opts.BatchSize {
+			break
+		}
+
+		cursor = rows[len(rows)-1][COLUMN_ID]
+	}
+
+	if !opts.Cutover {
+		return progress, nil
+	}
+
+	return progress, st.reembedCutover(ctx, opts.Model)
+}
+
+// ReembedCutover switches all chunks to the embeddings of the model staged
+// by ReembedAll, in a single transaction, and rebuilds the HNSW index once
+// it commits. It fails, without changing any chunk, when a chunk has no
+// staged embedding of the model, i.e. one created or changed since the run.
+//
+// The store should be created with the new EmbeddingModel afterwards, as
+// searches by the previous model fail on the chunks of the new model.
+func (st *store) ReembedCutover(ctx context.Context, model string) error {
+	if st.db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	if model == "" {
+		return errors.New("re-embedding model is required")
+	}
+
+	holder, acquired, err := st.leaseAcquire(ctx, reembedLeaseName)
+
+	if err != nil {
+		return err
+	}
+
+	if !acquired {
+		return errors.New("re-embedding is already running")
+	}
+
+	defer st.leaseRelease(ctx, reembedLeaseName, holder)
+
+	return st.reembedCutover(withOwnerScopeAll(ctx), model)
+}
+
+// reembedCutover moves the staged embeddings of the model into place. The
+// chunks are read in ID order, in batches, but written in one transaction.
+func (st *store) reembedCutover(ctx context.Context, model string) error {
+	return st.withTx(ctx, func(txStore *store) error {
+		if err := txStore.reembedCutoverCheck(ctx, model); err != nil {
+			return err
+		}
+
+		dimension := 0
+		cursor := ""
+		updatedAt := carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)
+
+		for {
+			sqlStr, sqlParams, err := goqu.Dialect(txStore.dbDriverName).
+				From(txStore.tableDocumentChunk).
+				Select(COLUMN_ID, COLUMN_EMBEDDING_NEXT).
+				Where(
+					goqu.C(COLUMN_ID).Gt(cursor),
+					goqu.C(COLUMN_EMBEDDING_NEXT_MODEL).Eq(model),
+				).
+				Order(goqu.C(COLUMN_ID).Asc()).
+				Limit(uint(txStore.chunkBatchSize)).
+				Prepared(true).
+				ToSQL()
+
+			if err != nil {
+				return err
+			}
+
+			if txStore.debugEnabled {
+				log.Println(sqlStr)
+			}
+
+			rows, err := database.SelectToMapString(txStore.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+			if err != nil {
+				return err
+			}
+
+			for _, row := range rows {
+				if err := txStore.reembedCutoverChunk(ctx, model, row, &dimension, updatedAt); err != nil {
+					return errors.New("chunk " + row[COLUMN_ID] + ": " + err.Error())
+				}
+			}
+
+			if len(rows) < txStore.chunkBatchSize {
+				break
+			}
+
+			cursor = rows[len(rows)-1][COLUMN_ID]
+		}
+
+		// a chunk changed during the cutover lost its staged embedding
+		if err := txStore.reembedCutoverCheck(ctx, model); err != nil {
+			return err
+		}
+
+		return txStore.hnswApply(func() error {
+			return st.hnswRebuild(ctx)
+		})
+	})
+}
+
+// reembedCutoverCheck fails, when a chunk has no embedding of the model,
+// neither staged, nor switched to
+func (st *store) reembedCutoverCheck(ctx context.Context, model string) error {
+	pending, err := st.reembedCount(ctx, reembedPending(model))
+
+	if err != nil {
+		return err
+	}
+
+	if pending > 0 {
+		return errors.New("re-embedding is not complete, " + strconv.FormatInt(pending, 10) + " chunks have no embedding of model " + model)
+	}
+
+	return nil
+}
+
+// reembedCutoverChunk writes the staged embedding of the chunk as its
+// embedding, and clears the staging columns
+func (st *store) reembedCutoverChunk(ctx context.Context, model string, row map[string]string, dimension *int, updatedAt string) error {
+	embedding, err := embeddingFromString(row[COLUMN_EMBEDDING_NEXT])
+
+	if err != nil {
+		return err
+	}
+
+	if *dimension == 0 {
+		*dimension = len(embedding)
+	}
+
+	if len(embedding) != *dimension {
+		return errors.New("staged embedding dimension " + strconv.Itoa(len(embedding)) + " does not match the dimension " + strconv.Itoa(*dimension) + " of the other chunks")
+	}
+
+	chunk := NewChunkFromExistingData(map[string]string{COLUMN_ID: row[COLUMN_ID]}).
+		SetEmbedding(embedding).
+		SetEmbeddingModel(model).
+		SetUpdatedAt(updatedAt)
+
+	record, err := st.chunkRecord(chunk.DataChanged())
+
+	if err != nil {
+		return err
+	}
+
+	record[COLUMN_EMBEDDING_NEXT] = nil
+	record[COLUMN_EMBEDDING_NEXT_MODEL] = nil
+
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Update(st.tableDocumentChunk).
+		Prepared(true).
+		Set(record).
+		Where(
+			goqu.C(COLUMN_ID).Eq(row[COLUMN_ID]),
+			goqu.C(COLUMN_EMBEDDING_NEXT_MODEL).Eq(model),
+		).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	return err
+}
+
+// reembedBatch returns the ID and content of the next chunks after the
+// cursor, which have no embedding of the model, staged or not
+func (st *store) reembedBatch(ctx context.Context, model string, cursor string, batchSize int) ([]map[string]string, error) {
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		From(st.tableDocumentChunk).
+		Select(COLUMN_ID, COLUMN_CONTENT).
+		Where(
+			goqu.C(COLUMN_ID).Gt(cursor),
+			reembedPending(model),
+		).
+		Order(goqu.C(COLUMN_ID).Asc()).
+		Limit(uint(batchSize)).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	return database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+}
+
+// reembedWrite stages the new embeddings of the batch in a transaction,
+// skipping the chunks deleted or changed since the batch was read.
+// Returns the number of staged embeddings.
+func (st *store) reembedWrite(ctx context.Context, model string, rows []map[string]string, embeddings [][]float32) (int64, error) {
+	written := int64(0)
+
+	ids := lo.Map(rows, func(row map[string]string, _ int) string {
+		return row[COLUMN_ID]
+	})
+
+	err := st.withTx(ctx, func(txStore *store) error {
+		sqlStr, sqlParams, err := goqu.Dialect(txStore.dbDriverName).
+			From(txStore.tableDocumentChunk).
+			Select(COLUMN_ID, COLUMN_CONTENT).
+			Where(goqu.C(COLUMN_ID).In(ids)).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return err
+		}
+
+		if txStore.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		current, err := database.SelectToMapString(txStore.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+		if err != nil {
+			return err
+		}
+
+		contents := lo.SliceToMap(current, func(row map[string]string) (string, string) {
+			return row[COLUMN_ID], row[COLUMN_CONTENT]
+		})
+
+		for i, row := range rows {
+			content, exists := contents[row[COLUMN_ID]]
+
+			if !exists || content != row[COLUMN_CONTENT] {
+				continue
+			}
+
+			sqlStr, sqlParams, err := goqu.Dialect(txStore.dbDriverName).
+				Update(txStore.tableDocumentChunk).
+				Prepared(true).
+				Set(goqu.Record{
+					COLUMN_EMBEDDING_NEXT:       embeddingToBinary(embeddings[i]),
+					COLUMN_EMBEDDING_NEXT_MODEL: model,
+				}).
+				Where(goqu.C(COLUMN_ID).Eq(row[COLUMN_ID])).
+				ToSQL()
+
+			if err != nil {
+				return err
+			}
+
+			if txStore.debugEnabled {
+				log.Println(sqlStr)
+			}
+
+			if _, err := database.Execute(txStore.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+				return err
+			}
+
+			written++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return written, nil
+}
+
+// reembedCount counts the chunks, soft deleted or not, matching the
+// condition, or all chunks when it is nil
+func (st *store) reembedCount(ctx context.Context, condition exp.Expression) (int64, error) {
+	q := goqu.Dialect(st.dbDriverName).
+		From(st.tableDocumentChunk).
+		Select(goqu.COUNT(goqu.Star()).As("count"))
+
+	if condition != nil {
+		q = q.Where(condition)
+	}
+
+	sqlStr, sqlParams, err := q.Prepared(true).ToSQL()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if len(rows) < 1 {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(rows[0]["count"], 10, 64)
+}
+
+// reembedPending matches the chunks, which have no embedding of the model,
+// neither staged, nor switched to
+func reembedPending(model string) exp.Expression {
+	return goqu.And(
+		goqu.Or(
+			goqu.C(COLUMN_EMBEDDING_NEXT_MODEL).IsNull(),
+			goqu.C(COLUMN_EMBEDDING_NEXT_MODEL).Neq(model),
+		),
+		goqu.Or(
+			goqu.C(COLUMN_EMBEDDING_MODEL).IsNull(),
+			goqu.C(COLUMN_EMBEDDING_MODEL).Neq(model),
+		),
+	)
+}
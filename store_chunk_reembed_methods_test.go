@@ -0,0 +1,330 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func initReembedChunks(t *testing.T, store StoreInterface) []ChunkInterface {
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("invoice receipt contract")
+
+	contents := []string{"invoice", "receipt", "contract"}
+
+	embeddings, err := NewHashEmbedder(8).Embed(context.Background(), contents)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunks := []ChunkInterface{}
+
+	for index, content := range contents {
+		chunks = append(chunks, NewChunk().
+			SetChunkIndex(index).
+			SetContent(content).
+			SetEmbedding(embeddings[index]))
+	}
+
+	if err := store.DocumentCreateWithChunks(context.Background(), document, chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return chunks
+}
+
+// failingEmbedder fails, once it embedded the number of batches
+type failingEmbedder struct {
+	Embedder
+	batches int
+}
+
+func (e *failingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.batches < 1 {
+		return nil, errors.New("embedder failed")
+	}
+
+	e.batches--
+
+	return e.Embedder.Embed(ctx, texts)
+}
+
+func TestStore_ReembedAll(t *testing.T) {
+	storeReembed := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+		opts.EmbeddingModel = "small"
+		opts.TableLeaseName = "lease_table"
+	})
+	chunks := initReembedChunks(t, storeReembed)
+
+	ctx := context.Background()
+
+	progresses := []ReembedProgress{}
+
+	progress, err := storeReembed.ReembedAll(ctx, NewHashEmbedder(4), ReembedOptions{
+		Model:     "large",
+		BatchSize: 2,
+		Progress: func(progress ReembedProgress) {
+			progresses = append(progresses, progress)
+		},
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if progress.Reembedded != 3 || progress.Total != 3 {
+		t.Fatal("Expected 3 of 3 chunks re-embedded, got", progress.Reembedded, "of", progress.Total)
+	}
+
+	if len(progresses) != 2 || progresses[0].Reembedded != 2 {
+		t.Fatal("Expected progress after each of 2 batches, got", progresses)
+	}
+
+	// searches keep using the current embeddings until the cutover
+	query, _ := NewHashEmbedder(8).Embed(ctx, []string{"invoice"})
+
+	results, err := storeReembed.ChunkSearchSimilar(ctx, query[0], ChunkSearchOptions{Limit: 1})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunks[0].ID() {
+		t.Fatal("Expected the invoice chunk to be found with the current embeddings")
+	}
+
+	if results[0].Chunk.EmbeddingModel() != "small" || results[0].Chunk.EmbeddingDim() != 8 {
+		t.Fatal("Expected the current embedding model small, got", results[0].Chunk.EmbeddingModel())
+	}
+
+	if err := storeReembed.ReembedCutover(ctx, "large"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	found, err := storeReembed.ChunkFindByID(ctx, chunks[0].ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found.EmbeddingModel() != "large" || found.EmbeddingDim() != 4 || !found.IsEmbedded() {
+		t.Fatal("Expected the chunk to be embedded with model large, got", found.EmbeddingModel(), found.EmbeddingDim())
+	}
+
+	query, _ = NewHashEmbedder(4).Embed(ctx, []string{"invoice"})
+
+	results, err = storeReembed.ChunkSearchSimilar(ctx, query[0], ChunkSearchOptions{
+		Limit:          1,
+		EmbeddingModel: "large",
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunks[0].ID() {
+		t.Fatal("Expected the invoice chunk to be found with the new embeddings")
+	}
+
+	// the store is still configured with the previous model
+	if _, err := storeReembed.ChunkSearchSimilar(ctx, query[0], ChunkSearchOptions{Limit: 1}); err == nil {
+		t.Fatal("Expected an error searching by the previous model")
+	}
+}
+
+func TestStore_ReembedAllResume(t *testing.T) {
+	storeReembed := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+		opts.EmbeddingModel = "small"
+		opts.TableLeaseName = "lease_table"
+	})
+	initReembedChunks(t, storeReembed)
+
+	ctx := context.Background()
+
+	progress, err := storeReembed.ReembedAll(ctx, &failingEmbedder{Embedder: NewHashEmbedder(4), batches: 1}, ReembedOptions{
+		Model:     "large",
+		BatchSize: 1,
+		Cutover:   true,
+	})
+
+	if err == nil {
+		t.Fatal("Expected the embedder error")
+	}
+
+	if progress.Reembedded != 1 {
+		t.Fatal("Expected 1 chunk re-embedded before the error, got", progress.Reembedded)
+	}
+
+	if err := storeReembed.ReembedCutover(ctx, "large"); err == nil {
+		t.Fatal("Expected an error cutting over an incomplete re-embedding")
+	}
+
+	embedder := &countingEmbedder{Embedder: NewHashEmbedder(4)}
+
+	progress, err = storeReembed.ReembedAll(ctx, embedder, ReembedOptions{
+		Model:   "large",
+		Cutover: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(embedder.batches) != 1 || embedder.batches[0] != 2 {
+		t.Fatal("Expected only the 2 remaining chunks to be embedded, got", embedder.batches)
+	}
+
+	if progress.Reembedded != 3 {
+		t.Fatal("Expected 3 chunks re-embedded, got", progress.Reembedded)
+	}
+
+	count, err := storeReembed.ChunkCount(ctx, ChunkQuery().SetEmbeddingModel("large"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 3 {
+		t.Fatal("Expected 3 chunks of model large after the cutover, got", count)
+	}
+}
+
+func TestStore_ReembedAllContentChanged(t *testing.T) {
+	storeReembed := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+		opts.EmbeddingModel = "small"
+		opts.TableLeaseName = "lease_table"
+	})
+	chunks := initReembedChunks(t, storeReembed)
+
+	ctx := context.Background()
+
+	if _, err := storeReembed.ReembedAll(ctx, NewHashEmbedder(4), ReembedOptions{Model: "large"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the staged embedding no longer matches the content
+	chunks[1].SetContent("changed")
+
+	if err := storeReembed.ChunkUpdate(ctx, chunks[1]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeReembed.ReembedCutover(ctx, "large"); err == nil {
+		t.Fatal("Expected an error cutting over with a changed chunk")
+	}
+
+	embedder := &countingEmbedder{Embedder: NewHashEmbedder(4)}
+
+	if _, err := storeReembed.ReembedAll(ctx, embedder, ReembedOptions{Model: "large", Cutover: true}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(embedder.batches) != 1 || embedder.batches[0] != 1 {
+		t.Fatal("Expected only the changed chunk to be embedded, got", embedder.batches)
+	}
+}
+
+func TestStore_ReembedCutoverRollback(t *testing.T) {
+	storeReembed := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+		opts.EmbeddingModel = "small"
+		opts.TableLeaseName = "lease_table"
+	})
+	chunks := initReembedChunks(t, storeReembed)
+
+	ctx := context.Background()
+
+	// the runs stage embeddings of different dimensions
+	if _, err := storeReembed.ReembedAll(ctx, &failingEmbedder{Embedder: NewHashEmbedder(4), batches: 1}, ReembedOptions{
+		Model:     "large",
+		BatchSize: 1,
+	}); err == nil {
+		t.Fatal("Expected the embedder error")
+	}
+
+	if _, err := storeReembed.ReembedAll(ctx, NewHashEmbedder(3), ReembedOptions{Model: "large"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeReembed.ReembedCutover(ctx, "large"); err == nil {
+		t.Fatal("Expected an error cutting over embeddings of different dimensions")
+	}
+
+	count, err := storeReembed.ChunkCount(ctx, ChunkQuery().SetEmbeddingModel("small"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 3 {
+		t.Fatal("Expected all 3 chunks to keep model small, got", count)
+	}
+
+	// searches keep using the current embeddings
+	query, _ := NewHashEmbedder(8).Embed(ctx, []string{"receipt"})
+
+	results, err := storeReembed.ChunkSearchSimilar(ctx, query[0], ChunkSearchOptions{Limit: 3})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 3 || results[0].Chunk.ID() != chunks[1].ID() {
+		t.Fatal("Expected the receipt chunk to be found with the current embeddings")
+	}
+}
+
+func TestStore_ReembedAllConcurrent(t *testing.T) {
+	storeReembed := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+		opts.EmbeddingModel = "small"
+		opts.TableLeaseName = "lease_table"
+	})
+	initReembedChunks(t, storeReembed)
+
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		_, err := storeReembed.ReembedAll(ctx, embedderFunc(func(ctx context.Context, texts []string) ([][]float32, error) {
+			close(started)
+			<-release
+			return nil, errors.New("embedder stopped")
+		}), ReembedOptions{Model: "large"})
+
+		done <- err
+	}()
+
+	<-started
+
+	if _, err := storeReembed.ReembedAll(ctx, NewHashEmbedder(4), ReembedOptions{Model: "large"}); err == nil {
+		t.Fatal("Expected an error starting a second run")
+	}
+
+	if err := storeReembed.ReembedCutover(ctx, "large"); err == nil {
+		t.Fatal("Expected an error cutting over during a run")
+	}
+
+	close(release)
+	<-done
+
+	if _, err := storeReembed.ReembedAll(ctx, NewHashEmbedder(4), ReembedOptions{Model: "large"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+// embedderFunc adapts a function to the Embedder interface
+type embedderFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+func (f embedderFunc) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return f(ctx, texts)
+}
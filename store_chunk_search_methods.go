@@ -0,0 +1,477 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dracory/base/database"
+	"github.com/samber/lo"
+)
+
+// ChunkSearchSimilar returns the chunks most similar to the query embedding,
+// ranked by the configured distance metric, highest score first.
+//
+// When the HNSW index is enabled it is used for the search, falling back
+// to an exact scan when the index cannot answer the query, i.e. for
+// another distance metric or when soft deleted chunks are requested.
+// Otherwise, when quantization is enabled, the quantized embeddings are
+// scanned first and only the best candidates are rescored. A named vector
+// space is always scanned exactly.
+func (st *store) ChunkSearchSimilar(ctx context.Context, queryEmbedding []float32, options ChunkSearchOptions) ([]ChunkSearchResult, error) {
+	if st.db == nil {
+		return nil, errors.New("database is not initialized")
+	}
+
+	if options.Limit < 0 {
+		return nil, errors.New("search limit cannot be negative")
+	}
+
+	if options.Limit == 0 {
+		options.Limit = 10
+	}
+
+	query, err := st.chunkQueryScoped(ctx, chunkSearchQuery(options.Query, options.DocumentQuery))
+
+	if err != nil {
+		return nil, err
+	}
+
+	options.Query = query
+
+	ranked, err := st.chunkSearchSimilar(ctx, queryEmbedding, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return st.chunkSearchResults(ctx, ranked)
+}
+
+// chunkSearchSimilar returns the IDs of the chunks most similar to the
+// query embedding. Only the embeddings of the model of the search are
+// compared, and when no model is known, the ranked chunks must share one.
+// Chunks of the store model are not filtered, but checked after ranking,
+// as they are of another model only after a re-embedding cutover.
+func (st *store) chunkSearchSimilar(ctx context.Context, queryEmbedding []float32, options ChunkSearchOptions) ([]scoredID, error) {
+	if len(queryEmbedding) < 1 {
+		return nil, errors.New("query embedding is required")
+	}
+
+	metric := st.distanceMetric
+
+	if options.DistanceMetric != "" {
+		metric = options.DistanceMetric
+	}
+
+	if err := validateDistanceMetric(metric); err != nil {
+		return nil, err
+	}
+
+	if options.VectorSpace != "" && options.VectorSpace != VECTOR_SPACE_DEFAULT {
+		return st.chunkSearchVectorSpace(ctx, queryEmbedding, metric, options.VectorSpace, options.Limit, options.Query)
+	}
+
+	model := options.EmbeddingModel
+
+	if model == "" {
+		model = st.embeddingModel
+	}
+
+	// the chunks written by the store are all of the configured model
+	if model != "" && model != st.embeddingModel {
+		options.Query = chunkQueryCopy(options.Query).SetEmbeddingModel(model)
+	}
+
+	ranked, err := st.chunkSearchRanked(ctx, queryEmbedding, metric, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if model == st.embeddingModel {
+		if err := st.chunkSearchModelsCheck(ctx, ranked, model); err != nil {
+			return nil, err
+		}
+	}
+
+	return ranked, nil
+}
+
+// chunkSearchRanked ranks the chunks matching the query by similarity to the
+// query embedding, using the HNSW index, the quantized embeddings or an
+// exact scan, as configured
+func (st *store) chunkSearchRanked(ctx context.Context, queryEmbedding []float32, metric string, options ChunkSearchOptions) ([]scoredID, error) {
+	if st.hnsw != nil && !options.Exact && metric == st.distanceMetric {
+		ranked, found, err := st.chunkSearchHnsw(ctx, queryEmbedding, options.Limit, options.Query)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if found {
+			return ranked, nil
+		}
+	}
+
+	if st.quantization != QUANTIZATION_NONE && !options.Exact {
+		return st.chunkSearchQuantized(ctx, queryEmbedding, metric, options.Limit, options.Query)
+	}
+
+	return st.chunkSearchExact(ctx, queryEmbedding, metric, options.Limit, options.Query)
+}
+
+// chunkSearchExact scores every chunk matching the query against the query
+// embedding, and returns the top ranked chunk IDs
+func (st *store) chunkSearchExact(ctx context.Context, queryEmbedding []float32, metric string, limit int, query ChunkQueryInterface) ([]scoredID, error) {
+	if query == nil {
+		query = ChunkQuery()
+	}
+
+	q, _, err := query.ToSelectDataset(st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sqlStr, sqlParams, err := q.
+		ClearLimit().
+		ClearOffset().
+		ClearOrder().
+		Select(COLUMN_ID, COLUMN_EMBEDDING).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.Query(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	top := newTopK(limit)
+
+	for rows.Next() {
+		var id string
+		var embeddingRaw []byte
+
+		if err := rows.Scan(&id, &embeddingRaw); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		embedding, err := embeddingFromString(string(embeddingRaw))
+
+		if err != nil {
+			rows.Close()
+			return nil, errors.New("chunk " + id + ": " + err.Error())
+		}
+
+		if len(embedding) < 1 {
+			continue // not embedded yet
+		}
+
+		if len(embedding) != len(queryEmbedding) {
+			rows.Close()
+			return nil, errors.New("chunk " + id + ": embedding dimension does not match query embedding")
+		}
+
+		top.Push(id, vectorScore(metric, queryEmbedding, embedding))
+	}
+
+	// the rows must be released before any follow up query,
+	// as it may need the same connection
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return top.Sorted(), nil
+}
+
+// chunkSearchQuantized scores the quantized embeddings of the chunks
+// matching the query, then rescores the best candidates with their full
+// precision embeddings. Chunks not quantized yet are scored exactly.
+func (st *store) chunkSearchQuantized(ctx context.Context, queryEmbedding []float32, metric string, limit int, query ChunkQueryInterface) ([]scoredID, error) {
+	if query == nil {
+		query = ChunkQuery()
+	}
+
+	q, _, err := query.ToSelectDataset(st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// the full embedding is only read for the chunks not quantized yet
+	embeddingUnquantized := goqu.Case().
+		When(goqu.C(COLUMN_EMBEDDING_QUANTIZED).IsNull(), goqu.C(COLUMN_EMBEDDING)).
+		As(COLUMN_EMBEDDING)
+
+	sqlStr, sqlParams, err := q.
+		ClearLimit().
+		ClearOffset().
+		ClearOrder().
+		Select(COLUMN_ID, COLUMN_EMBEDDING_QUANTIZED, embeddingUnquantized).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.Query(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scorer := newQuantizedScorer(metric, queryEmbedding)
+	candidates := newTopK(limit * st.quantizationRescoreFactor)
+	top := newTopK(limit)
+
+	for rows.Next() {
+		var id string
+		var quantized []byte
+		var embeddingRaw []byte
+
+		if err := rows.Scan(&id, &quantized, &embeddingRaw); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		if len(quantized) > 0 {
+			score, err := scorer.Score(quantized)
+
+			if err != nil {
+				rows.Close()
+				return nil, errors.New("chunk " + id + ": " + err.Error())
+			}
+
+			candidates.Push(id, score)
+			continue
+		}
+
+		embedding, err := embeddingFromString(string(embeddingRaw))
+
+		if err != nil {
+			rows.Close()
+			return nil, errors.New("chunk " + id + ": " + err.Error())
+		}
+
+		if len(embedding) < 1 {
+			continue // not embedded yet
+		}
+
+		if len(embedding) != len(queryEmbedding) {
+			rows.Close()
+			return nil, errors.New("chunk " + id + ": embedding dimension does not match query embedding")
+		}
+
+		top.Push(id, vectorScore(metric, queryEmbedding, embedding))
+	}
+
+	// the rows must be released before the rescoring query,
+	// as it may need the same connection
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	candidateIDs := lo.Map(candidates.Sorted(), func(item scoredID, _ int) string {
+		return item.id
+	})
+
+	if len(candidateIDs) < 1 {
+		return top.Sorted(), nil
+	}
+
+	rescored, err := st.chunkSearchExact(ctx, queryEmbedding, metric, limit, ChunkQuery().
+		SetIDIn(candidateIDs).
+		SetWithSoftDeleted(true))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range rescored {
+		top.Push(item.id, item.score)
+	}
+
+	return top.Sorted(), nil
+}
+
+// chunkSearchQuery returns the chunk query of a search, restricted to the
+// chunks of the documents matching the document query. The passed chunk
+// query is copied, not changed.
+func chunkSearchQuery(query ChunkQueryInterface, documentQuery DocumentQueryInterface) ChunkQueryInterface {
+	if documentQuery == nil {
+		return query
+	}
+
+	return chunkQueryCopy(query).SetDocumentQuery(documentQuery)
+}
+
+// chunkSearchModelsCheck fails, when the ranked chunks have embeddings of
+// another model than the search, or without a search model, of different
+// models, as their scores cannot be compared. Chunks without a recorded
+// model are not checked.
+func (st *store) chunkSearchModelsCheck(ctx context.Context, ranked []scoredID, model string) error {
+	if len(ranked) < 1 || (model == "" && len(ranked) < 2) {
+		return nil
+	}
+
+	ids := lo.Map(ranked, func(item scoredID, _ int) string {
+		return item.id
+	})
+
+	models := []string{}
+
+	for _, batch := range lo.Chunk(ids, st.chunkBulkBatchSize(1)) {
+		sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+			From(st.tableDocumentChunk).
+			Select(COLUMN_EMBEDDING_MODEL).
+			Distinct().
+			Where(
+				goqu.C(COLUMN_ID).In(batch),
+				goqu.C(COLUMN_EMBEDDING_MODEL).IsNotNull(),
+				goqu.C(COLUMN_EMBEDDING_MODEL).Neq(""),
+			).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			models = append(models, row[COLUMN_EMBEDDING_MODEL])
+		}
+	}
+
+	models = lo.Uniq(models)
+
+	for _, other := range models {
+		if model != "" && other != model {
+			return errors.New("embedding model " + other + " does not match the search model " + model)
+		}
+	}
+
+	if len(models) > 1 {
+		slices.Sort(models)
+		return errors.New("cannot compare the embeddings of different models: " + strings.Join(models, ", "))
+	}
+
+	return nil
+}
+
+// chunkSearchIDs returns the IDs of all chunks matching the query
+func (st *store) chunkSearchIDs(ctx context.Context, query ChunkQueryInterface) ([]string, error) {
+	query, err := st.chunkQueryScoped(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q, _, err := query.ToSelectDataset(st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sqlStr, sqlParams, err := q.
+		ClearLimit().
+		ClearOffset().
+		ClearOrder().
+		Select(COLUMN_ID).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(rows, func(row map[string]string, _ int) string {
+		return row[COLUMN_ID]
+	}), nil
+}
+
+// chunkSearchResults loads the chunks for the ranked IDs, keeping the order
+func (st *store) chunkSearchResults(ctx context.Context, ranked []scoredID) ([]ChunkSearchResult, error) {
+	if len(ranked) < 1 {
+		return []ChunkSearchResult{}, nil
+	}
+
+	ids := lo.Map(ranked, func(item scoredID, _ int) string {
+		return item.id
+	})
+
+	chunks, err := st.ChunkList(ctx, ChunkQuery().
+		SetIDIn(ids).
+		SetWithSoftDeleted(true))
+
+	if err != nil {
+		return nil, err
+	}
+
+	chunkMap := lo.KeyBy(chunks, func(chunk ChunkInterface) string {
+		return chunk.ID()
+	})
+
+	results := []ChunkSearchResult{}
+
+	for _, item := range ranked {
+		chunk, found := chunkMap[item.id]
+
+		if !found {
+			continue // removed since the scan
+		}
+
+		results = append(results, ChunkSearchResult{
+			Chunk: chunk,
+			Score: item.score,
+		})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,466 @@
+package ragstore
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestStore_ChunkSearchSimilar(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk1 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.0, 0.0, 0.0})
+
+	chunk2 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("chunk 2").
+		SetEmbedding([]float32{0.7, 0.7, 0.0})
+
+	chunk3 := NewChunk().
+		SetDocumentID(testDocument_O2).
+		SetChunkIndex(1).
+		SetContent("chunk 3").
+		SetEmbedding([]float32{0.0, 0.0, 1.0})
+
+	for _, chunk := range []ChunkInterface{chunk1, chunk2, chunk3} {
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	results, err := store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.1, 0.0}, ChunkSearchOptions{
+		Limit: 2,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	if results[0].Chunk.ID() != chunk1.ID() {
+		t.Fatalf("Expected first result to be chunk 1, got %s", results[0].Chunk.Content())
+	}
+
+	if results[1].Chunk.ID() != chunk2.ID() {
+		t.Fatalf("Expected second result to be chunk 2, got %s", results[1].Chunk.Content())
+	}
+
+	if results[0].Score < results[1].Score {
+		t.Fatal("Expected results to be ordered by score, highest first")
+	}
+
+	// Test filtering by document ID
+	documentResults, err := store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.1, 0.0}, ChunkSearchOptions{
+		Query: ChunkQuery().SetDocumentID(testDocument_O2),
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(documentResults) != 1 {
+		t.Fatalf("Expected 1 result for document %s, got %d", testDocument_O2, len(documentResults))
+	}
+
+	if documentResults[0].Chunk.ID() != chunk3.ID() {
+		t.Fatal("Expected only chunk 3 to be returned")
+	}
+}
+
+func TestStore_ChunkSearchSimilarExcludesSoftDeleted(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk1 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.0, 0.0, 0.0})
+
+	chunk2 := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("chunk 2").
+		SetEmbedding([]float32{0.0, 1.0, 0.0})
+
+	for _, chunk := range []ChunkInterface{chunk1, chunk2} {
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	if err := store.ChunkSoftDelete(context.Background(), chunk1); err != nil {
+		t.Fatal("unexpected error on soft delete:", err)
+	}
+
+	results, err := store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0, 0.0}, ChunkSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+
+	if results[0].Chunk.ID() != chunk2.ID() {
+		t.Fatal("Soft deleted chunk MUST NOT be returned")
+	}
+
+	withDeleted, err := store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0, 0.0}, ChunkSearchOptions{
+		Query: ChunkQuery().SetWithSoftDeleted(true),
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(withDeleted) != 2 {
+		t.Fatalf("Expected 2 results including soft deleted, got %d", len(withDeleted))
+	}
+}
+
+func TestStore_ChunkSearchSimilarDimensionMismatch(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.0, 0.0, 0.0})
+
+	if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+		t.Fatal("unexpected error creating chunk:", err)
+	}
+
+	_, err = store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{})
+
+	if err == nil {
+		t.Fatal("expected error for dimension mismatch, but got nil")
+	}
+}
+
+func TestStore_ChunkSearchSimilarDistanceMetrics(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// a long vector pointing the same way as the query, and a short vector
+	// close to the query point but pointing elsewhere
+	chunkLong := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("long").
+		SetEmbedding([]float32{10.0, 0.0})
+
+	chunkNear := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(2).
+		SetContent("near").
+		SetEmbedding([]float32{0.8, 0.5})
+
+	for _, chunk := range []ChunkInterface{chunkLong, chunkNear} {
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error creating chunk:", err)
+		}
+	}
+
+	expectedFirst := map[string]string{
+		DISTANCE_METRIC_COSINE:      chunkLong.ID(),
+		DISTANCE_METRIC_DOT_PRODUCT: chunkLong.ID(),
+		DISTANCE_METRIC_EUCLIDEAN:   chunkNear.ID(),
+		DISTANCE_METRIC_MANHATTAN:   chunkNear.ID(),
+	}
+
+	for metric, expectedID := range expectedFirst {
+		results, err := store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{
+			DistanceMetric: metric,
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if len(results) != 2 {
+			t.Fatalf("Expected 2 results for %s, got %d", metric, len(results))
+		}
+
+		if results[0].Chunk.ID() != expectedID {
+			t.Fatalf("Unexpected first result for %s: %s", metric, results[0].Chunk.Content())
+		}
+	}
+
+	_, err = store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{
+		DistanceMetric: "unknown",
+	})
+
+	if err == nil {
+		t.Fatal("expected error for unsupported distance metric, but got nil")
+	}
+}
+
+func TestStore_ChunkCreateNormalizesEmbeddings(t *testing.T) {
+	store, err := NewStore(NewStoreOptions{
+		DB:                     initDB(":memory:"),
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+		DistanceMetric:         DISTANCE_METRIC_DOT_PRODUCT,
+		NormalizeEmbeddings:    true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{3.0, 4.0})
+
+	if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+		t.Fatal("unexpected error creating chunk:", err)
+	}
+
+	chunkFound, err := store.ChunkFindByID(context.Background(), chunk.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	embedding, err := chunkFound.EmbeddingE()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(embedding) != 2 || embedding[0] != 0.6 || embedding[1] != 0.8 {
+		t.Fatalf("Expected normalized embedding [0.6 0.8], got %v", embedding)
+	}
+}
+
+func TestNewStoreUnsupportedDistanceMetric(t *testing.T) {
+	_, err := NewStore(NewStoreOptions{
+		DB:                     initDB(":memory:"),
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		DistanceMetric:         "unknown",
+	})
+
+	if err == nil {
+		t.Fatal("expected error for unsupported distance metric, but got nil")
+	}
+}
+
+func TestStore_ChunkSearchDocumentQuery(t *testing.T) {
+	storeHnsw := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+	})
+
+	documentActive := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("active.txt").
+		SetText("active")
+
+	documentInactive := NewDocument().
+		SetStatus(DOCUMENT_STATUS_INACTIVE).
+		SetFileName("inactive.txt").
+		SetText("inactive")
+
+	if err := documentInactive.SetMeta("source", "confluence"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunksInactive := []ChunkInterface{}
+
+	// the inactive document holds all the nearest chunks
+	for i := 0; i < 100; i++ {
+		chunksInactive = append(chunksInactive, NewChunk().
+			SetChunkIndex(i).
+			SetContent("chunk").
+			SetEmbedding([]float32{1.0, float32(i) / 1000}))
+	}
+
+	chunkActive := NewChunk().
+		SetChunkIndex(0).
+		SetContent("chunk").
+		SetEmbedding([]float32{0.0, 1.0})
+
+	if err := storeHnsw.DocumentCreateWithChunks(context.Background(), documentInactive, chunksInactive); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeHnsw.DocumentCreateWithChunks(context.Background(), documentActive, []ChunkInterface{chunkActive}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, exact := range []bool{false, true} {
+		results, err := storeHnsw.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{
+			Limit:         1,
+			DocumentQuery: DocumentQuery().SetStatus(DOCUMENT_STATUS_ACTIVE),
+			Exact:         exact,
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if len(results) != 1 || results[0].Chunk.ID() != chunkActive.ID() {
+			t.Fatal("Expected only the chunk of the active document to be found")
+		}
+	}
+
+	query := ChunkQuery().SetCreatedAtGte("2000-01-01 00:00:00")
+
+	results, err := storeHnsw.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{
+		Limit:         5,
+		Query:         query,
+		DocumentQuery: DocumentQuery().SetMetaEquals("source", "confluence"),
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 5 || results[0].Chunk.ID() != chunksInactive[0].ID() {
+		t.Fatal("Expected the nearest chunks of the confluence document")
+	}
+
+	if query.IsDocumentQuerySet() {
+		t.Fatal("Expected the passed chunk query not to be changed")
+	}
+
+	count, err := storeHnsw.ChunkCount(context.Background(), ChunkQuery().
+		SetDocumentQuery(DocumentQuery().SetStatus(DOCUMENT_STATUS_ACTIVE)))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 1 {
+		t.Fatalf("Expected 1 chunk of active documents, got %d", count)
+	}
+}
+
+func TestStore_ChunkCreateValidatesEmbeddings(t *testing.T) {
+	store, err := NewStore(NewStoreOptions{
+		DB:                       initDB(":memory:"),
+		TableDocumentName:        "document_table",
+		TableDocumentChunkName:   "document_chunk_table",
+		AutomigrateEnabled:       true,
+		EmbeddingModel:           "model-a",
+		EmbeddingDimension:       2,
+		EmbeddingRejectNonFinite: true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	invalid := map[string]ChunkInterface{
+		"dimension": NewChunk().SetEmbedding([]float32{1.0, 0.0, 0.0}),
+		"nan":       NewChunk().SetEmbedding([]float32{float32(math.NaN()), 0.0}),
+		"inf":       NewChunk().SetEmbedding([]float32{float32(math.Inf(1)), 0.0}),
+		"model":     NewChunk().SetEmbedding([]float32{1.0, 0.0}).SetEmbeddingModel("model-b"),
+	}
+
+	for name, chunk := range invalid {
+		chunk.SetDocumentID(testDocument_O1).SetChunkIndex(0).SetContent(name)
+
+		if err := store.ChunkCreate(context.Background(), chunk); err == nil {
+			t.Fatal("Expected an error for the invalid embedding:", name)
+		}
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(0).
+		SetContent("valid").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunkFound, err := store.ChunkFindByID(context.Background(), chunk.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if chunkFound.EmbeddingModel() != "model-a" {
+		t.Fatal("Expected embedding model model-a, got", chunkFound.EmbeddingModel())
+	}
+
+	if chunkFound.EmbeddingDim() != 2 {
+		t.Fatal("Expected embedding dimension 2, got", chunkFound.EmbeddingDim())
+	}
+}
+
+func TestStore_ChunkSearchSimilarEmbeddingModels(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunkA := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(0).
+		SetContent("model a").
+		SetEmbedding([]float32{1.0, 0.0}).
+		SetEmbeddingModel("model-a")
+
+	chunkB := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(1).
+		SetContent("model b").
+		SetEmbedding([]float32{0.9, 0.1}).
+		SetEmbeddingModel("model-b")
+
+	for _, chunk := range []ChunkInterface{chunkA, chunkB} {
+		if err := store.ChunkCreate(context.Background(), chunk); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+	}
+
+	if _, err := store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{}); err == nil {
+		t.Fatal("Expected an error comparing embeddings of different models")
+	}
+
+	results, err := store.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{
+		EmbeddingModel: "model-b",
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunkB.ID() {
+		t.Fatal("Expected only the chunk of model-b, got", len(results))
+	}
+}
@@ -0,0 +1,566 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dracory/base/database"
+	"github.com/dromara/carbon/v2"
+	"github.com/samber/lo"
+	"github.com/spf13/cast"
+)
+
+// vectorSpaceMaxLength is the maximum length of a vector space name
+const vectorSpaceMaxLength = 100
+
+// ChunkVectorSet sets the embedding of the chunk in the vector space,
+// replacing any previous one. The default vector space is the chunk
+// embedding, which is updated as by ChunkUpdate.
+//
+// The embeddings of a named vector space must share one dimension. They
+// are removed, when the chunk content changes or the chunk is deleted.
+func (st *store) ChunkVectorSet(ctx context.Context, chunkID string, space string, embedding []float32) error {
+	if st.db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	if chunkID == "" {
+		return errors.New("chunk ID is required")
+	}
+
+	if err := validateVectorSpace(space); err != nil {
+		return err
+	}
+
+	if space == VECTOR_SPACE_DEFAULT {
+		chunk, err := st.ChunkFindByID(ctx, chunkID)
+
+		if err != nil {
+			return err
+		}
+
+		if chunk == nil {
+			return errors.New("chunk not found")
+		}
+
+		return st.ChunkUpdate(ctx, chunk.SetEmbedding(embedding))
+	}
+
+	if st.tableDocumentChunkVector == "" {
+		return errors.New("vector spaces are not enabled")
+	}
+
+	ids, err := st.chunkSearchIDs(ctx, ChunkQuery().
+		SetID(chunkID).
+		SetWithSoftDeleted(true))
+
+	if err != nil {
+		return err
+	}
+
+	if len(ids) < 1 {
+		return errors.New("chunk not found")
+	}
+
+	dimension, err := st.chunkVectorSpaceDimension(ctx, space, chunkID)
+
+	if err != nil {
+		return err
+	}
+
+	embedding, err = st.embeddingPrepare(embedding, &dimension)
+
+	if err != nil {
+		return err
+	}
+
+	return st.withTx(ctx, func(txStore *store) error {
+		return txStore.chunkVectorWrite(ctx, chunkID, space, embedding)
+	})
+}
+
+// ChunkVectorFind returns the embedding of the chunk in the vector space,
+// or nil when the chunk has none
+func (st *store) ChunkVectorFind(ctx context.Context, chunkID string, space string) ([]float32, error) {
+	if st.db == nil {
+		return nil, errors.New("database is not initialized")
+	}
+
+	if chunkID == "" {
+		return nil, errors.New("chunk ID is required")
+	}
+
+	if err := validateVectorSpace(space); err != nil {
+		return nil, err
+	}
+
+	if space == VECTOR_SPACE_DEFAULT {
+		chunk, err := st.ChunkFindByID(ctx, chunkID)
+
+		if err != nil || chunk == nil {
+			return nil, err
+		}
+
+		embedding, err := chunk.EmbeddingE()
+
+		if err != nil || len(embedding) < 1 {
+			return nil, err
+		}
+
+		return embedding, nil
+	}
+
+	if st.tableDocumentChunkVector == "" {
+		return nil, errors.New("vector spaces are not enabled")
+	}
+
+	ids, err := st.chunkSearchIDs(ctx, ChunkQuery().
+		SetID(chunkID).
+		SetWithSoftDeleted(true))
+
+	if err != nil || len(ids) < 1 {
+		return nil, err
+	}
+
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		From(st.tableDocumentChunkVector).
+		Select(COLUMN_EMBEDDING).
+		Where(
+			goqu.C(COLUMN_CHUNK_ID).Eq(chunkID),
+			goqu.C(COLUMN_VECTOR_SPACE).Eq(space),
+		).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil || len(rows) < 1 {
+		return nil, err
+	}
+
+	return embeddingFromString(rows[0][COLUMN_EMBEDDING])
+}
+
+// ChunkVectorDelete removes the embedding of the chunk in the vector space.
+// For the default vector space the chunk embedding is cleared.
+func (st *store) ChunkVectorDelete(ctx context.Context, chunkID string, space string) error {
+	if st.db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	if chunkID == "" {
+		return errors.New("chunk ID is required")
+	}
+
+	if err := validateVectorSpace(space); err != nil {
+		return err
+	}
+
+	if space == VECTOR_SPACE_DEFAULT {
+		chunk, err := st.ChunkFindByID(ctx, chunkID)
+
+		if err != nil || chunk == nil {
+			return err
+		}
+
+		return st.ChunkUpdate(ctx, chunk.SetEmbedding([]float32{}))
+	}
+
+	if st.tableDocumentChunkVector == "" {
+		return errors.New("vector spaces are not enabled")
+	}
+
+	ids, err := st.chunkIDsInScope(ctx, []string{chunkID})
+
+	if err != nil || len(ids) < 1 {
+		return err // chunks of other owners are left untouched
+	}
+
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Delete(st.tableDocumentChunkVector).
+		Prepared(true).
+		Where(
+			goqu.C(COLUMN_CHUNK_ID).Eq(chunkID),
+			goqu.C(COLUMN_VECTOR_SPACE).Eq(space),
+		).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	return err
+}
+
+// ChunkVectorsEmbed embeds the content of the chunks matching the query,
+// which have no embedding in the named vector space yet, i.e. to fill a
+// new vector space or the chunks changed since. The chunks are embedded
+// in batches of the store embedding batch size, written each in its own
+// transaction, so an interrupted run continues where it stopped.
+//
+// Returns the number of embedded chunks, also when an error stopped the run.
+func (st *store) ChunkVectorsEmbed(ctx context.Context, space string, embedder Embedder, query ChunkQueryInterface) (int64, error) {
+	if st.db == nil {
+		return 0, errors.New("database is not initialized")
+	}
+
+	if err := validateVectorSpace(space); err != nil {
+		return 0, err
+	}
+
+	if space == VECTOR_SPACE_DEFAULT {
+		return 0, errors.New("the default vector space is embedded with the chunks")
+	}
+
+	if st.tableDocumentChunkVector == "" {
+		return 0, errors.New("vector spaces are not enabled")
+	}
+
+	if embedder == nil {
+		return 0, errors.New("embedder is nil")
+	}
+
+	if query == nil {
+		query = ChunkQuery()
+	}
+
+	query, err := st.chunkQueryScoped(ctx, query)
+
+	if err != nil {
+		return 0, err
+	}
+
+	q, _, err := query.ToSelectDataset(st)
+
+	if err != nil {
+		return 0, err
+	}
+
+	embedded := goqu.Dialect(st.dbDriverName).
+		From(st.tableDocumentChunkVector).
+		Select(COLUMN_CHUNK_ID).
+		Where(goqu.C(COLUMN_VECTOR_SPACE).Eq(space))
+
+	dimension, err := st.chunkVectorSpaceDimension(ctx, space, "")
+
+	if err != nil {
+		return 0, err
+	}
+
+	written := int64(0)
+	cursor := ""
+
+	for {
+		sqlStr, sqlParams, err := q.
+			ClearLimit().
+			ClearOffset().
+			ClearOrder().
+			Select(COLUMN_ID, COLUMN_CONTENT).
+			Where(
+				goqu.C(COLUMN_ID).Gt(cursor),
+				goqu.C(COLUMN_ID).NotIn(embedded),
+			).
+			Order(goqu.C(COLUMN_ID).Asc()).
+			Limit(uint(st.embeddingBatchSize)).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return written, err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+		if err != nil {
+			return written, err
+		}
+
+		if len(rows) < 1 {
+			return written, nil
+		}
+
+		texts := lo.Map(rows, func(row map[string]string, _ int) string {
+			return row[COLUMN_CONTENT]
+		})
+
+		embeddings, err := embedder.Embed(ctx, texts)
+
+		if err != nil {
+			return written, err
+		}
+
+		if len(embeddings) != len(rows) {
+			return written, errors.New("embedder returned " + strconv.Itoa(len(embeddings)) + " embeddings for " + strconv.Itoa(len(rows)) + " texts")
+		}
+
+		for i, row := range rows {
+			embedding, err := st.embeddingPrepare(embeddings[i], &dimension)
+
+			if err != nil {
+				return written, errors.New("chunk " + row[COLUMN_ID] + ": " + err.Error())
+			}
+
+			embeddings[i] = embedding
+		}
+
+		err = st.withTx(ctx, func(txStore *store) error {
+			for i, row := range rows {
+				if err := txStore.chunkVectorWrite(ctx, row[COLUMN_ID], space, embeddings[i]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return written, err
+		}
+
+		written += int64(len(rows))
+
+		if len(rows) < st.embeddingBatchSize {
+			return written, nil
+		}
+
+		cursor = rows[len(rows)-1][COLUMN_ID]
+	}
+}
+
+// chunkSearchVectorSpace scores the embeddings of the named vector space
+// of the chunks matching the query against the query embedding, and
+// returns the top ranked chunk IDs
+func (st *store) chunkSearchVectorSpace(ctx context.Context, queryEmbedding []float32, metric string, space string, limit int, query ChunkQueryInterface) ([]scoredID, error) {
+	if err := validateVectorSpace(space); err != nil {
+		return nil, err
+	}
+
+	if st.tableDocumentChunkVector == "" {
+		return nil, errors.New("vector spaces are not enabled")
+	}
+
+	if query == nil {
+		query = ChunkQuery()
+	}
+
+	q, _, err := query.ToSelectDataset(st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	chunkIDs := q.
+		ClearLimit().
+		ClearOffset().
+		ClearOrder().
+		Select(COLUMN_ID)
+
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		From(st.tableDocumentChunkVector).
+		Select(COLUMN_CHUNK_ID, COLUMN_EMBEDDING).
+		Where(
+			goqu.C(COLUMN_VECTOR_SPACE).Eq(space),
+			goqu.C(COLUMN_CHUNK_ID).In(chunkIDs),
+		).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.Query(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	top := newTopK(limit)
+
+	for rows.Next() {
+		var id string
+		var embeddingRaw []byte
+
+		if err := rows.Scan(&id, &embeddingRaw); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		embedding, err := embeddingFromString(string(embeddingRaw))
+
+		if err != nil {
+			rows.Close()
+			return nil, errors.New("chunk " + id + ": " + err.Error())
+		}
+
+		if len(embedding) != len(queryEmbedding) {
+			rows.Close()
+			return nil, errors.New("chunk " + id + ": embedding dimension does not match query embedding")
+		}
+
+		top.Push(id, vectorScore(metric, queryEmbedding, embedding))
+	}
+
+	// the rows must be released before any follow up query,
+	// as it may need the same connection
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return top.Sorted(), nil
+}
+
+// chunkVectorSpaceDimension returns the dimension of the embeddings of the
+// vector space, other than the one of the excluded chunk, or 0 when there
+// are none
+func (st *store) chunkVectorSpaceDimension(ctx context.Context, space string, excludedChunkID string) (int, error) {
+	q := goqu.Dialect(st.dbDriverName).
+		From(st.tableDocumentChunkVector).
+		Select(COLUMN_EMBEDDING_DIM).
+		Where(goqu.C(COLUMN_VECTOR_SPACE).Eq(space))
+
+	if excludedChunkID != "" {
+		q = q.Where(goqu.C(COLUMN_CHUNK_ID).Neq(excludedChunkID))
+	}
+
+	sqlStr, sqlParams, err := q.Limit(1).Prepared(true).ToSQL()
+
+	if err != nil {
+		return 0, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil || len(rows) < 1 {
+		return 0, err
+	}
+
+	return cast.ToInt(rows[0][COLUMN_EMBEDDING_DIM]), nil
+}
+
+// chunkVectorWrite replaces the embedding of the chunk in the vector space
+func (st *store) chunkVectorWrite(ctx context.Context, chunkID string, space string, embedding []float32) error {
+	value, err := embeddingStoreValue(st.embeddingFormat, embedding)
+
+	if err != nil {
+		return err
+	}
+
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Delete(st.tableDocumentChunkVector).
+		Prepared(true).
+		Where(
+			goqu.C(COLUMN_CHUNK_ID).Eq(chunkID),
+			goqu.C(COLUMN_VECTOR_SPACE).Eq(space),
+		).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	if _, err := database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+		return err
+	}
+
+	sqlStr, sqlParams, err = goqu.Dialect(st.dbDriverName).
+		Insert(st.tableDocumentChunkVector).
+		Prepared(true).
+		Rows(goqu.Record{
+			COLUMN_CHUNK_ID:      chunkID,
+			COLUMN_VECTOR_SPACE:  space,
+			COLUMN_EMBEDDING:     value,
+			COLUMN_EMBEDDING_DIM: len(embedding),
+			COLUMN_UPDATED_AT:    carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC),
+		}).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	return err
+}
+
+// chunkVectorsRemove removes the embeddings of the chunks in all named
+// vector spaces
+func (st *store) chunkVectorsRemove(ctx context.Context, chunkIDs ...string) error {
+	if st.tableDocumentChunkVector == "" || len(chunkIDs) < 1 {
+		return nil
+	}
+
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Delete(st.tableDocumentChunkVector).
+		Prepared(true).
+		Where(goqu.C(COLUMN_CHUNK_ID).In(chunkIDs)).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	return err
+}
+
+// validateVectorSpace checks the vector space name is not empty, and fits
+// the vector space column
+func validateVectorSpace(space string) error {
+	if space == "" {
+		return errors.New("vector space is required")
+	}
+
+	if len(space) > vectorSpaceMaxLength {
+		return errors.New("vector space name is longer than " + strconv.Itoa(vectorSpaceMaxLength) + " characters")
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package ragstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dracory/base/database"
+)
+
+func initVectorChunks(t *testing.T, store StoreInterface) []ChunkInterface {
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("invoice receipt contract")
+
+	chunks := []ChunkInterface{
+		NewChunk().SetChunkIndex(0).SetContent("invoice").SetEmbedding([]float32{1.0, 0.0}),
+		NewChunk().SetChunkIndex(1).SetContent("receipt").SetEmbedding([]float32{0.0, 1.0}),
+		NewChunk().SetChunkIndex(2).SetContent("contract").SetEmbedding([]float32{0.7, 0.7}),
+	}
+
+	if err := store.DocumentCreateWithChunks(context.Background(), document, chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return chunks
+}
+
+func TestStore_ChunkVectorSet(t *testing.T) {
+	storeVector := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkVectorName = "document_chunk_vector_table"
+		opts.HNSWEnabled = true
+	})
+	chunks := initVectorChunks(t, storeVector)
+
+	ctx := context.Background()
+
+	if err := storeVector.ChunkVectorSet(ctx, chunks[0].ID(), "large", []float32{0.0, 0.0, 1.0}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	embedding, err := storeVector.ChunkVectorFind(ctx, chunks[0].ID(), "large")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(embedding) != 3 || embedding[2] != 1.0 {
+		t.Fatal("Expected the embedding of the large vector space, got", embedding)
+	}
+
+	// the default vector space is the chunk embedding
+	embedding, err = storeVector.ChunkVectorFind(ctx, chunks[0].ID(), VECTOR_SPACE_DEFAULT)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(embedding) != 2 || embedding[0] != 1.0 {
+		t.Fatal("Expected the chunk embedding, got", embedding)
+	}
+
+	if err := storeVector.ChunkVectorSet(ctx, chunks[0].ID(), VECTOR_SPACE_DEFAULT, []float32{0.0, 1.0}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	found, err := storeVector.ChunkFindByID(ctx, chunks[0].ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if embedding := found.Embedding(); len(embedding) != 2 || embedding[1] != 1.0 {
+		t.Fatal("Expected the chunk embedding to be updated, got", embedding)
+	}
+
+	// the embeddings of a vector space share one dimension
+	if err := storeVector.ChunkVectorSet(ctx, chunks[1].ID(), "large", []float32{1.0, 0.0}); err == nil {
+		t.Fatal("Expected an error for an embedding of another dimension")
+	}
+
+	if err := storeVector.ChunkVectorSet(ctx, "missing", "large", []float32{0.0, 0.0, 1.0}); err == nil {
+		t.Fatal("Expected an error for a missing chunk")
+	}
+
+	if err := storeVector.ChunkVectorSet(ctx, chunks[0].ID(), "", []float32{0.0, 0.0, 1.0}); err == nil {
+		t.Fatal("Expected an error for an empty vector space")
+	}
+
+	if err := storeVector.ChunkVectorDelete(ctx, chunks[0].ID(), "large"); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	embedding, err = storeVector.ChunkVectorFind(ctx, chunks[0].ID(), "large")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if embedding != nil {
+		t.Fatal("Expected the embedding to be deleted, got", embedding)
+	}
+}
+
+func TestStore_ChunkVectorsEmbed(t *testing.T) {
+	storeVector := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkVectorName = "document_chunk_vector_table"
+		opts.HNSWEnabled = true
+	})
+	chunks := initVectorChunks(t, storeVector)
+
+	ctx := context.Background()
+
+	embedder := &countingEmbedder{Embedder: NewHashEmbedder(16)}
+
+	embedded, err := storeVector.ChunkVectorsEmbed(ctx, "hash", embedder, nil)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if embedded != 3 {
+		t.Fatal("Expected 3 embedded chunks, got", embedded)
+	}
+
+	// the embeddings of a changed chunk are removed
+	chunks[1].SetContent("changed receipt")
+
+	if err := storeVector.ChunkUpdate(ctx, chunks[1]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	embedding, err := storeVector.ChunkVectorFind(ctx, chunks[1].ID(), "hash")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if embedding != nil {
+		t.Fatal("Expected the embedding of the changed chunk to be removed")
+	}
+
+	embedder.batches = nil
+
+	embedded, err = storeVector.ChunkVectorsEmbed(ctx, "hash", embedder, nil)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if embedded != 1 || len(embedder.batches) != 1 {
+		t.Fatal("Expected only the changed chunk to be embedded, got", embedded)
+	}
+
+	if _, err := storeVector.ChunkVectorsEmbed(ctx, VECTOR_SPACE_DEFAULT, embedder, nil); err == nil {
+		t.Fatal("Expected an error embedding the default vector space")
+	}
+
+	// the embeddings of a deleted chunk are removed
+	if err := storeVector.ChunkDeleteByID(ctx, chunks[2].ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	rows, err := database.SelectToMapString(database.Context(ctx, storeVector.(*store).db),
+		"SELECT chunk_id FROM document_chunk_vector_table WHERE chunk_id = ?", chunks[2].ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(rows) != 0 {
+		t.Fatal("Expected the embedding of the deleted chunk to be removed")
+	}
+}
+
+func TestStore_ChunkSearchSimilarVectorSpace(t *testing.T) {
+	storeVector := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkVectorName = "document_chunk_vector_table"
+		opts.HNSWEnabled = true
+	})
+	chunks := initVectorChunks(t, storeVector)
+
+	ctx := context.Background()
+
+	if _, err := storeVector.ChunkVectorsEmbed(ctx, "hash", NewHashEmbedder(16), nil); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	query, err := NewHashEmbedder(16).Embed(ctx, []string{"contract"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	results, err := storeVector.ChunkSearchSimilar(ctx, query[0], ChunkSearchOptions{
+		Limit:       1,
+		VectorSpace: "hash",
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunks[2].ID() {
+		t.Fatal("Expected the contract chunk in the hash vector space")
+	}
+
+	// the default vector space keeps searching the chunk embeddings
+	results, err = storeVector.ChunkSearchSimilar(ctx, []float32{1.0, 0.0}, ChunkSearchOptions{Limit: 1})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.ID() != chunks[0].ID() {
+		t.Fatal("Expected the invoice chunk in the default vector space")
+	}
+
+	// soft deleted chunks are not searched
+	if err := storeVector.ChunkSoftDeleteByID(ctx, chunks[2].ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	results, err = storeVector.ChunkSearchSimilar(ctx, query[0], ChunkSearchOptions{
+		Limit:       3,
+		VectorSpace: "hash",
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatal("Expected 2 chunks in the hash vector space, got", len(results))
+	}
+
+	hybridResults, err := storeVector.ChunkSearchHybrid(ctx, "", query[0], ChunkHybridSearchOptions{
+		Limit:       3,
+		VectorSpace: "hash",
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(hybridResults) != 2 {
+		t.Fatal("Expected 2 hybrid results in the hash vector space, got", len(hybridResults))
+	}
+
+	if _, err := storeVector.ChunkSearchSimilar(ctx, query[0], ChunkSearchOptions{VectorSpace: "missing"}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
@@ -0,0 +1,444 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dracory/base/database"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
+	"github.com/samber/lo"
+)
+
+// DocumentChunksCreate splits the text of the document with the chunker,
+// and creates a chunk for each piece, in order, with 0-based chunk indexes,
+// in one transaction. The document must have been created. Existing chunks
+// are kept.
+//
+// Returns the created chunks.
+func (st *store) DocumentChunksCreate(ctx context.Context, document DocumentInterface, chunker Chunker) ([]ChunkInterface, error) {
+	if st.db == nil {
+		return nil, errors.New("database is not initialized")
+	}
+
+	chunks, err := documentChunksSplit(document, chunker)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = st.WithTx(ctx, func(txStore StoreInterface) error {
+		return txStore.ChunkCreateMany(ctx, chunks)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return chunks, nil
+}
+
+// DocumentCreateWithChunks creates the document and its chunks in one
+// transaction, so either all or none are created. The document ID and
+// owner are set on the chunks.
+func (st *store) DocumentCreateWithChunks(ctx context.Context, document DocumentInterface, chunks []ChunkInterface) error {
+	if document == nil {
+		return errors.New("document is nil")
+	}
+
+	return st.WithTx(ctx, func(txStore StoreInterface) error {
+		if err := txStore.DocumentCreate(ctx, document); err != nil {
+			return err
+		}
+
+		for _, chunk := range chunks {
+			if chunk == nil {
+				return errors.New("chunk is nil")
+			}
+
+			chunk.SetDocumentID(document.ID())
+			chunk.SetOwnerID(document.OwnerID())
+		}
+
+		return txStore.ChunkCreateMany(ctx, chunks)
+	})
+}
+
+// DocumentReplaceChunks permanently deletes all chunks of the document
+// (including soft deleted ones), and creates the new chunks in their
+// place, in one transaction. The document ID and owner are set on the
+// chunks.
+func (st *store) DocumentReplaceChunks(ctx context.Context, documentID string, chunks []ChunkInterface) error {
+	if documentID == "" {
+		return errors.New("document ID is required")
+	}
+
+	return st.withTx(ctx, func(txStore *store) error {
+		document, err := txStore.DocumentFindByID(ctx, documentID)
+
+		if err != nil {
+			return err
+		}
+
+		if document == nil {
+			return errors.New("document not found")
+		}
+
+		if err := txStore.documentChunksDelete(ctx, documentID); err != nil {
+			return err
+		}
+
+		for _, chunk := range chunks {
+			if chunk == nil {
+				return errors.New("chunk is nil")
+			}
+
+			chunk.SetDocumentID(documentID)
+			chunk.SetOwnerID(document.OwnerID())
+		}
+
+		return txStore.ChunkCreateMany(ctx, chunks)
+	})
+}
+
+// DocumentIngest creates the document and its chunks in one call. The
+// text of the document is split with the chunker, the chunks are embedded
+// in batches of EmbeddingBatchSize, and only once all are embedded, the
+// document and chunks are created in one transaction, with 0-based chunk
+// indexes.
+//
+// Returns the created chunks.
+func (st *store) DocumentIngest(ctx context.Context, document DocumentInterface, chunker Chunker, embedder Embedder) ([]ChunkInterface, error) {
+	if st.db == nil {
+		return nil, errors.New("database is not initialized")
+	}
+
+	if embedder == nil {
+		return nil, errors.New("embedder is nil")
+	}
+
+	chunks, err := documentChunksSplit(document, chunker)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := st.chunksEmbed(ctx, chunks, embedder); err != nil {
+		return nil, err
+	}
+
+	if err := st.DocumentCreateWithChunks(ctx, document, chunks); err != nil {
+		return nil, err
+	}
+
+	return chunks, nil
+}
+
+// DocumentReindex brings the chunks of the document up to date with its
+// text, which is split with the chunker. The chunks whose content hash is
+// unchanged are kept, and only the new chunks, along with the kept chunks
+// not embedded yet, are embedded. The chunk indexes are set to the order of
+// the new text, and the other chunks of the document (including soft
+// deleted ones) are permanently deleted. The document is updated, and the
+// chunks written, in one transaction, once all are embedded. A document,
+// which is not found, or not in the owner scope, fails before anything is
+// split or embedded.
+//
+// Returns the chunks of the document, in order.
+func (st *store) DocumentReindex(ctx context.Context, document DocumentInterface, chunker Chunker, embedder Embedder) ([]ChunkInterface, error) {
+	if st.db == nil {
+		return nil, errors.New("database is not initialized")
+	}
+
+	if embedder == nil {
+		return nil, errors.New("embedder is nil")
+	}
+
+	if document == nil {
+		return nil, errors.New("document is nil")
+	}
+
+	found, err := st.DocumentFindByID(ctx, document.ID())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if found == nil {
+		return nil, errors.New("document not found")
+	}
+
+	split, err := documentChunksSplit(document, chunker)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := st.ChunkList(ctx, ChunkQuery().
+		SetDocumentID(document.ID()).
+		SetOrderBy(COLUMN_CHUNK_INDEX).
+		SetOrderDirection(sb.ASC))
+
+	if err != nil {
+		return nil, err
+	}
+
+	existingByHash := lo.GroupBy(existing, chunkContentHash)
+
+	chunks := make([]ChunkInterface, 0, len(split))
+	created := []ChunkInterface{}
+	kept := []ChunkInterface{}
+
+	for index, chunk := range split {
+		hash := chunkContentHash(chunk)
+		candidates := existingByHash[hash]
+
+		if len(candidates) < 1 {
+			chunks = append(chunks, chunk)
+			created = append(created, chunk)
+			continue
+		}
+
+		existingByHash[hash] = candidates[1:]
+
+		if candidates[0].ChunkIndex() != index {
+			candidates[0].SetChunkIndex(index)
+		}
+
+		chunks = append(chunks, candidates[0])
+		kept = append(kept, candidates[0])
+	}
+
+	unembedded := lo.Filter(kept, func(chunk ChunkInterface, _ int) bool {
+		return !chunk.IsEmbedded()
+	})
+
+	if err := st.chunksEmbed(ctx, append(created, unembedded...), embedder); err != nil {
+		return nil, err
+	}
+
+	updated := lo.Filter(kept, func(chunk ChunkInterface, _ int) bool {
+		return len(chunk.DataChanged()) > 0
+	})
+
+	keptIDs := lo.Map(chunks, func(chunk ChunkInterface, _ int) string {
+		return chunk.ID()
+	})
+
+	err = st.withTx(ctx, func(txStore *store) error {
+		found, err := txStore.DocumentFindByID(ctx, document.ID())
+
+		if err != nil {
+			return err
+		}
+
+		if found == nil {
+			return errors.New("document not found")
+		}
+
+		if err := txStore.DocumentUpdate(ctx, document); err != nil {
+			return err
+		}
+
+		ids, err := txStore.chunkSearchIDs(ctx, ChunkQuery().
+			SetDocumentID(document.ID()).
+			SetWithSoftDeleted(true))
+
+		if err != nil {
+			return err
+		}
+
+		if err := txStore.ChunkDeleteMany(ctx, lo.Without(ids, keptIDs...)); err != nil {
+			return err
+		}
+
+		if err := txStore.ChunkUpdateMany(ctx, updated); err != nil {
+			return err
+		}
+
+		return txStore.ChunkCreateMany(ctx, created)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return chunks, nil
+}
+
+// chunksEmbed sets the embeddings of the chunk contents, embedded in
+// batches of the store embedding batch size
+func (st *store) chunksEmbed(ctx context.Context, chunks []ChunkInterface, embedder Embedder) error {
+	for _, batch := range lo.Chunk(chunks, st.embeddingBatchSize) {
+		texts := lo.Map(batch, func(chunk ChunkInterface, _ int) string {
+			return chunk.Content()
+		})
+
+		embeddings, err := embedder.Embed(ctx, texts)
+
+		if err != nil {
+			return err
+		}
+
+		if len(embeddings) != len(batch) {
+			return errors.New("embedder returned " + strconv.Itoa(len(embeddings)) + " embeddings for " + strconv.Itoa(len(batch)) + " texts")
+		}
+
+		for i, chunk := range batch {
+			chunk.SetEmbedding(embeddings[i])
+		}
+	}
+
+	return nil
+}
+
+// documentChunksSplit splits the text of the document with the chunker into
+// new chunks, which are not created yet
+func documentChunksSplit(document DocumentInterface, chunker Chunker) ([]ChunkInterface, error) {
+	if document == nil {
+		return nil, errors.New("document is nil")
+	}
+
+	if document.ID() == "" {
+		return nil, errors.New("document ID is required")
+	}
+
+	if chunker == nil {
+		return nil, errors.New("chunker is nil")
+	}
+
+	contents, err := chunker.Split(document.Text())
+
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]ChunkInterface, 0, len(contents))
+
+	for index, content := range contents {
+		chunks = append(chunks, NewChunk().
+			SetDocumentID(document.ID()).
+			SetOwnerID(document.OwnerID()).
+			SetChunkIndex(index).
+			SetContent(content))
+	}
+
+	return chunks, nil
+}
+
+// documentChunksDelete permanently deletes all chunks of the document,
+// including soft deleted ones
+func (st *store) documentChunksDelete(ctx context.Context, documentID string) error {
+	chunkIDs, err := st.chunkSearchIDs(ctx, ChunkQuery().
+		SetDocumentID(documentID).
+		SetWithSoftDeleted(true))
+
+	if err != nil {
+		return err
+	}
+
+	return st.ChunkDeleteMany(ctx, chunkIDs)
+}
+
+// documentChunksSoftDelete soft deletes the chunks of the document, which
+// are not soft deleted yet, at the time the document was soft deleted, and
+// marks them as soft deleted along with the document
+func (st *store) documentChunksSoftDelete(ctx context.Context, documentID string, softDeletedAt string) error {
+	chunkIDs, err := st.chunkSearchIDs(ctx, ChunkQuery().
+		SetDocumentID(documentID))
+
+	if err != nil {
+		return err
+	}
+
+	for _, batch := range lo.Chunk(chunkIDs, st.chunkBulkBatchSize(1)) {
+		sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+			Update(st.tableDocumentChunk).
+			Prepared(true).
+			Set(goqu.Record{
+				COLUMN_SOFT_DELETED_AT:      softDeletedAt,
+				COLUMN_SOFT_DELETED_CASCADE: 1,
+				COLUMN_UPDATED_AT:           carbon.Now(carbon.UTC).ToDateTimeString(),
+			}).
+			Where(goqu.C(COLUMN_ID).In(batch)).
+			ToSQL()
+
+		if err != nil {
+			return err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		if _, err := database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...); err != nil {
+			return err
+		}
+
+		for _, chunkID := range batch {
+			st.hnswChunkRemove(chunkID)
+		}
+	}
+
+	return nil
+}
+
+// documentChunksRestore restores the chunks of the document, which were
+// soft deleted along with the document
+func (st *store) documentChunksRestore(ctx context.Context, documentID string) error {
+	q, _, err := ChunkQuery().
+		SetDocumentID(documentID).
+		SetOnlySoftDeleted(true).
+		ToSelectDataset(st)
+
+	if err != nil {
+		return err
+	}
+
+	sqlStr, sqlParams, err := q.
+		Select(COLUMN_ID).
+		Where(goqu.C(COLUMN_SOFT_DELETED_CASCADE).Eq(1)).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return err
+	}
+
+	chunkIDs := lo.Map(rows, func(row map[string]string, _ int) string {
+		return row[COLUMN_ID]
+	})
+
+	for _, batch := range lo.Chunk(chunkIDs, st.chunkBulkBatchSize(1)) {
+		chunks, err := st.ChunkList(ctx, ChunkQuery().
+			SetIDIn(batch).
+			SetOnlySoftDeleted(true))
+
+		if err != nil {
+			return err
+		}
+
+		for _, chunk := range chunks {
+			chunk.SetSoftDeletedAt(sb.MAX_DATETIME)
+		}
+
+		if err := st.ChunkUpdateMany(ctx, chunks); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package ragstore
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestStore_DocumentChunksCreate(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("First sentence. Second sentence. Third sentence.")
+
+	if err := store.DocumentCreate(context.Background(), document); err != nil {
+		t.Fatal("unexpected error creating document:", err)
+	}
+
+	chunks, err := store.DocumentChunksCreate(context.Background(), document, NewSentenceChunker(20))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+
+	list, err := store.ChunkList(context.Background(), ChunkQuery().
+		SetDocumentID(document.ID()).
+		SetOrderBy(COLUMN_CHUNK_INDEX).
+		SetOrderDirection("asc"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 3 {
+		t.Fatalf("Expected 3 stored chunks, got %d", len(list))
+	}
+
+	for index, chunk := range list {
+		if chunk.ChunkIndex() != index {
+			t.Fatalf("Expected chunk index %d, got %d", index, chunk.ChunkIndex())
+		}
+	}
+
+	if list[1].Content() != "Second sentence." {
+		t.Fatalf("Unexpected content of the second chunk: %s", list[1].Content())
+	}
+
+	if _, err := store.DocumentChunksCreate(context.Background(), document, nil); err == nil {
+		t.Fatal("expected error for nil chunker, but got nil")
+	}
+}
+
+// countingEmbedder records the batch sizes it is called with
+type countingEmbedder struct {
+	Embedder
+	batches []int
+}
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.batches = append(e.batches, len(texts))
+	return e.Embedder.Embed(ctx, texts)
+}
+
+func TestStore_DocumentIngest(t *testing.T) {
+	store, err := NewStore(NewStoreOptions{
+		DB:                     initDB(":memory:"),
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+		EmbeddingBatchSize:     2,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("router.txt").
+		SetText("Reset the router. Update the firmware. Check the cables.")
+
+	embedder := &countingEmbedder{Embedder: NewHashEmbedder(32)}
+
+	chunks, err := store.DocumentIngest(context.Background(), document, NewSentenceChunker(20), embedder)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+
+	if !slices.Equal(embedder.batches, []int{2, 1}) {
+		t.Fatalf("Expected batches of 2 and 1, got %v", embedder.batches)
+	}
+
+	found, err := store.DocumentFindByID(context.Background(), document.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found == nil {
+		t.Fatal("Expected the document to be created")
+	}
+
+	queryEmbeddings, err := embedder.Embed(context.Background(), []string{"update firmware"})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	results, err := store.ChunkSearchSimilar(context.Background(), queryEmbeddings[0], ChunkSearchOptions{
+		Limit: 1,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || results[0].Chunk.Content() != "Update the firmware." || results[0].Chunk.ChunkIndex() != 1 {
+		t.Fatal("Expected the firmware chunk to be the nearest")
+	}
+}
+
+func TestStore_DocumentIngestEmbedderFailure(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("Some text.")
+
+	_, err = store.DocumentIngest(context.Background(), document, NewSentenceChunker(20), NewHashEmbedder(0))
+
+	if err == nil {
+		t.Fatal("expected error from the embedder, but got nil")
+	}
+
+	found, err := store.DocumentFindByID(context.Background(), document.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found != nil {
+		t.Fatal("Document MUST NOT be created when embedding fails")
+	}
+}
+
+func TestStore_DocumentReindex(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("router.txt").
+		SetText("Reset the router. Update the firmware. Check the cables.")
+
+	embedder := &countingEmbedder{Embedder: NewHashEmbedder(32)}
+
+	chunks, err := store.DocumentIngest(context.Background(), document, NewSentenceChunker(25), embedder)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	hash := document.ContentHash()
+
+	document.SetText("Unplug the modem. Reset the router. Check the cables twice.")
+
+	embedder.batches = nil
+
+	reindexed, err := store.DocumentReindex(context.Background(), document, NewSentenceChunker(25), embedder)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if document.ContentHash() == hash {
+		t.Fatal("Expected the content hash of the document to change")
+	}
+
+	// only the new sentences are embedded
+	if !slices.Equal(embedder.batches, []int{2}) {
+		t.Fatalf("Expected a single batch of 2, got %v", embedder.batches)
+	}
+
+	if len(reindexed) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(reindexed))
+	}
+
+	if reindexed[1].ID() != chunks[0].ID() {
+		t.Fatal("Expected the unchanged chunk to be kept")
+	}
+
+	list, err := store.ChunkList(context.Background(), ChunkQuery().
+		SetDocumentID(document.ID()).
+		SetOrderBy(COLUMN_CHUNK_INDEX).
+		SetOrderDirection("asc"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	contents := []string{"Unplug the modem.", "Reset the router.", "Check the cables twice."}
+
+	if len(list) != len(contents) {
+		t.Fatalf("Expected %d stored chunks, got %d", len(contents), len(list))
+	}
+
+	for index, chunk := range list {
+		if chunk.Content() != contents[index] || chunk.ChunkIndex() != index {
+			t.Fatalf("Expected chunk %d to be %q, got %q at %d", index, contents[index], chunk.Content(), chunk.ChunkIndex())
+		}
+
+		if !chunk.IsEmbedded() || chunk.ContentHash() != contentHash(contents[index]) {
+			t.Fatalf("Expected chunk %d to be embedded with its content hash", index)
+		}
+	}
+
+	// reindexing unchanged text embeds nothing
+	embedder.batches = nil
+
+	if _, err := store.DocumentReindex(context.Background(), document, NewSentenceChunker(25), embedder); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(embedder.batches) != 0 {
+		t.Fatalf("Expected no embedding, got %v", embedder.batches)
+	}
+}
+
+func TestStore_DocumentReindexNotFound(t *testing.T) {
+	store := initStoreWith(t, nil)
+
+	embedder := &countingEmbedder{Embedder: NewHashEmbedder(32)}
+
+	missing := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("missing.txt").
+		SetText("Reset the router.")
+
+	if _, err := store.DocumentReindex(context.Background(), missing, NewSentenceChunker(25), embedder); err == nil {
+		t.Fatal("Expected an error reindexing a document not found")
+	}
+
+	ctxA := WithOwnerScope(context.Background(), "owner_a")
+	ctxB := WithOwnerScope(context.Background(), "owner_b")
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("router.txt").
+		SetText("Reset the router.")
+
+	if err := store.DocumentCreate(ctxA, document); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, err := store.DocumentReindex(ctxB, document, NewSentenceChunker(25), embedder); err == nil {
+		t.Fatal("Expected an error reindexing a document of another owner")
+	}
+
+	if len(embedder.batches) != 0 {
+		t.Fatalf("Expected no embedding, got %v", embedder.batches)
+	}
+}
@@ -9,11 +9,12 @@ import (
 	"github.com/doug-martin/goqu/v9"
 	"github.com/dracory/base/database"
 	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/sb"
 	"github.com/samber/lo"
 )
 
 // DocumentCount counts the number of documents that match the query
-func (st *store) DocumentCount(options DocumentQueryInterface) (int64, error) {
+func (st *store) DocumentCount(ctx context.Context, options DocumentQueryInterface) (int64, error) {
 	if st.db == nil {
 		return 0, errors.New("database is not initialized")
 	}
@@ -22,6 +23,12 @@ func (st *store) DocumentCount(options DocumentQueryInterface) (int64, error) {
 		return 0, errors.New("query is nil")
 	}
 
+	options, err := st.documentQueryScoped(ctx, options)
+
+	if err != nil {
+		return -1, err
+	}
+
 	options.SetCountOnly(true)
 
 	q, _, err := options.ToSelectDataset(st)
@@ -44,7 +51,7 @@ func (st *store) DocumentCount(options DocumentQueryInterface) (int64, error) {
 		log.Println(sqlStr)
 	}
 
-	mapped, err := database.SelectToMapString(database.Context(context.Background(), st.db), sqlStr, sqlParams...)
+	mapped, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
 	if err != nil {
 		return -1, err
 	}
@@ -65,7 +72,7 @@ func (st *store) DocumentCount(options DocumentQueryInterface) (int64, error) {
 }
 
 // DocumentCreate creates a new document
-func (st *store) DocumentCreate(document DocumentInterface) error {
+func (st *store) DocumentCreate(ctx context.Context, document DocumentInterface) error {
 	if st.db == nil {
 		return errors.New("database is not initialized")
 	}
@@ -74,6 +81,13 @@ func (st *store) DocumentCreate(document DocumentInterface) error {
 		return errors.New("document ID is required")
 	}
 
+	ownerID, err := st.ownerScopeApply(ctx, document.OwnerID())
+
+	if err != nil {
+		return err
+	}
+
+	document.SetOwnerID(ownerID)
 	document.SetCreatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
 	document.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC))
 
@@ -93,7 +107,7 @@ func (st *store) DocumentCreate(document DocumentInterface) error {
 		st.logger.Debug("Document create query", "query", sqlStr, "params", sqlParams)
 	}
 
-	_, err = database.Execute(database.Context(context.Background(), st.db), sqlStr, sqlParams...)
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...)
 
 	if err != nil {
 		return err
@@ -105,16 +119,17 @@ func (st *store) DocumentCreate(document DocumentInterface) error {
 }
 
 // DocumentDelete permanently deletes an document
-func (st *store) DocumentDelete(document DocumentInterface) error {
+func (st *store) DocumentDelete(ctx context.Context, document DocumentInterface) error {
 	if document == nil {
 		return errors.New("document is nil")
 	}
 
-	return st.DocumentDeleteByID(document.ID())
+	return st.DocumentDeleteByID(ctx, document.ID())
 }
 
-// DocumentDeleteByID permanently deletes an document by ID
-func (st *store) DocumentDeleteByID(id string) error {
+// DocumentDeleteByID permanently deletes an document by ID. With cascade
+// delete enabled, all chunks of the document are deleted with it.
+func (st *store) DocumentDeleteByID(ctx context.Context, id string) error {
 	if st.db == nil {
 		return errors.New("database is not initialized")
 	}
@@ -123,6 +138,27 @@ func (st *store) DocumentDeleteByID(id string) error {
 		return errors.New("document ID is required")
 	}
 
+	inScope, err := st.documentInScope(ctx, id)
+
+	if err != nil || !inScope {
+		return err // documents of other owners are left untouched
+	}
+
+	if !st.cascadeDeleteEnabled {
+		return st.documentDeleteByID(ctx, id)
+	}
+
+	return st.withTx(ctx, func(txStore *store) error {
+		if err := txStore.documentChunksDelete(ctx, id); err != nil {
+			return err
+		}
+
+		return txStore.documentDeleteByID(ctx, id)
+	})
+}
+
+// documentDeleteByID deletes the document row
+func (st *store) documentDeleteByID(ctx context.Context, id string) error {
 	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
 		Delete(st.tableDocument).
 		Prepared(true).
@@ -137,7 +173,7 @@ func (st *store) DocumentDeleteByID(id string) error {
 		st.logger.Debug("Document delete query", "query", sqlStr, "params", sqlParams)
 	}
 
-	_, err = database.Execute(database.Context(context.Background(), st.db), sqlStr, sqlParams...)
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...)
 	if err != nil {
 		return err
 	}
@@ -146,7 +182,7 @@ func (st *store) DocumentDeleteByID(id string) error {
 }
 
 // DocumentExists checks if an document exists
-func (st *store) DocumentExists(id string) (bool, error) {
+func (st *store) DocumentExists(ctx context.Context, id string) (bool, error) {
 	if st.db == nil {
 		return false, errors.New("database is not initialized")
 	}
@@ -155,7 +191,7 @@ func (st *store) DocumentExists(id string) (bool, error) {
 		return false, errors.New("document ID is required")
 	}
 
-	count, err := st.DocumentCount(DocumentQuery().SetID(id))
+	count, err := st.DocumentCount(ctx, DocumentQuery().SetID(id))
 
 	if err != nil {
 		return false, err
@@ -165,7 +201,7 @@ func (st *store) DocumentExists(id string) (bool, error) {
 }
 
 // DocumentFindByID finds an document by ID
-func (st *store) DocumentFindByID(documentID string) (DocumentInterface, error) {
+func (st *store) DocumentFindByID(ctx context.Context, documentID string) (DocumentInterface, error) {
 	if st.db == nil {
 		return nil, errors.New("database is not initialized")
 	}
@@ -174,7 +210,7 @@ func (st *store) DocumentFindByID(documentID string) (DocumentInterface, error)
 		return nil, errors.New("document ID is required")
 	}
 
-	list, err := st.DocumentList(DocumentQuery().
+	list, err := st.DocumentList(ctx, DocumentQuery().
 		SetID(documentID).
 		SetLimit(1))
 	if err != nil {
@@ -189,7 +225,7 @@ func (st *store) DocumentFindByID(documentID string) (DocumentInterface, error)
 }
 
 // DocumentList lists documents based on the query
-func (st *store) DocumentList(query DocumentQueryInterface) ([]DocumentInterface, error) {
+func (st *store) DocumentList(ctx context.Context, query DocumentQueryInterface) ([]DocumentInterface, error) {
 	if st.db == nil {
 		return nil, errors.New("database is not initialized")
 	}
@@ -198,6 +234,12 @@ func (st *store) DocumentList(query DocumentQueryInterface) ([]DocumentInterface
 		return nil, errors.New("query is nil")
 	}
 
+	query, err := st.documentQueryScoped(ctx, query)
+
+	if err != nil {
+		return []DocumentInterface{}, err
+	}
+
 	q, columns, err := query.ToSelectDataset(st)
 
 	if err != nil {
@@ -214,7 +256,7 @@ func (st *store) DocumentList(query DocumentQueryInterface) ([]DocumentInterface
 		log.Println(sqlStr)
 	}
 
-	modelMaps, err := database.SelectToMapString(database.Context(context.Background(), st.db), sqlStr, sqlParams...)
+	modelMaps, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
 
 	if err != nil {
 		return []DocumentInterface{}, err
@@ -230,30 +272,91 @@ func (st *store) DocumentList(query DocumentQueryInterface) ([]DocumentInterface
 	return list, nil
 }
 
-// DocumentSoftDelete soft deletes an document
-func (st *store) DocumentSoftDelete(document DocumentInterface) error {
+// DocumentRestore restores a soft deleted document. With cascade delete
+// enabled, the chunks soft deleted along with the document are restored
+// with it. Chunks soft deleted on their own stay soft deleted.
+func (st *store) DocumentRestore(ctx context.Context, document DocumentInterface) error {
+	if document == nil {
+		return errors.New("document is nil")
+	}
+
+	document.SetSoftDeletedAt(sb.MAX_DATETIME)
+
+	if !st.cascadeDeleteEnabled {
+		return st.DocumentUpdate(ctx, document)
+	}
+
+	return st.withTx(ctx, func(txStore *store) error {
+		if err := txStore.DocumentUpdate(ctx, document); err != nil {
+			return err
+		}
+
+		return txStore.documentChunksRestore(ctx, document.ID())
+	})
+}
+
+// DocumentRestoreByID restores a soft deleted document by ID
+func (st *store) DocumentRestoreByID(ctx context.Context, id string) error {
+	if st.db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	if id == "" {
+		return errors.New("document ID is required")
+	}
+
+	list, err := st.DocumentList(ctx, DocumentQuery().
+		SetID(id).
+		SetWithSoftDeleted(true).
+		SetLimit(1))
+
+	if err != nil {
+		return err
+	}
+
+	if len(list) < 1 {
+		return errors.New("document not found")
+	}
+
+	return st.DocumentRestore(ctx, list[0])
+}
+
+// DocumentSoftDelete soft deletes an document. With cascade delete
+// enabled, the chunks of the document, which are not soft deleted yet,
+// are soft deleted at the same time.
+func (st *store) DocumentSoftDelete(ctx context.Context, document DocumentInterface) error {
 	if document == nil {
 		return errors.New("document is nil")
 	}
 
 	document.SetSoftDeletedAt(carbon.Now(carbon.UTC).ToDateTimeString())
 
-	return st.DocumentUpdate(document)
+	if !st.cascadeDeleteEnabled {
+		return st.DocumentUpdate(ctx, document)
+	}
+
+	return st.withTx(ctx, func(txStore *store) error {
+		if err := txStore.DocumentUpdate(ctx, document); err != nil {
+			return err
+		}
+
+		return txStore.documentChunksSoftDelete(ctx, document.ID(), document.SoftDeletedAt())
+	})
 }
 
 // DocumentSoftDeleteByID soft deletes an document by ID
-func (st *store) DocumentSoftDeleteByID(id string) error {
-	document, err := st.DocumentFindByID(id)
+func (st *store) DocumentSoftDeleteByID(ctx context.Context, id string) error {
+	document, err := st.DocumentFindByID(ctx, id)
 
 	if err != nil {
 		return err
 	}
 
-	return st.DocumentSoftDelete(document)
+	return st.DocumentSoftDelete(ctx, document)
 }
 
 // DocumentUpdate updates an document
-func (st *store) DocumentUpdate(document DocumentInterface) error {
+func (st *store) DocumentUpdate(ctx context.Context, document DocumentInterface) error {
 	if st.db == nil {
 		return errors.New("database is not initialized")
 	}
@@ -266,6 +369,16 @@ func (st *store) DocumentUpdate(document DocumentInterface) error {
 		return errors.New("document ID is required")
 	}
 
+	ownerID, err := st.ownerScopeApply(ctx, document.OwnerID())
+
+	if err != nil {
+		return err
+	}
+
+	if ownerID != document.OwnerID() {
+		document.SetOwnerID(ownerID)
+	}
+
 	document.SetUpdatedAt(carbon.Now(carbon.UTC).ToDateTimeString())
 
 	dataChanged := document.DataChanged()
@@ -276,6 +389,16 @@ func (st *store) DocumentUpdate(document DocumentInterface) error {
 		return nil
 	}
 
+	inScope, err := st.documentInScope(ctx, document.ID())
+
+	if err != nil {
+		return err
+	}
+
+	if !inScope {
+		return errors.New("document not found")
+	}
+
 	sqlStr, params, errSql := goqu.Dialect(st.dbDriverName).
 		Update(st.tableDocument).
 		Prepared(true).
@@ -291,7 +414,7 @@ func (st *store) DocumentUpdate(document DocumentInterface) error {
 		log.Println(sqlStr)
 	}
 
-	_, err := st.db.Exec(sqlStr, params...)
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, params...)
 
 	document.MarkAsNotDirty()
 
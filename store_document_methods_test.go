@@ -1,6 +1,9 @@
 package ragstore
 
 import (
+	"context"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/gouniverse/sb"
@@ -30,23 +33,23 @@ func TestStore_DocumentCount(t *testing.T) {
 		SetFileName("test3.txt").
 		SetText("This is third test document.")
 
-	err = store.DocumentCreate(document1)
+	err = store.DocumentCreate(context.Background(), document1)
 	if err != nil {
 		t.Fatal("unexpected error creating document1:", err)
 	}
 
-	err = store.DocumentCreate(document2)
+	err = store.DocumentCreate(context.Background(), document2)
 	if err != nil {
 		t.Fatal("unexpected error creating document2:", err)
 	}
 
-	err = store.DocumentCreate(document3)
+	err = store.DocumentCreate(context.Background(), document3)
 	if err != nil {
 		t.Fatal("unexpected error creating document3:", err)
 	}
 
 	// Test counting all documents
-	allCount, err := store.DocumentCount(DocumentQuery())
+	allCount, err := store.DocumentCount(context.Background(), DocumentQuery())
 	if err != nil {
 		t.Fatal("unexpected error counting all documents:", err)
 	}
@@ -56,7 +59,7 @@ func TestStore_DocumentCount(t *testing.T) {
 	}
 
 	// Test counting by monitor ID
-	// documentCount, err := store.DocumentCount(DocumentQuery().SetOwnerID(testUser_O1))
+	// documentCount, err := store.DocumentCount(context.Background(), DocumentQuery().SetOwnerID(testUser_O1))
 	// if err != nil {
 	// 	t.Fatal("unexpected error counting monitor documents:", err)
 	// }
@@ -66,7 +69,7 @@ func TestStore_DocumentCount(t *testing.T) {
 	// }
 
 	// Test counting by status
-	activeCount, err := store.DocumentCount(DocumentQuery().SetStatus(DOCUMENT_STATUS_ACTIVE))
+	activeCount, err := store.DocumentCount(context.Background(), DocumentQuery().SetStatus(DOCUMENT_STATUS_ACTIVE))
 	if err != nil {
 		t.Fatal("unexpected error counting active documents:", err)
 	}
@@ -92,7 +95,7 @@ func TestStore_DocumentCreate(t *testing.T) {
 		SetFileName("test1.txt").
 		SetText("This is first test document.")
 
-	err = store.DocumentCreate(document)
+	err = store.DocumentCreate(context.Background(), document)
 
 	if err != nil {
 		t.Fatal("unexpected error:", err)
@@ -111,13 +114,13 @@ func TestStore_DocumentCreateDuplicate(t *testing.T) {
 		SetFileName("test1.txt").
 		SetText("This is first test document.")
 
-	err = store.DocumentCreate(document)
+	err = store.DocumentCreate(context.Background(), document)
 	if err != nil {
 		t.Fatal("unexpected error on first create:", err)
 	}
 
 	// Try to create the same document again
-	err = store.DocumentCreate(document)
+	err = store.DocumentCreate(context.Background(), document)
 	if err == nil {
 		t.Fatal("expected error for duplicate document, but got nil")
 	}
@@ -145,12 +148,12 @@ func TestStore_DocumentFindByID(t *testing.T) {
 		t.Fatal("unexpected error:", err)
 	}
 
-	err = store.DocumentCreate(document)
+	err = store.DocumentCreate(context.Background(), document)
 	if err != nil {
 		t.Error("unexpected error:", err)
 	}
 
-	documentFound, errFind := store.DocumentFindByID(document.ID())
+	documentFound, errFind := store.DocumentFindByID(context.Background(), document.ID())
 
 	if errFind != nil {
 		t.Fatal("unexpected error:", errFind)
@@ -222,7 +225,7 @@ func TestStore_DocumentFindByIDNotFound(t *testing.T) {
 		t.Fatal("unexpected error:", err)
 	}
 
-	documentFound, errFind := store.DocumentFindByID("non-existent-id")
+	documentFound, errFind := store.DocumentFindByID(context.Background(), "non-existent-id")
 
 	if errFind != nil {
 		t.Fatal("unexpected error:", errFind)
@@ -244,7 +247,7 @@ func TestStore_DocumentFindByIDNotFound(t *testing.T) {
 // 		SetStatus(DOCUMENT_STATUS_ACTIVE)
 
 // 	// Try to update a non-existent document
-// 	err = store.DocumentUpdate(document)
+// 	err = store.DocumentUpdate(context.Background(), document)
 // 	if err == nil {
 // 		t.Fatal("expected error for updating non-existent document, but got nil")
 // 	}
@@ -262,19 +265,19 @@ func TestStore_DocumentDelete(t *testing.T) {
 		SetFileName("test1.txt").
 		SetText("This is first test document.")
 
-	err = store.DocumentCreate(document)
+	err = store.DocumentCreate(context.Background(), document)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
 	// Delete the document
-	err = store.DocumentDelete(document)
+	err = store.DocumentDelete(context.Background(), document)
 	if err != nil {
 		t.Fatal("unexpected error on delete:", err)
 	}
 
 	// Verify the document is deleted
-	deletedDocument, err := store.DocumentFindByID(document.ID())
+	deletedDocument, err := store.DocumentFindByID(context.Background(), document.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding deleted document:", err)
 	}
@@ -296,19 +299,19 @@ func TestStore_DocumentDeleteByID(t *testing.T) {
 		SetFileName("test1.txt").
 		SetText("This is first test document.")
 
-	err = store.DocumentCreate(document)
+	err = store.DocumentCreate(context.Background(), document)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
 	// Delete the document by ID
-	err = store.DocumentDeleteByID(document.ID())
+	err = store.DocumentDeleteByID(context.Background(), document.ID())
 	if err != nil {
 		t.Fatal("unexpected error on delete by ID:", err)
 	}
 
 	// Verify the document is deleted
-	deletedDocument, err := store.DocumentFindByID(document.ID())
+	deletedDocument, err := store.DocumentFindByID(context.Background(), document.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding deleted document:", err)
 	}
@@ -336,18 +339,18 @@ func TestStore_DocumentList(t *testing.T) {
 		SetFileName("test2.txt").
 		SetText("This is second test document.")
 
-	err = store.DocumentCreate(document1)
+	err = store.DocumentCreate(context.Background(), document1)
 	if err != nil {
 		t.Fatal("unexpected error creating document1:", err)
 	}
 
-	err = store.DocumentCreate(document2)
+	err = store.DocumentCreate(context.Background(), document2)
 	if err != nil {
 		t.Fatal("unexpected error creating document2:", err)
 	}
 
 	// Test listing all documents
-	allDocuments, err := store.DocumentList(DocumentQuery())
+	allDocuments, err := store.DocumentList(context.Background(), DocumentQuery())
 	if err != nil {
 		t.Fatal("unexpected error listing all documents:", err)
 	}
@@ -357,7 +360,7 @@ func TestStore_DocumentList(t *testing.T) {
 	}
 
 	// Test filtering by owner ID
-	// ownerDocuments, err := store.DocumentList(DocumentQuery().SetOwnerID(testUser_O1))
+	// ownerDocuments, err := store.DocumentList(context.Background(), DocumentQuery().SetOwnerID(testUser_O1))
 	// if err != nil {
 	// 	t.Fatal("unexpected error listing owner documents:", err)
 	// }
@@ -367,7 +370,7 @@ func TestStore_DocumentList(t *testing.T) {
 	// }
 
 	// Test filtering by status
-	activeDocuments, err := store.DocumentList(DocumentQuery().SetStatus(DOCUMENT_STATUS_ACTIVE))
+	activeDocuments, err := store.DocumentList(context.Background(), DocumentQuery().SetStatus(DOCUMENT_STATUS_ACTIVE))
 	if err != nil {
 		t.Fatal("unexpected error listing active documents:", err)
 	}
@@ -377,7 +380,7 @@ func TestStore_DocumentList(t *testing.T) {
 	}
 
 	// Test limit and offset
-	limitedDocuments, err := store.DocumentList(DocumentQuery().SetLimit(1))
+	limitedDocuments, err := store.DocumentList(context.Background(), DocumentQuery().SetLimit(1))
 	if err != nil {
 		t.Fatal("unexpected error listing limited documents:", err)
 	}
@@ -386,7 +389,7 @@ func TestStore_DocumentList(t *testing.T) {
 		t.Fatalf("Expected 1 document with limit, got %d", len(limitedDocuments))
 	}
 
-	offsetDocuments, err := store.DocumentList(DocumentQuery().SetOffset(1).SetLimit(2))
+	offsetDocuments, err := store.DocumentList(context.Background(), DocumentQuery().SetOffset(1).SetLimit(2))
 	if err != nil {
 		t.Fatal("unexpected error listing offset documents:", err)
 	}
@@ -408,19 +411,19 @@ func TestStore_DocumentSoftDelete(t *testing.T) {
 		SetFileName("test1.txt").
 		SetText("This is first test document.")
 
-	err = store.DocumentCreate(document)
+	err = store.DocumentCreate(context.Background(), document)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
 	// Soft delete the document
-	err = store.DocumentSoftDelete(document)
+	err = store.DocumentSoftDelete(context.Background(), document)
 	if err != nil {
 		t.Fatal("unexpected error on soft delete:", err)
 	}
 
 	// Verify the document is soft deleted (not found by default)
-	softDeletedDocument, err := store.DocumentFindByID(document.ID())
+	softDeletedDocument, err := store.DocumentFindByID(context.Background(), document.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding soft deleted document:", err)
 	}
@@ -435,7 +438,7 @@ func TestStore_DocumentSoftDelete(t *testing.T) {
 		SetID(document.ID()).
 		SetLimit(1)
 
-	documentFindWithDeleted, err := store.DocumentList(query)
+	documentFindWithDeleted, err := store.DocumentList(context.Background(), query)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
@@ -465,19 +468,19 @@ func TestStore_DocumentSoftDeleteByID(t *testing.T) {
 		SetFileName("test1.txt").
 		SetText("This is first test document.")
 
-	err = store.DocumentCreate(document)
+	err = store.DocumentCreate(context.Background(), document)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
 
 	// Soft delete the document by ID
-	err = store.DocumentSoftDeleteByID(document.ID())
+	err = store.DocumentSoftDeleteByID(context.Background(), document.ID())
 	if err != nil {
 		t.Fatal("unexpected error on soft delete by ID:", err)
 	}
 
 	// Verify the document is soft deleted (not found by default)
-	softDeletedDocument, err := store.DocumentFindByID(document.ID())
+	softDeletedDocument, err := store.DocumentFindByID(context.Background(), document.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding soft deleted document:", err)
 	}
@@ -492,7 +495,7 @@ func TestStore_DocumentSoftDeleteByID(t *testing.T) {
 		SetID(document.ID()).
 		SetLimit(1)
 
-	documentFindWithDeleted, err := store.DocumentList(query)
+	documentFindWithDeleted, err := store.DocumentList(context.Background(), query)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
@@ -522,7 +525,7 @@ func TestStore_DocumentUpdate(t *testing.T) {
 		SetFileName("test1.txt").
 		SetText("This is first test document.")
 
-	err = store.DocumentCreate(document)
+	err = store.DocumentCreate(context.Background(), document)
 	if err != nil {
 		t.Fatal("unexpected error:", err)
 	}
@@ -531,13 +534,13 @@ func TestStore_DocumentUpdate(t *testing.T) {
 	document.SetStatus(DOCUMENT_STATUS_INACTIVE).
 		SetMemo("Resolved by ops team")
 
-	err = store.DocumentUpdate(document)
+	err = store.DocumentUpdate(context.Background(), document)
 	if err != nil {
 		t.Fatal("unexpected error on update:", err)
 	}
 
 	// Verify the update
-	updatedDocument, err := store.DocumentFindByID(document.ID())
+	updatedDocument, err := store.DocumentFindByID(context.Background(), document.ID())
 	if err != nil {
 		t.Fatal("unexpected error finding updated document:", err)
 	}
@@ -550,3 +553,401 @@ func TestStore_DocumentUpdate(t *testing.T) {
 		t.Fatalf("Memo not updated. Expected 'Resolved by ops team', got '%s'", updatedDocument.Memo())
 	}
 }
+
+func initCascadeDocument(t *testing.T, store StoreInterface) (DocumentInterface, []ChunkInterface) {
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("text")
+
+	chunks := []ChunkInterface{
+		NewChunk().SetChunkIndex(0).SetContent("cascade one").SetEmbedding([]float32{1.0, 0.0}),
+		NewChunk().SetChunkIndex(1).SetContent("cascade two").SetEmbedding([]float32{0.0, 1.0}),
+	}
+
+	if err := store.DocumentCreateWithChunks(context.Background(), document, chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return document, chunks
+}
+
+func TestStore_DocumentDeleteByIDCascade(t *testing.T) {
+	storeCascade := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.HNSWEnabled = true
+		opts.CascadeDeleteEnabled = true
+	})
+
+	document, chunks := initCascadeDocument(t, storeCascade)
+	documentOther, _ := initCascadeDocument(t, storeCascade)
+
+	// a soft deleted chunk is deleted too
+	if err := storeCascade.ChunkSoftDeleteByID(context.Background(), chunks[1].ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeCascade.DocumentDeleteByID(context.Background(), document.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	count, err := storeCascade.ChunkCount(context.Background(), ChunkQuery().
+		SetDocumentID(document.ID()).
+		SetWithSoftDeleted(true))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatalf("Expected the chunks of the document to be deleted, got %d", count)
+	}
+
+	count, err = storeCascade.ChunkCount(context.Background(), ChunkQuery().SetDocumentID(documentOther.ID()))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected the chunks of the other document to be kept, got %d", count)
+	}
+
+	if storeCascade.(*store).hnsw.Has(chunks[0].ID()) {
+		t.Fatal("Deleted chunk MUST NOT be in the HNSW index")
+	}
+
+	results, err := storeCascade.ChunkSearchKeyword(context.Background(), "cascade", ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected only the chunks of the other document to be found, got %d", len(results))
+	}
+}
+
+func TestStore_DocumentSoftDeleteCascade(t *testing.T) {
+	storeCascade := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.HNSWEnabled = true
+		opts.CascadeDeleteEnabled = true
+	})
+
+	document, chunks := initCascadeDocument(t, storeCascade)
+
+	if err := storeCascade.DocumentSoftDeleteByID(context.Background(), document.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := storeCascade.ChunkList(context.Background(), ChunkQuery().SetDocumentID(document.ID()))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 0 {
+		t.Fatalf("Expected the chunks of the document not to be listed, got %d", len(list))
+	}
+
+	list, err = storeCascade.ChunkList(context.Background(), ChunkQuery().
+		SetDocumentID(document.ID()).
+		SetOnlySoftDeleted(true))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 soft deleted chunks, got %d", len(list))
+	}
+
+	softDeleted, err := storeCascade.DocumentList(context.Background(), DocumentQuery().
+		SetID(document.ID()).
+		SetWithSoftDeleted(true))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(softDeleted) != 1 || list[0].SoftDeletedAt() != softDeleted[0].SoftDeletedAt() {
+		t.Fatal("Expected the chunks to be soft deleted at the time of the document")
+	}
+
+	if storeCascade.(*store).hnsw.Has(chunks[0].ID()) {
+		t.Fatal("Soft deleted chunk MUST NOT be in the HNSW index")
+	}
+
+	results, err := storeCascade.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{Exact: true})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 0 {
+		t.Fatalf("Expected soft deleted chunks not to be found, got %d", len(results))
+	}
+}
+
+func TestStore_DocumentDeleteByIDWithoutCascade(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document, _ := initCascadeDocument(t, store)
+
+	if err := store.DocumentDeleteByID(context.Background(), document.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	count, err := store.ChunkCount(context.Background(), ChunkQuery().SetDocumentID(document.ID()))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected the chunks to be kept without cascade delete, got %d", count)
+	}
+}
+
+func TestStore_DocumentRestoreByID(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("text")
+
+	if err := store.DocumentCreate(context.Background(), document); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := store.DocumentSoftDeleteByID(context.Background(), document.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := store.DocumentRestoreByID(context.Background(), document.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	restored, err := store.DocumentFindByID(context.Background(), document.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if restored == nil {
+		t.Fatal("Expected the restored document to be found")
+	}
+
+	if !strings.HasPrefix(restored.SoftDeletedAt(), sb.MAX_DATETIME) {
+		t.Fatal("Expected SoftDeletedAt to be MAX_DATETIME, got:", restored.SoftDeletedAt())
+	}
+
+	if err := store.DocumentRestoreByID(context.Background(), "missing"); err == nil {
+		t.Fatal("expected error for missing document, but got nil")
+	}
+}
+
+func TestStore_DocumentRestoreCascade(t *testing.T) {
+	storeCascade := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.HNSWEnabled = true
+		opts.CascadeDeleteEnabled = true
+	})
+
+	document, chunks := initCascadeDocument(t, storeCascade)
+
+	// soft deleted on its own, before the document
+	chunks[1].SetSoftDeletedAt("2020-01-01 00:00:00")
+
+	if err := storeCascade.ChunkUpdate(context.Background(), chunks[1]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeCascade.DocumentSoftDeleteByID(context.Background(), document.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeCascade.DocumentRestoreByID(context.Background(), document.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := storeCascade.ChunkList(context.Background(), ChunkQuery().SetDocumentID(document.ID()))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 1 || list[0].ID() != chunks[0].ID() {
+		t.Fatal("Expected only the chunk soft deleted with the document to be restored")
+	}
+
+	if !storeCascade.(*store).hnsw.Has(chunks[0].ID()) {
+		t.Fatal("Expected the restored chunk to be in the HNSW index")
+	}
+
+	if storeCascade.(*store).hnsw.Has(chunks[1].ID()) {
+		t.Fatal("Soft deleted chunk MUST NOT be in the HNSW index")
+	}
+}
+
+func TestStore_DocumentRestoreCascadeSameSecond(t *testing.T) {
+	storeCascade := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.HNSWEnabled = true
+		opts.CascadeDeleteEnabled = true
+	})
+
+	document, chunks := initCascadeDocument(t, storeCascade)
+
+	if err := storeCascade.DocumentSoftDelete(context.Background(), document); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// restored, then soft deleted on its own in the same second as the document
+	if err := storeCascade.ChunkRestoreByID(context.Background(), chunks[1].ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunks[1].SetSoftDeletedAt(document.SoftDeletedAt())
+
+	if err := storeCascade.ChunkUpdate(context.Background(), chunks[1]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeCascade.DocumentRestoreByID(context.Background(), document.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := storeCascade.ChunkList(context.Background(), ChunkQuery().SetDocumentID(document.ID()))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 1 || list[0].ID() != chunks[0].ID() {
+		t.Fatal("Expected only the chunk soft deleted with the document to be restored")
+	}
+}
+
+func TestStore_DocumentListMetaFilters(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	sources := []string{"confluence", "jira", "confluence"}
+	documents := []DocumentInterface{}
+
+	for i, source := range sources {
+		document := NewDocument().
+			SetStatus(DOCUMENT_STATUS_ACTIVE).
+			SetFileName("test" + strconv.Itoa(i) + ".txt").
+			SetText("text")
+
+		if err := document.SetMeta("source", source); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if err := store.DocumentCreate(context.Background(), document); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		documents = append(documents, document)
+	}
+
+	count, err := store.DocumentCount(context.Background(), DocumentQuery().SetMetaEquals("source", "confluence"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 2 {
+		t.Fatalf("Expected 2 confluence documents, got %d", count)
+	}
+
+	list, err := store.DocumentList(context.Background(), DocumentQuery().SetMetaIn("source", []string{"jira", "github"}))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 1 || list[0].ID() != documents[1].ID() {
+		t.Fatal("Expected the jira document")
+	}
+
+	count, err = store.DocumentCount(context.Background(), DocumentQuery().SetMetaExists("author"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatalf("Expected no documents with an author, got %d", count)
+	}
+}
+
+func TestStore_DocumentListMetaFiltersLegacyMetas(t *testing.T) {
+	storeMetas, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	documents := []DocumentInterface{}
+
+	for i := 0; i < 2; i++ {
+		document := NewDocument().
+			SetStatus(DOCUMENT_STATUS_ACTIVE).
+			SetFileName("test" + strconv.Itoa(i) + ".txt").
+			SetText("text")
+
+		if err := document.SetMeta("source", "jira"); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if err := storeMetas.DocumentCreate(context.Background(), document); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		documents = append(documents, document)
+	}
+
+	// rows created before the metas were always set hold an empty string
+	_, err = storeMetas.(*store).db.ExecContext(context.Background(),
+		`UPDATE "document_table" SET "metas" = '' WHERE "id" = ?`, documents[1].ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := storeMetas.DocumentList(context.Background(), DocumentQuery().SetMetaEquals("source", "jira"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 1 || list[0].ID() != documents[0].ID() {
+		t.Fatal("Expected only the document with metas to match")
+	}
+
+	count, err := storeMetas.DocumentCount(context.Background(), DocumentQuery().SetMetaExists("source"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 1 {
+		t.Fatal("Expected 1 document with the meta, got", count)
+	}
+}
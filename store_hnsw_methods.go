@@ -0,0 +1,488 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dracory/base/database"
+	"github.com/dromara/carbon/v2"
+	"github.com/samber/lo"
+)
+
+// HNSWSnapshotSave writes the HNSW index to a file, so that a store
+// created later with the same HNSWSnapshotPath can warm start from it.
+// When the path is empty the configured HNSWSnapshotPath is used.
+func (st *store) HNSWSnapshotSave(ctx context.Context, path string) error {
+	if st.hnsw == nil {
+		return errors.New("hnsw index is not enabled")
+	}
+
+	if path == "" {
+		path = st.hnswSnapshotPath
+	}
+
+	if path == "" {
+		return errors.New("hnsw snapshot path is required")
+	}
+
+	if err := st.hnswSync(ctx); err != nil {
+		return err
+	}
+
+	// the watermark is read before the index is serialised, so that any
+	// change made in between is replayed on load
+	watermark, err := st.hnswWatermark(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(file.Name()) // no-op once renamed
+
+	if err := st.hnsw.WriteSnapshot(file, watermark); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), path)
+}
+
+// hnswBuild builds the HNSW index from the embeddings of all chunks,
+// which are not soft deleted
+func (st *store) hnswBuild(ctx context.Context) error {
+	if st.hnsw == nil {
+		return errors.New("hnsw index is not enabled")
+	}
+
+	q, _, err := ChunkQuery().ToSelectDataset(st)
+
+	if err != nil {
+		return err
+	}
+
+	sqlStr, sqlParams, err := q.
+		Select(COLUMN_ID, COLUMN_EMBEDDING).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.Query(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var embeddingRaw []byte
+
+		if err := rows.Scan(&id, &embeddingRaw); err != nil {
+			return err
+		}
+
+		embedding, err := embeddingFromString(string(embeddingRaw))
+
+		if err != nil {
+			return errors.New("chunk " + id + ": " + err.Error())
+		}
+
+		if err := st.hnsw.Upsert(id, embedding); err != nil {
+			return errors.New("chunk " + id + ": " + err.Error())
+		}
+	}
+
+	return rows.Err()
+}
+
+// hnswRebuild builds a new HNSW index from the chunk table, while the current
+// index keeps answering searches, then swaps the graphs
+func (st *store) hnswRebuild(ctx context.Context) error {
+	if st.hnsw == nil {
+		return nil
+	}
+
+	rebuilt := *st
+	rebuilt.tx = nil
+	rebuilt.hnswPending = nil
+	rebuilt.hnsw = newHnswIndex(st.hnsw.metric, st.hnsw.m, st.hnsw.efConstruction, st.hnsw.efSearch)
+
+	if err := rebuilt.hnswBuild(ctx); err != nil {
+		return err
+	}
+
+	st.hnsw.Replace(rebuilt.hnsw)
+
+	return nil
+}
+
+// hnswSync rebuilds the HNSW index, once a transaction carried by a
+// context, which changed it, was committed or rolled back by the caller.
+// The index is read outside the transaction of the context, if any.
+func (st *store) hnswSync(ctx context.Context) error {
+	if st.hnswContextTxs == nil {
+		return nil
+	}
+
+	st.hnswContextTxs.mu.Lock()
+
+	done := false
+
+	for tx := range st.hnswContextTxs.txs {
+		if contextTxDone(ctx, tx) {
+			delete(st.hnswContextTxs.txs, tx)
+			done = true
+		}
+	}
+
+	st.hnswContextTxs.mu.Unlock()
+
+	if !done {
+		return nil
+	}
+
+	return st.hnswRebuild(database.Context(ctx, st.db))
+}
+
+// hnswSnapshotLoad loads the HNSW index from a snapshot file, then replays
+// the chunks changed since the snapshot was taken and drops the chunks
+// deleted since. Reports false when there is no snapshot file.
+func (st *store) hnswSnapshotLoad(ctx context.Context, path string) (bool, error) {
+	file, err := os.Open(path)
+
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	defer file.Close()
+
+	watermark, err := st.hnsw.ReadSnapshot(file)
+
+	if err != nil {
+		return false, err
+	}
+
+	if err := st.hnswReplay(ctx, watermark); err != nil {
+		return false, err
+	}
+
+	ids, err := st.chunkSearchIDs(withOwnerScopeAll(ctx), ChunkQuery())
+
+	if err != nil {
+		return false, err
+	}
+
+	existing := lo.Keyify(ids)
+
+	for _, id := range st.hnsw.IDs() {
+		if _, exists := existing[id]; !exists {
+			st.hnsw.Remove(id)
+		}
+	}
+
+	return true, nil
+}
+
+// hnswReplay applies the chunks updated at or after the watermark to
+// the HNSW index
+func (st *store) hnswReplay(ctx context.Context, watermark string) error {
+	q, _, err := ChunkQuery().
+		SetWithSoftDeleted(true).
+		ToSelectDataset(st)
+
+	if err != nil {
+		return err
+	}
+
+	if watermark != "" {
+		q = q.Where(goqu.C(COLUMN_UPDATED_AT).Gte(watermark))
+	}
+
+	sqlStr, sqlParams, err := q.
+		Select(COLUMN_ID, COLUMN_EMBEDDING, COLUMN_SOFT_DELETED_AT).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if err := st.hnswChunkUpsert(NewChunkFromExistingData(row)); err != nil {
+			return errors.New("chunk " + row[COLUMN_ID] + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// hnswWatermark returns the latest updated_at value of the chunk table
+func (st *store) hnswWatermark(ctx context.Context) (string, error) {
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		From(st.tableDocumentChunk).
+		Select(goqu.MAX(COLUMN_UPDATED_AT).As("watermark")).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return "", err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(rows) < 1 {
+		return "", nil
+	}
+
+	return rows[0]["watermark"], nil
+}
+
+// hnswChunkUpsert brings the HNSW index up to date with the written chunk
+func (st *store) hnswChunkUpsert(chunk ChunkInterface) error {
+	if st.hnsw == nil {
+		return nil
+	}
+
+	id := chunk.ID()
+
+	if chunkIsSoftDeleted(chunk) {
+		return st.hnswApply(func() error {
+			st.hnsw.Remove(id)
+			return nil
+		})
+	}
+
+	embedding, err := chunk.EmbeddingE()
+
+	if err != nil {
+		return err
+	}
+
+	return st.hnswApply(func() error {
+		return st.hnsw.Upsert(id, embedding)
+	})
+}
+
+// hnswChunkRemove removes the deleted chunk from the HNSW index
+func (st *store) hnswChunkRemove(id string) {
+	if st.hnsw == nil {
+		return
+	}
+
+	st.hnswApply(func() error {
+		st.hnsw.Remove(id)
+		return nil
+	})
+}
+
+// hnswApply applies the change to the HNSW index, or in a transaction,
+// defers it until the transaction is committed
+func (st *store) hnswApply(change func() error) error {
+	if st.hnswPending != nil {
+		*st.hnswPending = append(*st.hnswPending, change)
+		return nil
+	}
+
+	return change()
+}
+
+// hnswFilterOverFetch is the factor of the limit, by which a filtered
+// search over-fetches candidates from the HNSW index, to leave room for
+// the candidates the query filters out. Each further round fetches as many
+// again times the factor, up to hnswFilterMaxRounds rounds.
+const hnswFilterOverFetch = 4
+
+// hnswFilterMaxRounds is the number of over-fetch rounds of a filtered
+// search, before falling back to the exact search
+const hnswFilterMaxRounds = 3
+
+// chunkSearchHnsw searches the HNSW index. It reports not found when the
+// exact search should be used instead, i.e. when the query asks for soft
+// deleted chunks, or the index cannot fill the limit from the chunks
+// matching the query.
+//
+// A query by chunk or document IDs is looked up first, and the index only
+// returns the matching chunks, or when few enough, they are scored exactly.
+// Any other query is applied to the candidates the index returns, which
+// are over-fetched, so the chunk table is not scanned as a whole.
+func (st *store) chunkSearchHnsw(ctx context.Context, queryEmbedding []float32, limit int, query ChunkQueryInterface) ([]scoredID, bool, error) {
+	if query != nil && (query.GetWithSoftDeleted() || query.GetOnlySoftDeleted()) {
+		return nil, false, nil // the index holds no soft deleted chunks
+	}
+
+	if err := st.hnswSync(ctx); err != nil {
+		return nil, false, err
+	}
+
+	if query == nil {
+		ranked, err := st.hnsw.Search(queryEmbedding, limit, nil)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		return ranked, len(ranked) >= min(limit, st.hnsw.Len()), nil
+	}
+
+	if chunkQueryIsSelective(query) {
+		return st.chunkSearchHnswAllowed(ctx, queryEmbedding, limit, query)
+	}
+
+	fetch := limit
+
+	for range hnswFilterMaxRounds {
+		fetch *= hnswFilterOverFetch
+
+		candidates, err := st.hnsw.Search(queryEmbedding, fetch, nil)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		ranked, err := st.chunkSearchHnswFilter(ctx, candidates, query)
+
+		if err != nil {
+			return nil, false, err
+		}
+
+		if len(ranked) >= limit {
+			return ranked[:limit], true, nil
+		}
+
+		// every chunk of the index was a candidate
+		if len(candidates) >= st.hnsw.Len() {
+			return ranked, true, nil
+		}
+
+		if len(candidates) < fetch {
+			break // the graph yields no further candidates
+		}
+	}
+
+	return nil, false, nil // too few candidates match the query
+}
+
+// chunkSearchHnswAllowed searches the HNSW index for the chunks matching
+// the selective query, which are looked up first
+func (st *store) chunkSearchHnswAllowed(ctx context.Context, queryEmbedding []float32, limit int, query ChunkQueryInterface) ([]scoredID, bool, error) {
+	ids, err := st.chunkSearchIDs(ctx, query)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	eligible := lo.CountBy(ids, st.hnsw.Has)
+
+	if eligible <= st.hnsw.efSearch {
+		return nil, false, nil // few enough to score exactly
+	}
+
+	allowed := lo.Keyify(ids)
+
+	ranked, err := st.hnsw.Search(queryEmbedding, limit, func(id string) bool {
+		_, exists := allowed[id]
+		return exists
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(ranked) < min(limit, eligible) {
+		return nil, false, nil
+	}
+
+	return ranked, true, nil
+}
+
+// chunkSearchHnswFilter returns the candidates matching the query, keeping
+// their order
+func (st *store) chunkSearchHnswFilter(ctx context.Context, candidates []scoredID, query ChunkQueryInterface) ([]scoredID, error) {
+	ids := lo.Map(candidates, func(item scoredID, _ int) string {
+		return item.id
+	})
+
+	matching := map[string]struct{}{}
+
+	for _, batch := range lo.Chunk(ids, st.chunkBulkBatchSize(1)) {
+		matched, err := st.chunkSearchIDs(ctx, chunkQueryCopy(query).SetIDIn(batch))
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range matched {
+			matching[id] = struct{}{}
+		}
+	}
+
+	return lo.Filter(candidates, func(item scoredID, _ int) bool {
+		_, exists := matching[item.id]
+		return exists
+	}), nil
+}
+
+// chunkQueryIsSelective checks if the query is by chunk or document IDs,
+// whose chunks are looked up by index and are few compared to the table
+func chunkQueryIsSelective(query ChunkQueryInterface) bool {
+	return query.IsIDSet() ||
+		query.IsIDInSet() ||
+		query.IsDocumentIDSet() ||
+		query.IsDocumentIDInSet()
+}
+
+// chunkIsSoftDeleted checks if the soft delete time of the chunk is in the
+// past, the same way the chunk queries do
+func chunkIsSoftDeleted(chunk ChunkInterface) bool {
+	softDeletedAt := chunk.SoftDeletedAt()
+
+	if softDeletedAt == "" {
+		return false
+	}
+
+	return softDeletedAt <= carbon.Now(carbon.UTC).ToDateTimeString()
+}
@@ -1,26 +1,57 @@
 package ragstore
 
+import (
+	"context"
+	"time"
+)
+
 type StoreInterface interface {
-	AutoMigrate() error
+	AutoMigrate(ctx context.Context) error
 	EnableDebug(enabled bool)
+	HNSWSnapshotSave(ctx context.Context, path string) error
+	PurgeSoftDeleted(ctx context.Context, olderThan time.Duration) (PurgeResult, error)
+	ReembedAll(ctx context.Context, embedder Embedder, opts ReembedOptions) (ReembedProgress, error)
+	ReembedCutover(ctx context.Context, model string) error
+	WithTx(ctx context.Context, fn func(txStore StoreInterface) error) error
 
-	DocumentCount(options DocumentQueryInterface) (int64, error)
-	DocumentCreate(chat DocumentInterface) error
-	DocumentDelete(chat DocumentInterface) error
-	DocumentDeleteByID(id string) error
-	DocumentFindByID(id string) (DocumentInterface, error)
-	DocumentList(options DocumentQueryInterface) ([]DocumentInterface, error)
-	DocumentSoftDelete(chat DocumentInterface) error
-	DocumentSoftDeleteByID(id string) error
-	DocumentUpdate(chat DocumentInterface) error
+	DocumentChunksCreate(ctx context.Context, document DocumentInterface, chunker Chunker) ([]ChunkInterface, error)
+	DocumentCount(ctx context.Context, options DocumentQueryInterface) (int64, error)
+	DocumentCreate(ctx context.Context, chat DocumentInterface) error
+	DocumentCreateWithChunks(ctx context.Context, document DocumentInterface, chunks []ChunkInterface) error
+	DocumentDelete(ctx context.Context, chat DocumentInterface) error
+	DocumentDeleteByID(ctx context.Context, id string) error
+	DocumentFindByID(ctx context.Context, id string) (DocumentInterface, error)
+	DocumentIngest(ctx context.Context, document DocumentInterface, chunker Chunker, embedder Embedder) ([]ChunkInterface, error)
+	DocumentList(ctx context.Context, options DocumentQueryInterface) ([]DocumentInterface, error)
+	DocumentReindex(ctx context.Context, document DocumentInterface, chunker Chunker, embedder Embedder) ([]ChunkInterface, error)
+	DocumentReplaceChunks(ctx context.Context, documentID string, chunks []ChunkInterface) error
+	DocumentRestore(ctx context.Context, document DocumentInterface) error
+	DocumentRestoreByID(ctx context.Context, id string) error
+	DocumentSoftDelete(ctx context.Context, chat DocumentInterface) error
+	DocumentSoftDeleteByID(ctx context.Context, id string) error
+	DocumentUpdate(ctx context.Context, chat DocumentInterface) error
 
-	ChunkCount(options ChunkQueryInterface) (int64, error)
-	ChunkCreate(message ChunkInterface) error
-	ChunkDelete(message ChunkInterface) error
-	ChunkDeleteByID(id string) error
-	ChunkFindByID(id string) (ChunkInterface, error)
-	ChunkList(options ChunkQueryInterface) ([]ChunkInterface, error)
-	ChunkSoftDelete(message ChunkInterface) error
-	ChunkSoftDeleteByID(id string) error
-	ChunkUpdate(message ChunkInterface) error
+	ChunkCount(ctx context.Context, options ChunkQueryInterface) (int64, error)
+	ChunkCreate(ctx context.Context, message ChunkInterface) error
+	ChunkCreateMany(ctx context.Context, chunks []ChunkInterface) error
+	ChunkDelete(ctx context.Context, message ChunkInterface) error
+	ChunkDeleteByID(ctx context.Context, id string) error
+	ChunkDeleteMany(ctx context.Context, ids []string) error
+	ChunkEmbeddingsMigrate(ctx context.Context) (int64, error)
+	ChunkFindByID(ctx context.Context, id string) (ChunkInterface, error)
+	ChunkKeywordIndexRebuild(ctx context.Context) (int64, error)
+	ChunkList(ctx context.Context, options ChunkQueryInterface) ([]ChunkInterface, error)
+	ChunkRestore(ctx context.Context, chunk ChunkInterface) error
+	ChunkRestoreByID(ctx context.Context, id string) error
+	ChunkSearchHybrid(ctx context.Context, text string, queryEmbedding []float32, options ChunkHybridSearchOptions) ([]ChunkHybridSearchResult, error)
+	ChunkSearchKeyword(ctx context.Context, text string, options ChunkKeywordSearchOptions) ([]ChunkSearchResult, error)
+	ChunkSearchSimilar(ctx context.Context, queryEmbedding []float32, options ChunkSearchOptions) ([]ChunkSearchResult, error)
+	ChunkSoftDelete(ctx context.Context, message ChunkInterface) error
+	ChunkSoftDeleteByID(ctx context.Context, id string) error
+	ChunkUpdate(ctx context.Context, message ChunkInterface) error
+	ChunkUpdateMany(ctx context.Context, chunks []ChunkInterface) error
+	ChunkVectorDelete(ctx context.Context, chunkID string, space string) error
+	ChunkVectorFind(ctx context.Context, chunkID string, space string) ([]float32, error)
+	ChunkVectorSet(ctx context.Context, chunkID string, space string, embedding []float32) error
+	ChunkVectorsEmbed(ctx context.Context, space string, embedder Embedder, query ChunkQueryInterface) (int64, error)
 }
@@ -0,0 +1,207 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/dracory/base/database"
+	"github.com/dromara/carbon/v2"
+	"github.com/gouniverse/uid"
+)
+
+// leaseMinutes is how long a lease is held without being renewed, so the
+// lease of a crashed process expires
+const leaseMinutes = 10
+
+// leaseAcquire takes the lease of the name, unless another holder has it.
+// Returns the holder ID, which renews and releases the lease.
+//
+// The leases are written on the store database, outside any transaction,
+// so other processes see them right away.
+func (st *store) leaseAcquire(ctx context.Context, name string) (string, bool, error) {
+	if st.tableLease == "" {
+		return "", false, errors.New("lease table is not configured")
+	}
+
+	holder := uid.HumanUid()
+	now := carbon.Now(carbon.UTC).ToDateTimeString(carbon.UTC)
+	expiresAt := carbon.Now(carbon.UTC).AddMinutes(leaseMinutes).ToDateTimeString(carbon.UTC)
+
+	// take over an expired lease
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Update(st.tableLease).
+		Prepared(true).
+		Set(goqu.Record{
+			COLUMN_HOLDER:     holder,
+			COLUMN_EXPIRES_AT: expiresAt,
+		}).
+		Where(
+			goqu.C(COLUMN_ID).Eq(name),
+			goqu.C(COLUMN_EXPIRES_AT).Lt(now),
+		).
+		ToSQL()
+
+	if err != nil {
+		return "", false, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	result, err := database.Execute(database.Context(ctx, st.db), sqlStr, sqlParams...)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return "", false, err
+	} else if affected > 0 {
+		return holder, true, nil
+	}
+
+	sqlStr, sqlParams, err = goqu.Dialect(st.dbDriverName).
+		Insert(st.tableLease).
+		Prepared(true).
+		Rows(goqu.Record{
+			COLUMN_ID:         name,
+			COLUMN_HOLDER:     holder,
+			COLUMN_EXPIRES_AT: expiresAt,
+		}).
+		ToSQL()
+
+	if err != nil {
+		return "", false, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, insertErr := database.Execute(database.Context(ctx, st.db), sqlStr, sqlParams...)
+
+	if insertErr == nil {
+		return holder, true, nil
+	}
+
+	// the insert fails on the lease of another holder
+	held, err := st.leaseExists(ctx, name)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	if held {
+		return "", false, nil
+	}
+
+	return "", false, insertErr
+}
+
+// leaseRenew extends the lease of the holder, failing when it expired and
+// was taken over by another holder
+func (st *store) leaseRenew(ctx context.Context, name string, holder string) error {
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Update(st.tableLease).
+		Prepared(true).
+		Set(goqu.Record{
+			COLUMN_EXPIRES_AT: carbon.Now(carbon.UTC).AddMinutes(leaseMinutes).ToDateTimeString(carbon.UTC),
+		}).
+		Where(
+			goqu.C(COLUMN_ID).Eq(name),
+			goqu.C(COLUMN_HOLDER).Eq(holder),
+		).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	result, err := database.Execute(database.Context(ctx, st.db), sqlStr, sqlParams...)
+
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if affected < 1 {
+		return errors.New("lease " + name + " expired, and was taken over")
+	}
+
+	return nil
+}
+
+// leaseRelease drops the lease of the holder. It is released also when
+// the context is cancelled, so it does not block the next run until it
+// expires.
+func (st *store) leaseRelease(ctx context.Context, name string, holder string) error {
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Delete(st.tableLease).
+		Prepared(true).
+		Where(
+			goqu.C(COLUMN_ID).Eq(name),
+			goqu.C(COLUMN_HOLDER).Eq(holder),
+		).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = database.Execute(database.Context(context.WithoutCancel(ctx), st.db), sqlStr, sqlParams...)
+
+	return err
+}
+
+// leaseExists checks if the lease of the name is held, expired or not
+func (st *store) leaseExists(ctx context.Context, name string) (bool, error) {
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		From(st.tableLease).
+		Select(goqu.COUNT(goqu.Star()).As("count")).
+		Where(goqu.C(COLUMN_ID).Eq(name)).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return false, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(database.Context(ctx, st.db), sqlStr, sqlParams...)
+
+	if err != nil {
+		return false, err
+	}
+
+	if len(rows) < 1 {
+		return false, nil
+	}
+
+	count, err := strconv.ParseInt(rows[0]["count"], 10, 64)
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,98 @@
+package ragstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dromara/carbon/v2"
+)
+
+func TestStore_Lease(t *testing.T) {
+	db := initDB(":memory:")
+
+	// two processes sharing the database
+	stores := []*store{}
+
+	for range 2 {
+		storeLease, err := NewStore(NewStoreOptions{
+			DB:                     db,
+			TableDocumentName:      "document_table",
+			TableDocumentChunkName: "document_chunk_table",
+			TableLeaseName:         "lease_table",
+			AutomigrateEnabled:     true,
+		})
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		stores = append(stores, storeLease.(*store))
+	}
+
+	ctx := context.Background()
+
+	holder, acquired, err := stores[0].leaseAcquire(ctx, "job")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !acquired {
+		t.Fatal("Expected the lease to be acquired")
+	}
+
+	if _, acquired, err := stores[1].leaseAcquire(ctx, "job"); err != nil || acquired {
+		t.Fatal("Expected the lease held by the other store not to be acquired, got", acquired, err)
+	}
+
+	if err := stores[0].leaseRenew(ctx, "job", holder); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// an expired lease is taken over
+	_, err = db.Exec(`UPDATE "lease_table" SET "expires_at" = ?`, carbon.Now(carbon.UTC).SubHours(1).ToDateTimeString(carbon.UTC))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	other, acquired, err := stores[1].leaseAcquire(ctx, "job")
+
+	if err != nil || !acquired {
+		t.Fatal("Expected the expired lease to be taken over, got", acquired, err)
+	}
+
+	if err := stores[0].leaseRenew(ctx, "job", holder); err == nil {
+		t.Fatal("Expected an error renewing a lease taken over")
+	}
+
+	if err := stores[0].leaseRelease(ctx, "job", holder); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, acquired, _ := stores[0].leaseAcquire(ctx, "job"); acquired {
+		t.Fatal("Expected the release of a lease taken over to keep it held")
+	}
+
+	if err := stores[1].leaseRelease(ctx, "job", other); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	count := 0
+
+	if err := db.QueryRow(`SELECT COUNT(*) FROM "lease_table"`).Scan(&count); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("Expected the lease to be released, got", count, "rows")
+	}
+}
+
+func TestStore_ReembedAllRequiresLeaseTable(t *testing.T) {
+	storeReembed := initStoreWith(t, nil)
+
+	if _, err := storeReembed.ReembedAll(context.Background(), NewHashEmbedder(4), ReembedOptions{Model: "large"}); err == nil {
+		t.Fatal("Expected an error re-embedding without a lease table")
+	}
+}
@@ -1,6 +1,7 @@
 package ragstore
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"log/slog"
@@ -18,6 +19,100 @@ type NewStoreOptions struct {
 	AutomigrateEnabled     bool
 	DebugEnabled           bool
 	Logger                 *slog.Logger
+
+	// TableDocumentChunkTermName is the table of the inverted index used by
+	// keyword search, which is kept in sync by the chunk methods. Keyword
+	// search is disabled when empty.
+	TableDocumentChunkTermName string
+
+	// TableDocumentChunkVectorName is the table holding the embeddings of
+	// the named vector spaces, which let chunks be embedded by several
+	// models side by side. Named vector spaces are disabled when empty.
+	TableDocumentChunkVectorName string
+
+	// TableLeaseName is the table of the leases, which keep maintenance
+	// runs, like re-embedding, from running in several processes at once.
+	// Re-embedding is disabled when empty.
+	TableLeaseName string
+
+	// DistanceMetric is the default metric used by similarity search,
+	// one of the DISTANCE_METRIC_* constants (defaults to cosine)
+	DistanceMetric string
+
+	// NormalizeEmbeddings scales embeddings to unit length when chunks
+	// are created or updated
+	NormalizeEmbeddings bool
+
+	// EmbeddingFormat is the format embeddings are stored in, one of the
+	// EMBEDDING_FORMAT_* constants (defaults to JSON). Existing JSON rows
+	// can be converted with ChunkEmbeddingsMigrate.
+	EmbeddingFormat string
+
+	// EmbeddingModel is the name of the model the embeddings are made with.
+	// It is recorded on the chunks written with an embedding, chunks of
+	// another model are rejected, and similarity search compares the query
+	// embedding with the chunks of this model by default.
+	EmbeddingModel string
+
+	// EmbeddingDimension rejects chunk writes, whose embedding has another
+	// number of dimensions (not checked when 0)
+	EmbeddingDimension int
+
+	// EmbeddingRejectNonFinite rejects chunk writes, whose embedding
+	// contains NaN or infinite values
+	EmbeddingRejectNonFinite bool
+
+	// Quantization stores a compact copy of each embedding next to the
+	// original, one of the QUANTIZATION_* constants (defaults to none).
+	// The exact search scans the quantized copies first, and rescores only
+	// the best candidates with the original embeddings. Existing chunks are
+	// quantized by ChunkEmbeddingsMigrate.
+	Quantization string
+
+	// QuantizationRescoreFactor is the number of candidates per requested
+	// result, which are rescored with full precision (defaults to 4)
+	QuantizationRescoreFactor int
+
+	// EmbeddingBatchSize is the number of texts DocumentIngest passes to
+	// the embedder per call (defaults to 32)
+	EmbeddingBatchSize int
+
+	// ChunkBatchSize is the maximum number of chunks written per statement
+	// and transaction by the bulk chunk methods (defaults to 100). Batches
+	// are made smaller, when needed to stay within the bind parameter limit
+	// of the database.
+	ChunkBatchSize int
+
+	// CascadeDeleteEnabled makes deleting a document delete its chunks.
+	// A hard delete removes the chunks, a soft delete soft deletes them,
+	// and restoring the document restores the chunks soft deleted with it.
+	CascadeDeleteEnabled bool
+
+	// OwnerScopeRequired makes every document and chunk call fail, unless
+	// its context is scoped to an owner with WithOwnerScope. Maintenance
+	// methods, like PurgeSoftDeleted, work across all owners regardless.
+	OwnerScopeRequired bool
+
+	// HNSWEnabled builds an in-memory HNSW index from the chunk table,
+	// which is kept up to date by the chunk methods and used by
+	// similarity search for approximate nearest neighbour lookups
+	HNSWEnabled bool
+
+	// HNSWM is the number of neighbours per node (defaults to 16)
+	HNSWM int
+
+	// HNSWEfConstruction is the candidate list size used while building
+	// the index (defaults to 200)
+	HNSWEfConstruction int
+
+	// HNSWEfSearch is the candidate list size used while searching,
+	// higher values trade speed for recall (defaults to 64)
+	HNSWEfSearch int
+
+	// HNSWSnapshotPath is the file the HNSW index is loaded from, when it
+	// exists, instead of being rebuilt from the chunk table. Only the chunks
+	// changed since the snapshot was saved (see HNSWSnapshotSave) are replayed.
+	HNSWSnapshotPath string
 }
 
 // NewStore creates a new block store
@@ -38,6 +133,74 @@ func NewStore(opts NewStoreOptions) (StoreInterface, error) {
 		opts.DbDriverName = sb.DatabaseDriverName(opts.DB)
 	}
 
+	if opts.DistanceMetric == "" {
+		opts.DistanceMetric = DISTANCE_METRIC_COSINE
+	}
+
+	if err := validateDistanceMetric(opts.DistanceMetric); err != nil {
+		return nil, err
+	}
+
+	if opts.EmbeddingFormat == "" {
+		opts.EmbeddingFormat = EMBEDDING_FORMAT_JSON
+	}
+
+	if err := validateEmbeddingFormat(opts.EmbeddingFormat); err != nil {
+		return nil, err
+	}
+
+	if opts.EmbeddingDimension < 0 {
+		return nil, errors.New("rag store: EmbeddingDimension cannot be negative")
+	}
+
+	if opts.Quantization == "" {
+		opts.Quantization = QUANTIZATION_NONE
+	}
+
+	if err := validateQuantization(opts.Quantization); err != nil {
+		return nil, err
+	}
+
+	if opts.QuantizationRescoreFactor == 0 {
+		opts.QuantizationRescoreFactor = 4
+	}
+
+	if opts.QuantizationRescoreFactor < 1 {
+		return nil, errors.New("rag store: QuantizationRescoreFactor must be positive")
+	}
+
+	if opts.EmbeddingBatchSize == 0 {
+		opts.EmbeddingBatchSize = 32
+	}
+
+	if opts.EmbeddingBatchSize < 1 {
+		return nil, errors.New("rag store: EmbeddingBatchSize must be positive")
+	}
+
+	if opts.ChunkBatchSize == 0 {
+		opts.ChunkBatchSize = 100
+	}
+
+	if opts.ChunkBatchSize < 1 {
+		return nil, errors.New("rag store: ChunkBatchSize must be positive")
+	}
+
+	if opts.HNSWM == 0 {
+		opts.HNSWM = 16
+	}
+
+	if opts.HNSWEfConstruction == 0 {
+		opts.HNSWEfConstruction = 200
+	}
+
+	if opts.HNSWEfSearch == 0 {
+		opts.HNSWEfSearch = 64
+	}
+
+	if opts.HNSWM < 2 || opts.HNSWEfConstruction < 1 || opts.HNSWEfSearch < 1 {
+		return nil, errors.New("rag store: HNSW parameters must be positive, and M at least 2")
+	}
+
 	if opts.Logger == nil {
 		opts.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
 	}
@@ -50,15 +213,61 @@ func NewStore(opts NewStoreOptions) (StoreInterface, error) {
 		dbDriverName:       opts.DbDriverName,
 		debugEnabled:       opts.DebugEnabled,
 		logger:             opts.Logger,
+
+		tableDocumentChunkTerm:   opts.TableDocumentChunkTermName,
+		tableDocumentChunkVector: opts.TableDocumentChunkVectorName,
+		tableLease:               opts.TableLeaseName,
+
+		distanceMetric:      opts.DistanceMetric,
+		normalizeEmbeddings: opts.NormalizeEmbeddings,
+		embeddingFormat:     opts.EmbeddingFormat,
+
+		embeddingModel:           opts.EmbeddingModel,
+		embeddingDimension:       opts.EmbeddingDimension,
+		embeddingRejectNonFinite: opts.EmbeddingRejectNonFinite,
+
+		quantization:              opts.Quantization,
+		quantizationRescoreFactor: opts.QuantizationRescoreFactor,
+
+		embeddingBatchSize: opts.EmbeddingBatchSize,
+		chunkBatchSize:     opts.ChunkBatchSize,
+
+		cascadeDeleteEnabled: opts.CascadeDeleteEnabled,
+		ownerScopeRequired:   opts.OwnerScopeRequired,
 	}
 
 	if store.automigrateEnabled {
-		err := store.AutoMigrate()
+		err := store.AutoMigrate(context.Background())
 
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	if opts.HNSWEnabled {
+		store.hnsw = newHnswIndex(store.distanceMetric, opts.HNSWM, opts.HNSWEfConstruction, opts.HNSWEfSearch)
+		store.hnswSnapshotPath = opts.HNSWSnapshotPath
+		store.hnswContextTxs = &contextTxSet{txs: map[*sql.Tx]bool{}}
+
+		loaded := false
+
+		if store.hnswSnapshotPath != "" {
+			var err error
+
+			loaded, err = store.hnswSnapshotLoad(context.Background(), store.hnswSnapshotPath)
+
+			if err != nil {
+				store.logger.Warn("rag store: HNSW snapshot not loaded, rebuilding the index", "error", err)
+				store.hnsw = newHnswIndex(store.distanceMetric, opts.HNSWM, opts.HNSWEfConstruction, opts.HNSWEfSearch)
+			}
+		}
+
+		if !loaded {
+			if err := store.hnswBuild(context.Background()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return store, nil
 }
@@ -0,0 +1,192 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+
+	"github.com/dracory/base/database"
+	"github.com/samber/lo"
+)
+
+// ownerScope returns the owner the call is scoped to, or an empty string
+// for an unscoped call. Unscoped calls fail, when the store requires an
+// owner scope.
+func (st *store) ownerScope(ctx context.Context) (string, error) {
+	scope, ok := ctx.Value(ownerScopeKey{}).(ownerScope)
+
+	if ok && scope.all {
+		return "", nil
+	}
+
+	if ok && scope.ownerID != "" {
+		return scope.ownerID, nil
+	}
+
+	if st.ownerScopeRequired {
+		return "", errors.New("owner scope is required")
+	}
+
+	return "", nil
+}
+
+// ownerScopeApply returns the owner a record is written with. Within an
+// owner scope, a record without owner gets the owner of the scope, and
+// a record of another owner is refused.
+func (st *store) ownerScopeApply(ctx context.Context, ownerID string) (string, error) {
+	scope, err := st.ownerScope(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	if scope == "" || ownerID == scope {
+		return ownerID, nil
+	}
+
+	if ownerID == "" {
+		return scope, nil
+	}
+
+	return "", errors.New("owner does not match the owner scope")
+}
+
+// documentQueryScoped returns the document query restricted to the owner
+// scope. The passed query is copied, not changed.
+func (st *store) documentQueryScoped(ctx context.Context, query DocumentQueryInterface) (DocumentQueryInterface, error) {
+	scope, err := st.ownerScope(ctx)
+
+	if err != nil || scope == "" {
+		return query, err
+	}
+
+	if query.IsOwnerIDSet() && query.GetOwnerID() != scope {
+		return nil, errors.New("query owner does not match the owner scope")
+	}
+
+	scoped := &documentQuery{
+		params: map[string]interface{}{},
+	}
+
+	if q, ok := query.(*documentQuery); ok && q != nil {
+		scoped.params = maps.Clone(q.params)
+	}
+
+	return scoped.SetOwnerID(scope), nil
+}
+
+// chunkQueryScoped returns the chunk query restricted to the owner scope.
+// The passed query is copied, not changed. A nil query stays nil, unless
+// the call is scoped.
+func (st *store) chunkQueryScoped(ctx context.Context, query ChunkQueryInterface) (ChunkQueryInterface, error) {
+	scope, err := st.ownerScope(ctx)
+
+	if err != nil || scope == "" {
+		return query, err
+	}
+
+	if query != nil && query.IsOwnerIDSet() && query.GetOwnerID() != scope {
+		return nil, errors.New("query owner does not match the owner scope")
+	}
+
+	return chunkQueryCopy(query).SetOwnerID(scope), nil
+}
+
+// documentInScope reports whether the document, soft deleted or not, is
+// in the owner scope. Every document is in scope of an unscoped call.
+func (st *store) documentInScope(ctx context.Context, id string) (bool, error) {
+	scope, err := st.ownerScope(ctx)
+
+	if err != nil || scope == "" {
+		return err == nil, err
+	}
+
+	count, err := st.DocumentCount(ctx, DocumentQuery().
+		SetID(id).
+		SetWithSoftDeleted(true))
+
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// documentIDsInScope returns the IDs of the documents, soft deleted or not,
+// which are in the owner scope. Every ID is in scope of an unscoped call.
+func (st *store) documentIDsInScope(ctx context.Context, ids []string) ([]string, error) {
+	scope, err := st.ownerScope(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if scope == "" || len(ids) < 1 {
+		return ids, nil
+	}
+
+	inScope := []string{}
+
+	for _, batch := range lo.Chunk(lo.Uniq(ids), st.chunkBulkBatchSize(1)) {
+		query, err := st.documentQueryScoped(ctx, DocumentQuery().
+			SetIDIn(batch).
+			SetWithSoftDeleted(true))
+
+		if err != nil {
+			return nil, err
+		}
+
+		q, _, err := query.ToSelectDataset(st)
+
+		if err != nil {
+			return nil, err
+		}
+
+		sqlStr, sqlParams, err := q.
+			ClearLimit().
+			ClearOffset().
+			ClearOrder().
+			Select(COLUMN_ID).
+			Prepared(true).
+			ToSQL()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if st.debugEnabled {
+			log.Println(sqlStr)
+		}
+
+		rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, row := range rows {
+			inScope = append(inScope, row[COLUMN_ID])
+		}
+	}
+
+	return inScope, nil
+}
+
+// chunkIDsInScope returns the IDs of the chunks, soft deleted or not,
+// which are in the owner scope
+func (st *store) chunkIDsInScope(ctx context.Context, ids []string) ([]string, error) {
+	scope, err := st.ownerScope(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if scope == "" || len(ids) < 1 {
+		return ids, nil
+	}
+
+	return st.chunkSearchIDs(ctx, ChunkQuery().
+		SetIDIn(ids).
+		SetWithSoftDeleted(true))
+}
@@ -0,0 +1,328 @@
+package ragstore
+
+import (
+	"context"
+	"testing"
+)
+
+func initOwnerDocument(t *testing.T, store StoreInterface, ctx context.Context) (DocumentInterface, []ChunkInterface) {
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("text")
+
+	chunks := []ChunkInterface{
+		NewChunk().SetChunkIndex(0).SetContent("owner invoice").SetEmbedding([]float32{1.0, 0.0}),
+		NewChunk().SetChunkIndex(1).SetContent("owner receipt").SetEmbedding([]float32{0.0, 1.0}),
+	}
+
+	if err := store.DocumentCreateWithChunks(ctx, document, chunks); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return document, chunks
+}
+
+func TestStore_OwnerScopeCreate(t *testing.T) {
+	storeOwner := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.HNSWEnabled = true
+	})
+
+	ctxA := WithOwnerScope(context.Background(), "owner_a")
+
+	document, chunks := initOwnerDocument(t, storeOwner, ctxA)
+
+	if document.OwnerID() != "owner_a" {
+		t.Fatal("Expected document owner owner_a, got", document.OwnerID())
+	}
+
+	for _, chunk := range chunks {
+		if chunk.OwnerID() != "owner_a" {
+			t.Fatal("Expected chunk owner owner_a, got", chunk.OwnerID())
+		}
+	}
+
+	found, err := storeOwner.ChunkFindByID(ctxA, chunks[0].ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found == nil || found.OwnerID() != "owner_a" {
+		t.Fatal("Expected the chunk to be stored with owner owner_a")
+	}
+
+	// a document of another owner cannot be created within the scope
+	other := NewDocument().
+		SetOwnerID("owner_b").
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("other.txt")
+
+	if err := storeOwner.DocumentCreate(ctxA, other); err == nil {
+		t.Fatal("Expected an error for a document of another owner")
+	}
+}
+
+func TestStore_OwnerScopeQueries(t *testing.T) {
+	storeOwner := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.HNSWEnabled = true
+	})
+
+	ctxA := WithOwnerScope(context.Background(), "owner_a")
+	ctxB := WithOwnerScope(context.Background(), "owner_b")
+
+	documentA, _ := initOwnerDocument(t, storeOwner, ctxA)
+	documentB, chunksB := initOwnerDocument(t, storeOwner, ctxB)
+
+	documents, err := storeOwner.DocumentList(ctxA, DocumentQuery())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(documents) != 1 || documents[0].ID() != documentA.ID() {
+		t.Fatal("Expected only the document of owner_a, got", len(documents))
+	}
+
+	// the owner filter also works without a scope
+	documents, err = storeOwner.DocumentList(context.Background(), DocumentQuery().SetOwnerID("owner_b"))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(documents) != 1 || documents[0].ID() != documentB.ID() {
+		t.Fatal("Expected only the document of owner_b, got", len(documents))
+	}
+
+	count, err := storeOwner.ChunkCount(ctxA, ChunkQuery())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 2 {
+		t.Fatal("Expected 2 chunks of owner_a, got", count)
+	}
+
+	found, err := storeOwner.DocumentFindByID(ctxA, documentB.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found != nil {
+		t.Fatal("Expected the document of owner_b not to be found")
+	}
+
+	if _, err := storeOwner.DocumentList(ctxA, DocumentQuery().SetOwnerID("owner_b")); err == nil {
+		t.Fatal("Expected an error for a query of another owner")
+	}
+
+	if _, err := storeOwner.ChunkList(ctxA, ChunkQuery().SetOwnerID("owner_b")); err == nil {
+		t.Fatal("Expected an error for a query of another owner")
+	}
+
+	results, err := storeOwner.ChunkSearchSimilar(ctxA, []float32{1.0, 0.0}, ChunkSearchOptions{Limit: 10})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatal("Expected 2 similar chunks of owner_a, got", len(results))
+	}
+
+	for _, result := range results {
+		if result.Chunk.DocumentID() != documentA.ID() {
+			t.Fatal("Expected only chunks of owner_a, got chunk of", result.Chunk.DocumentID())
+		}
+	}
+
+	keywordResults, err := storeOwner.ChunkSearchKeyword(ctxB, "invoice", ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(keywordResults) != 1 || keywordResults[0].Chunk.ID() != chunksB[0].ID() {
+		t.Fatal("Expected only the invoice chunk of owner_b")
+	}
+}
+
+func TestStore_OwnerScopeWrites(t *testing.T) {
+	storeOwner := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.HNSWEnabled = true
+	})
+
+	ctxA := WithOwnerScope(context.Background(), "owner_a")
+	ctxB := WithOwnerScope(context.Background(), "owner_b")
+
+	documentB, chunksB := initOwnerDocument(t, storeOwner, ctxB)
+
+	// deleting the records of another owner is a no-op
+	if err := storeOwner.DocumentDeleteByID(ctxA, documentB.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeOwner.ChunkDeleteByID(ctxA, chunksB[0].ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeOwner.ChunkDeleteMany(ctxA, []string{chunksB[1].ID()}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	found, err := storeOwner.DocumentFindByID(ctxB, documentB.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found == nil {
+		t.Fatal("Expected the document of owner_b to be kept")
+	}
+
+	count, err := storeOwner.ChunkCount(ctxB, ChunkQuery())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 2 {
+		t.Fatal("Expected the 2 chunks of owner_b to be kept, got", count)
+	}
+
+	// and they are kept in the search indexes
+	results, err := storeOwner.ChunkSearchKeyword(ctxB, "invoice", ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 {
+		t.Fatal("Expected the invoice chunk of owner_b to be found, got", len(results))
+	}
+
+	// updating them fails
+	chunksB[0].SetContent("changed")
+
+	if err := storeOwner.ChunkUpdate(ctxA, chunksB[0]); err == nil {
+		t.Fatal("Expected an error updating a chunk of another owner")
+	}
+
+	documentB.SetText("changed")
+
+	if err := storeOwner.DocumentUpdate(ctxA, documentB); err == nil {
+		t.Fatal("Expected an error updating a document of another owner")
+	}
+
+	// chunks cannot be attached to the document of another owner
+	attached := NewChunk().SetDocumentID(documentB.ID()).SetChunkIndex(2).SetContent("attached")
+
+	if err := storeOwner.ChunkCreate(ctxA, attached); err == nil {
+		t.Fatal("Expected an error creating a chunk for a document of another owner")
+	}
+
+	attachedMany := []ChunkInterface{
+		NewChunk().SetDocumentID(documentB.ID()).SetChunkIndex(3).SetContent("attached"),
+	}
+
+	if err := storeOwner.ChunkCreateMany(ctxA, attachedMany); err == nil {
+		t.Fatal("Expected an error creating chunks for a document of another owner")
+	}
+
+	count, err = storeOwner.ChunkCount(ctxB, ChunkQuery())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 2 {
+		t.Fatal("Expected no chunks attached to the document of owner_b, got", count)
+	}
+}
+
+func TestStore_OwnerScopeRequired(t *testing.T) {
+	storeOwner := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+		opts.HNSWEnabled = true
+		opts.OwnerScopeRequired = true
+	})
+
+	ctx := context.Background()
+
+	if _, err := storeOwner.DocumentList(ctx, DocumentQuery()); err == nil {
+		t.Fatal("Expected an error listing documents without owner scope")
+	}
+
+	if _, err := storeOwner.ChunkSearchSimilar(ctx, []float32{1.0, 0.0}, ChunkSearchOptions{}); err == nil {
+		t.Fatal("Expected an error searching chunks without owner scope")
+	}
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt")
+
+	if err := storeOwner.DocumentCreate(ctx, document); err == nil {
+		t.Fatal("Expected an error creating a document without owner scope")
+	}
+
+	initOwnerDocument(t, storeOwner, WithOwnerScope(ctx, "owner_a"))
+
+	// maintenance works across all owners
+	if _, err := storeOwner.PurgeSoftDeleted(ctx, 0); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestStore_OwnerScopeKeywordStats(t *testing.T) {
+	storeOwner := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.TableDocumentChunkTermName = "document_chunk_term_table"
+	})
+
+	ctxA := WithOwnerScope(context.Background(), "owner_a")
+	ctxB := WithOwnerScope(context.Background(), "owner_b")
+
+	initOwnerDocument(t, storeOwner, ctxA)
+
+	before, err := storeOwner.ChunkSearchKeyword(ctxA, "invoice", ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the chunks of another owner, and soft deleted chunks, do not count
+	chunksB := []ChunkInterface{
+		NewChunk().SetChunkIndex(0).SetContent("receipt one two three"),
+		NewChunk().SetChunkIndex(1).SetContent("receipt four five six"),
+		NewChunk().SetChunkIndex(2).SetContent("receipt seven eight nine"),
+	}
+
+	if err := storeOwner.DocumentCreateWithChunks(ctxB, NewDocument().SetStatus(DOCUMENT_STATUS_ACTIVE).SetFileName("b.txt").SetText("text"), chunksB); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	deleted := NewChunk().SetDocumentID(before[0].Chunk.DocumentID()).SetChunkIndex(2).SetContent("owner receipt receipt")
+
+	if err := storeOwner.ChunkCreate(ctxA, deleted); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeOwner.ChunkSoftDeleteByID(ctxA, deleted.ID()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	after, err := storeOwner.ChunkSearchKeyword(ctxA, "invoice", ChunkKeywordSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(before) != 1 || len(after) != 1 || before[0].Score != after[0].Score {
+		t.Fatal("Expected the score not to change with chunks out of scope, got", before, after)
+	}
+}
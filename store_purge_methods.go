@@ -0,0 +1,183 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/dracory/base/database"
+	"github.com/dromara/carbon/v2"
+	"github.com/samber/lo"
+)
+
+// PurgeResult holds the number of records removed by PurgeSoftDeleted
+type PurgeResult struct {
+	// Documents is the number of purged soft deleted documents
+	Documents int64
+
+	// Chunks is the number of purged soft deleted chunks, along with the
+	// chunks of the purged documents
+	Chunks int64
+
+	// OrphanChunks is the number of purged chunks, whose document does
+	// not exist
+	OrphanChunks int64
+}
+
+// PurgeSoftDeleted permanently deletes the documents and chunks soft deleted
+// longer than olderThan ago, along with the chunks of the purged documents
+// and the chunks, whose document does not exist. The records are deleted in
+// batches of the configured chunk batch size, each in its own transaction,
+// so the purge can be interrupted and run again.
+//
+// The purge works across all owners, also within an owner scope.
+//
+// Returns the number of purged records, also when an error stopped the purge.
+func (st *store) PurgeSoftDeleted(ctx context.Context, olderThan time.Duration) (PurgeResult, error) {
+	result := PurgeResult{}
+
+	if st.db == nil {
+		return result, errors.New("database is not initialized")
+	}
+
+	if olderThan < 0 {
+		return result, errors.New("purge age cannot be negative")
+	}
+
+	ctx = withOwnerScopeAll(ctx)
+
+	cutoff := carbon.CreateFromStdTime(time.Now().Add(-olderThan)).ToDateTimeString(carbon.UTC)
+	batchSize := st.chunkBulkBatchSize(1)
+
+	for {
+		documentIDs, err := st.purgeIDs(ctx, st.tableDocument, goqu.C(COLUMN_SOFT_DELETED_AT).Lt(cutoff), batchSize)
+
+		if err != nil {
+			return result, err
+		}
+
+		if len(documentIDs) < 1 {
+			break
+		}
+
+		err = st.withTx(ctx, func(txStore *store) error {
+			for _, documentID := range documentIDs {
+				chunks, err := txStore.ChunkCount(ctx, ChunkQuery().
+					SetDocumentID(documentID).
+					SetWithSoftDeleted(true))
+
+				if err != nil {
+					return err
+				}
+
+				if err := txStore.documentChunksDelete(ctx, documentID); err != nil {
+					return err
+				}
+
+				result.Chunks += chunks
+			}
+
+			return txStore.purgeDocuments(ctx, documentIDs)
+		})
+
+		if err != nil {
+			return result, err
+		}
+
+		result.Documents += int64(len(documentIDs))
+	}
+
+	purged, err := st.purgeChunks(ctx, goqu.C(COLUMN_SOFT_DELETED_AT).Lt(cutoff), batchSize)
+	result.Chunks += purged
+
+	if err != nil {
+		return result, err
+	}
+
+	documentIDs := goqu.Dialect(st.dbDriverName).
+		From(st.tableDocument).
+		Select(COLUMN_ID)
+
+	result.OrphanChunks, err = st.purgeChunks(ctx, goqu.C(COLUMN_DOCUMENT_ID).NotIn(documentIDs), batchSize)
+
+	return result, err
+}
+
+// purgeChunks deletes the chunks matching the condition in batches, and
+// returns the number of deleted chunks
+func (st *store) purgeChunks(ctx context.Context, condition exp.Expression, batchSize int) (int64, error) {
+	purged := int64(0)
+
+	for {
+		chunkIDs, err := st.purgeIDs(ctx, st.tableDocumentChunk, condition, batchSize)
+
+		if err != nil {
+			return purged, err
+		}
+
+		if len(chunkIDs) < 1 {
+			return purged, nil
+		}
+
+		if err := st.ChunkDeleteMany(ctx, chunkIDs); err != nil {
+			return purged, err
+		}
+
+		purged += int64(len(chunkIDs))
+	}
+}
+
+// purgeIDs returns the IDs of up to limit rows of the table matching
+// the condition
+func (st *store) purgeIDs(ctx context.Context, table string, condition exp.Expression, limit int) ([]string, error) {
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		From(table).
+		Select(COLUMN_ID).
+		Where(condition).
+		Order(goqu.C(COLUMN_ID).Asc()).
+		Limit(uint(limit)).
+		Prepared(true).
+		ToSQL()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	rows, err := database.SelectToMapString(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lo.Map(rows, func(row map[string]string, _ int) string {
+		return row[COLUMN_ID]
+	}), nil
+}
+
+// purgeDocuments deletes the document rows with the IDs
+func (st *store) purgeDocuments(ctx context.Context, documentIDs []string) error {
+	sqlStr, sqlParams, err := goqu.Dialect(st.dbDriverName).
+		Delete(st.tableDocument).
+		Prepared(true).
+		Where(goqu.C(COLUMN_ID).In(documentIDs)).
+		ToSQL()
+
+	if err != nil {
+		return err
+	}
+
+	if st.debugEnabled {
+		log.Println(sqlStr)
+	}
+
+	_, err = database.Execute(st.toQueryableContext(ctx), sqlStr, sqlParams...)
+
+	return err
+}
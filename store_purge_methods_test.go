@@ -0,0 +1,131 @@
+package ragstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestStore_PurgeSoftDeleted(t *testing.T) {
+	storePurge, err := NewStore(NewStoreOptions{
+		DB:                     initDB(":memory:"),
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+		ChunkBatchSize:         2,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	newChunks := func(count int) []ChunkInterface {
+		chunks := []ChunkInterface{}
+
+		for i := 0; i < count; i++ {
+			chunks = append(chunks, NewChunk().
+				SetChunkIndex(i).
+				SetContent("chunk"))
+		}
+
+		return chunks
+	}
+
+	documentExpired := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("expired.txt").
+		SetText("expired")
+
+	if err := storePurge.DocumentCreateWithChunks(context.Background(), documentExpired, newChunks(3)); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	documentExpired.SetSoftDeletedAt("2020-01-01 00:00:00")
+
+	if err := storePurge.DocumentUpdate(context.Background(), documentExpired); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	documentActive := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("active.txt").
+		SetText("active")
+
+	chunksActive := newChunks(4)
+
+	if err := storePurge.DocumentCreateWithChunks(context.Background(), documentActive, chunksActive); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// soft deleted long ago, and just now
+	chunksActive[0].SetSoftDeletedAt("2020-01-01 00:00:00")
+
+	if err := storePurge.ChunkUpdate(context.Background(), chunksActive[0]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storePurge.ChunkSoftDelete(context.Background(), chunksActive[1]); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	orphan := NewChunk().
+		SetDocumentID("missing").
+		SetChunkIndex(0).
+		SetContent("orphan")
+
+	if err := storePurge.ChunkCreate(context.Background(), orphan); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	result, err := storePurge.PurgeSoftDeleted(context.Background(), 24*time.Hour)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if result.Documents != 1 || result.Chunks != 4 || result.OrphanChunks != 1 {
+		t.Fatalf("Expected 1 document, 4 chunks and 1 orphan chunk purged, got %+v", result)
+	}
+
+	list, err := storePurge.ChunkList(context.Background(), ChunkQuery().SetWithSoftDeleted(true))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 3 {
+		t.Fatalf("Expected 3 chunks left, got %d", len(list))
+	}
+
+	for _, chunk := range list {
+		if chunk.DocumentID() != documentActive.ID() || chunk.ID() == chunksActive[0].ID() {
+			t.Fatal("Expected only the recent chunks of the active document to be left")
+		}
+	}
+
+	documents, err := storePurge.DocumentCount(context.Background(), DocumentQuery().SetWithSoftDeleted(true))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if documents != 1 {
+		t.Fatalf("Expected 1 document left, got %d", documents)
+	}
+
+	result, err = storePurge.PurgeSoftDeleted(context.Background(), 24*time.Hour)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if result != (PurgeResult{}) {
+		t.Fatalf("Expected nothing left to purge, got %+v", result)
+	}
+
+	if _, err := storePurge.PurgeSoftDeleted(context.Background(), -time.Hour); err == nil {
+		t.Fatal("expected error for negative age, but got nil")
+	}
+}
@@ -1,10 +1,18 @@
 package ragstore
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"io"
+	"log/slog"
 	"os"
+	"slices"
+	"strings"
+	"testing"
 
+	"github.com/doug-martin/goqu/v9"
+	"github.com/gouniverse/sb"
 	"github.com/gouniverse/utils"
 )
 
@@ -53,3 +61,141 @@ func initStore(filepath string) (StoreInterface, error) {
 
 	return store, nil
 }
+
+// initStoreWith creates an in-memory store, with the options changed by
+// the configure function
+func initStoreWith(t *testing.T, configure func(opts *NewStoreOptions)) StoreInterface {
+	opts := NewStoreOptions{
+		DB:                     initDB(":memory:"),
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+	}
+
+	if configure != nil {
+		configure(&opts)
+	}
+
+	store, err := NewStore(opts)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	return store
+}
+
+func testDiscardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestStore_AutoMigrateAddsColumns(t *testing.T) {
+	db := initDB(":memory:")
+
+	// A chunk table created before the added columns existed
+	_, err := db.Exec(`CREATE TABLE "document_chunk_table" ("id" TEXT(40) PRIMARY KEY NOT NULL, "document_id" TEXT(40) NOT NULL, "chunk_index" INTEGER NOT NULL, "content" TEXT NOT NULL, "embedding" TEXT NOT NULL, "created_at" DATETIME NOT NULL, "updated_at" DATETIME NOT NULL, "soft_deleted_at" DATETIME NOT NULL)`)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// A document table created before the added columns existed
+	_, err = db.Exec(`CREATE TABLE "document_table" ("id" TEXT(40) PRIMARY KEY NOT NULL, "status" TEXT(40) NOT NULL, "file_name" TEXT(255) NOT NULL, "text" TEXT NOT NULL, "metas" TEXT NOT NULL, "memo" TEXT NOT NULL, "created_at" DATETIME NOT NULL, "updated_at" DATETIME NOT NULL, "soft_deleted_at" DATETIME NOT NULL)`)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	_, err = db.Exec(`INSERT INTO "document_chunk_table" VALUES ('chunk1', 'document1', 1, 'content', '[1,0]', '2020-01-01 00:00:00', '2020-01-01 00:00:00', '9999-12-31 23:59:59'), ('chunk2', 'document1', 2, 'content', '[]', '2020-01-01 00:00:00', '2020-01-01 00:00:00', '9999-12-31 23:59:59')`)
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	storeMigrated, err := NewStore(NewStoreOptions{
+		DB:                     db,
+		TableDocumentName:      "document_table",
+		TableDocumentChunkName: "document_chunk_table",
+		AutomigrateEnabled:     true,
+	})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	columns, err := storeMigrated.(*store).tableColumns(context.Background(), "document_chunk_table")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, column := range storeMigrated.(*store).sqlDocumentChunkTableColumnsAdded() {
+		if !slices.Contains(columns, column.Name) {
+			t.Fatalf("Expected column %s to be added", column.Name)
+		}
+	}
+
+	columns, err = storeMigrated.(*store).tableColumns(context.Background(), "document_table")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	for _, column := range storeMigrated.(*store).sqlDocumentTableColumnsAdded() {
+		if !slices.Contains(columns, column.Name) {
+			t.Fatalf("Expected column %s to be added", column.Name)
+		}
+	}
+
+	// The status of the existing chunks is set from their embedding
+	for id, status := range map[string]string{"chunk1": CHUNK_STATUS_EMBEDDED, "chunk2": CHUNK_STATUS_PENDING} {
+		chunk, err := storeMigrated.ChunkFindByID(context.Background(), id)
+
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if chunk == nil || chunk.Status() != status {
+			t.Fatalf("Expected chunk %s to be %s", id, status)
+		}
+
+		// and their content hash from their content
+		if chunk.ContentHash() != contentHash("content") {
+			t.Fatalf("Expected chunk %s to have the content hash of its content", id)
+		}
+	}
+
+	// Migrating again is a no-op
+	if err := storeMigrated.AutoMigrate(context.Background()); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestStore_SqlMetaValue(t *testing.T) {
+	cases := map[string]string{
+		sb.DIALECT_SQLITE:   `json_extract(?, ?)`,
+		sb.DIALECT_POSTGRES: `CAST(? AS JSONB) ->> ?`,
+		sb.DIALECT_MYSQL:    `JSON_UNQUOTE(JSON_EXTRACT(?, ?))`,
+		sb.DIALECT_MSSQL:    `JSON_VALUE(?, ?)`,
+	}
+
+	for dialect, expected := range cases {
+		storeDialect := &store{dbDriverName: dialect}
+
+		if literal := storeDialect.sqlMetaValue("source").Literal(); literal != expected {
+			t.Fatalf("Expected %s for %s, got %s", expected, dialect, literal)
+		}
+	}
+
+	storeMssql := &store{dbDriverName: sb.DIALECT_MSSQL}
+
+	sqlStr, _, err := goqu.From("document_table").Select(storeMssql.sqlMetaValue("source")).ToSQL()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if !strings.Contains(sqlStr, `NULLIF(CAST("metas" AS NVARCHAR(MAX)), '')`) {
+		t.Fatal("Expected the MSSQL metas to be cast from TEXT, got", sqlStr)
+	}
+}
@@ -0,0 +1,148 @@
+package ragstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+
+	"github.com/dracory/base/database"
+)
+
+// WithTx runs the function in a database transaction, passing a store,
+// which runs all its queries in the transaction. The transaction is
+// committed when the function returns nil, and rolled back otherwise.
+//
+// Changes to the HNSW index are applied only once the transaction is
+// committed. Within a transaction carried by the context, the index is
+// rebuilt once the caller commits or rolls it back. Calling WithTx on the transaction store joins the
+// running transaction.
+func (st *store) WithTx(ctx context.Context, fn func(txStore StoreInterface) error) error {
+	if st.db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	if fn == nil {
+		return errors.New("transaction function is nil")
+	}
+
+	return st.withTx(ctx, func(txStore *store) error {
+		return fn(txStore)
+	})
+}
+
+// withTx runs the function in a transaction, joining the running one
+// when the store is a transaction store. A transaction carried by the
+// context is joined as well, but as it is committed by the caller, the
+// HNSW index is rebuilt once it is done, instead of applying the changes.
+func (st *store) withTx(ctx context.Context, fn func(txStore *store) error) error {
+	if st.tx != nil {
+		return fn(st)
+	}
+
+	if tx, isTx := contextTx(ctx); isTx {
+		txStore := *st
+		txStore.tx = tx
+		txStore.hnswPending = &[]func() error{}
+
+		err := fn(&txStore)
+
+		// the caller may commit the changes made before an error as well
+		if len(*txStore.hnswPending) > 0 && st.hnswContextTxs != nil {
+			st.hnswContextTxs.mu.Lock()
+			st.hnswContextTxs.txs[tx] = true
+			st.hnswContextTxs.mu.Unlock()
+		}
+
+		return err
+	}
+
+	tx, err := st.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	txStore := *st
+	txStore.tx = tx
+	txStore.hnswPending = &[]func() error{}
+
+	committed := false
+
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	if err := fn(&txStore); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	committed = true
+
+	for _, change := range *txStore.hnswPending {
+		if err := change(); err != nil {
+			st.GetLogger().Warn("rag store: HNSW index not updated after commit", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// contextTx returns the transaction carried by the context, if any
+func contextTx(ctx context.Context) (*sql.Tx, bool) {
+	queryableContext, isQueryable := ctx.(database.QueryableContext)
+
+	if !isQueryable {
+		return nil, false
+	}
+
+	tx, isTx := queryableContext.Queryable().(*sql.Tx)
+
+	return tx, isTx
+}
+
+// contextTxSet holds the transactions carried by contexts, which changed
+// the HNSW index. As the caller commits or rolls them back, the changes are
+// not applied, but the index is rebuilt once they are done.
+type contextTxSet struct {
+	mu  sync.Mutex
+	txs map[*sql.Tx]bool
+}
+
+// contextTxDone checks if the transaction was committed or rolled back
+func contextTxDone(ctx context.Context, tx *sql.Tx) bool {
+	err := tx.QueryRowContext(ctx, "SELECT 1").Scan(new(int))
+
+	return errors.Is(err, sql.ErrTxDone)
+}
+
+// queryableForeign is queried instead of a database or transaction, which
+// would escape the store transaction, failing every query
+var queryableForeign = sql.OpenDB(failingConnector{
+	err: errors.New("context carries another database or transaction than the store transaction"),
+})
+
+// failingConnector fails to connect with the error, so every query on
+// its database fails with it
+type failingConnector struct {
+	err error
+}
+
+func (c failingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+func (c failingConnector) Driver() driver.Driver {
+	return c
+}
+
+func (c failingConnector) Open(name string) (driver.Conn, error) {
+	return nil, c.err
+}
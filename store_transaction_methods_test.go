@@ -0,0 +1,333 @@
+package ragstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/dracory/base/database"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestStore_WithTxRollback(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("text")
+
+	errRollback := errors.New("rollback")
+
+	err = store.WithTx(context.Background(), func(txStore StoreInterface) error {
+		if err := txStore.DocumentCreate(context.Background(), document); err != nil {
+			return err
+		}
+
+		found, err := txStore.DocumentFindByID(context.Background(), document.ID())
+
+		if err != nil {
+			return err
+		}
+
+		if found == nil {
+			t.Fatal("Expected the document to be found in the transaction")
+		}
+
+		return errRollback
+	})
+
+	if !errors.Is(err, errRollback) {
+		t.Fatal("Expected the function error, got:", err)
+	}
+
+	found, err := store.DocumentFindByID(context.Background(), document.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found != nil {
+		t.Fatal("Document MUST NOT be created when the transaction is rolled back")
+	}
+}
+
+func TestStore_WithTxQueryableContext(t *testing.T) {
+	storeTx, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("text")
+
+	// a context carrying the store database is queried in the transaction
+	dbCtx := database.Context(context.Background(), storeTx.(*store).db)
+
+	errRollback := errors.New("rollback")
+
+	err = storeTx.WithTx(context.Background(), func(txStore StoreInterface) error {
+		if err := txStore.DocumentCreate(dbCtx, document); err != nil {
+			return err
+		}
+
+		return errRollback
+	})
+
+	if !errors.Is(err, errRollback) {
+		t.Fatal("Expected the function error, got:", err)
+	}
+
+	found, err := storeTx.DocumentFindByID(context.Background(), document.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found != nil {
+		t.Fatal("Document MUST NOT be created when the transaction is rolled back")
+	}
+
+	// a context carrying another transaction cannot be queried
+	otherTx, err := initDB(":memory:").Begin()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	defer otherTx.Rollback()
+
+	err = storeTx.WithTx(context.Background(), func(txStore StoreInterface) error {
+		_, err := txStore.DocumentList(database.Context(context.Background(), otherTx), DocumentQuery())
+		return err
+	})
+
+	if err == nil {
+		t.Fatal("Expected an error querying another transaction")
+	}
+
+	// a single row query fails the same way
+	err = storeTx.WithTx(context.Background(), func(txStore StoreInterface) error {
+		queryable := txStore.(*store).toQueryableContext(database.Context(context.Background(), otherTx))
+		return queryable.Queryable().QueryRowContext(queryable, "SELECT 1").Scan(new(int))
+	})
+
+	if err == nil {
+		t.Fatal("Expected an error querying a row of another transaction")
+	}
+}
+
+func TestStore_ChunkCreateContextTx(t *testing.T) {
+	storeHnsw := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+	})
+
+	tx, err := storeHnsw.(*store).db.Begin()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunk := NewChunk().
+		SetDocumentID(testDocument_O1).
+		SetChunkIndex(0).
+		SetContent("chunk").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	// the transaction of the caller is joined
+	if err := storeHnsw.ChunkCreate(database.Context(context.Background(), tx), chunk); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	count, err := storeHnsw.ChunkCount(context.Background(), ChunkQuery().SetID(chunk.ID()))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatal("Chunk MUST NOT be created when the transaction of the caller is rolled back")
+	}
+
+	// the index is rebuilt without the chunk, once searched
+	if _, err := storeHnsw.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if storeHnsw.(*store).hnsw.Has(chunk.ID()) {
+		t.Fatal("Chunk MUST NOT be indexed when the transaction of the caller is rolled back")
+	}
+
+	tx, err = storeHnsw.(*store).db.Begin()
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if err := storeHnsw.ChunkCreate(database.Context(context.Background(), tx), chunk); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if storeHnsw.(*store).hnsw.Has(chunk.ID()) {
+		t.Fatal("Chunk MUST NOT be indexed before the transaction of the caller is committed")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	results, err := storeHnsw.ChunkSearchSimilar(context.Background(), []float32{1.0, 0.0}, ChunkSearchOptions{})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(results) != 1 || !storeHnsw.(*store).hnsw.Has(chunk.ID()) {
+		t.Fatal("Chunk MUST be indexed once the transaction of the caller is committed")
+	}
+}
+
+func TestStore_DocumentCreateWithChunks(t *testing.T) {
+	storeHnsw := initStoreWith(t, func(opts *NewStoreOptions) {
+		opts.HNSWEnabled = true
+	})
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("text")
+
+	chunk1 := NewChunk().
+		SetChunkIndex(0).
+		SetContent("chunk 1").
+		SetEmbedding([]float32{1.0, 0.0})
+
+	// A duplicate ID fails halfway through
+	chunkDuplicate := NewChunk().
+		SetChunkIndex(1).
+		SetContent("chunk 2").
+		SetEmbedding([]float32{0.0, 1.0})
+	chunkDuplicate.SetID(chunk1.ID())
+
+	err := storeHnsw.DocumentCreateWithChunks(context.Background(), document, []ChunkInterface{chunk1, chunkDuplicate})
+
+	if err == nil {
+		t.Fatal("expected error for duplicate chunk ID, but got nil")
+	}
+
+	found, err := storeHnsw.DocumentFindByID(context.Background(), document.ID())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if found != nil {
+		t.Fatal("Document MUST NOT be created when a chunk fails")
+	}
+
+	count, err := storeHnsw.ChunkCount(context.Background(), ChunkQuery())
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if count != 0 {
+		t.Fatalf("Expected no chunks, got %d", count)
+	}
+
+	if storeHnsw.(*store).hnsw.Has(chunk1.ID()) {
+		t.Fatal("Rolled back chunk MUST NOT be in the HNSW index")
+	}
+
+	chunk2 := NewChunk().
+		SetChunkIndex(1).
+		SetContent("chunk 2").
+		SetEmbedding([]float32{0.0, 1.0})
+
+	err = storeHnsw.DocumentCreateWithChunks(context.Background(), document, []ChunkInterface{chunk1, chunk2})
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := storeHnsw.ChunkList(context.Background(), ChunkQuery().SetDocumentID(document.ID()))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 chunks of the document, got %d", len(list))
+	}
+
+	if !storeHnsw.(*store).hnsw.Has(chunk2.ID()) {
+		t.Fatal("Expected the committed chunk to be in the HNSW index")
+	}
+}
+
+func TestStore_DocumentReplaceChunks(t *testing.T) {
+	store, err := initStore(":memory:")
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	document := NewDocument().
+		SetStatus(DOCUMENT_STATUS_ACTIVE).
+		SetFileName("test.txt").
+		SetText("text")
+
+	chunkOld := NewChunk().
+		SetChunkIndex(0).
+		SetContent("old")
+
+	if err := store.DocumentCreateWithChunks(context.Background(), document, []ChunkInterface{chunkOld}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	chunkNew1 := NewChunk().
+		SetChunkIndex(0).
+		SetContent("new 1")
+
+	chunkNew2 := NewChunk().
+		SetChunkIndex(1).
+		SetContent("new 2")
+
+	if err := store.DocumentReplaceChunks(context.Background(), document.ID(), []ChunkInterface{chunkNew1, chunkNew2}); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	list, err := store.ChunkList(context.Background(), ChunkQuery().
+		SetDocumentID(document.ID()).
+		SetWithSoftDeleted(true))
+
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d", len(list))
+	}
+
+	for _, chunk := range list {
+		if chunk.ID() == chunkOld.ID() {
+			t.Fatal("Expected the old chunk to be replaced")
+		}
+	}
+
+	if err := store.DocumentReplaceChunks(context.Background(), "missing", nil); err == nil {
+		t.Fatal("expected error for missing document, but got nil")
+	}
+}
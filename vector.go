@@ -0,0 +1,187 @@
+package ragstore
+
+import (
+	"container/heap"
+	"errors"
+	"math"
+	"slices"
+	"sort"
+)
+
+// distanceMetrics lists the supported distance metrics
+var distanceMetrics = []string{
+	DISTANCE_METRIC_COSINE,
+	DISTANCE_METRIC_DOT_PRODUCT,
+	DISTANCE_METRIC_EUCLIDEAN,
+	DISTANCE_METRIC_MANHATTAN,
+}
+
+// validateDistanceMetric checks the metric is one of the supported ones
+func validateDistanceMetric(metric string) error {
+	if !slices.Contains(distanceMetrics, metric) {
+		return errors.New("unsupported distance metric: " + metric)
+	}
+
+	return nil
+}
+
+// vectorScore scores two equally sized vectors with the given metric.
+//
+// The score is always "higher is more similar", so for the distance
+// based metrics (euclidean, manhattan) the negated distance is returned.
+func vectorScore(metric string, a, b []float32) float64 {
+	switch metric {
+	case DISTANCE_METRIC_DOT_PRODUCT:
+		return dotProduct(a, b)
+	case DISTANCE_METRIC_EUCLIDEAN:
+		return -euclideanDistance(a, b)
+	case DISTANCE_METRIC_MANHATTAN:
+		return -manhattanDistance(a, b)
+	default:
+		return cosineSimilarity(a, b)
+	}
+}
+
+// cosineSimilarity returns the cosine similarity of two equally sized vectors
+func cosineSimilarity(a, b []float32) float64 {
+	var dot, normA, normB float64
+
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// dotProduct returns the dot product of two equally sized vectors
+func dotProduct(a, b []float32) float64 {
+	var dot float64
+
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+
+	return dot
+}
+
+// euclideanDistance returns the L2 distance of two equally sized vectors
+func euclideanDistance(a, b []float32) float64 {
+	var sum float64
+
+	for i := range a {
+		diff := float64(a[i]) - float64(b[i])
+		sum += diff * diff
+	}
+
+	return math.Sqrt(sum)
+}
+
+// manhattanDistance returns the L1 distance of two equally sized vectors
+func manhattanDistance(a, b []float32) float64 {
+	var sum float64
+
+	for i := range a {
+		sum += math.Abs(float64(a[i]) - float64(b[i]))
+	}
+
+	return sum
+}
+
+// NormalizeEmbedding returns a copy of the embedding scaled to unit (L2) length.
+// A zero vector is returned unchanged.
+func NormalizeEmbedding(embedding []float32) []float32 {
+	var sum float64
+
+	for _, value := range embedding {
+		sum += float64(value) * float64(value)
+	}
+
+	normalized := make([]float32, len(embedding))
+
+	if sum == 0 {
+		copy(normalized, embedding)
+		return normalized
+	}
+
+	norm := math.Sqrt(sum)
+
+	for i, value := range embedding {
+		normalized[i] = float32(float64(value) / norm)
+	}
+
+	return normalized
+}
+
+// ============================================================================
+// == TOP K
+// ============================================================================
+
+// scoredID is a chunk ID together with its score
+type scoredID struct {
+	id    string
+	score float64
+}
+
+// topK keeps the k highest scored IDs seen so far
+type topK struct {
+	k     int
+	items scoredIDHeap
+}
+
+func newTopK(k int) *topK {
+	return &topK{k: k, items: scoredIDHeap{}}
+}
+
+// Push offers a scored ID, keeping it only if it ranks in the top k
+func (t *topK) Push(id string, score float64) {
+	if t.k < 1 {
+		return
+	}
+
+	if len(t.items) < t.k {
+		heap.Push(&t.items, scoredID{id: id, score: score})
+		return
+	}
+
+	if score > t.items[0].score {
+		t.items[0] = scoredID{id: id, score: score}
+		heap.Fix(&t.items, 0)
+	}
+}
+
+// Sorted returns the kept IDs ordered by score, highest first
+func (t *topK) Sorted() []scoredID {
+	sorted := make([]scoredID, len(t.items))
+	copy(sorted, t.items)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].score > sorted[j].score
+	})
+
+	return sorted
+}
+
+// scoredIDHeap is a min-heap on score
+type scoredIDHeap []scoredID
+
+func (h scoredIDHeap) Len() int           { return len(h) }
+func (h scoredIDHeap) Less(i, j int) bool { return h[i].score < h[j].score }
+func (h scoredIDHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *scoredIDHeap) Push(x any) {
+	*h = append(*h, x.(scoredID))
+}
+
+func (h *scoredIDHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	*h = old[:n-1]
+	return item
+}